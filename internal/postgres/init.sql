@@ -7,6 +7,7 @@ GRANT ALL PRIVILEGES ON DATABASE certificate_dev TO docker;
 \connect certificate_dev;
 
 CREATE EXTENSION pgcrypto;
+CREATE EXTENSION pg_trgm;
 
 CREATE TABLE users (
     uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -14,18 +15,182 @@ CREATE TABLE users (
     password TEXT NOT NULL,
     email TEXT UNIQUE NOT NULL,
     active BOOL DEFAULT TRUE,
+    admin BOOL NOT NULL DEFAULT FALSE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE api_keys (
+    uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_uuid UUID NOT NULL REFERENCES users(uuid),
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX api_key_user_idx ON api_keys (user_uuid, created_at);
+
+CREATE TABLE organizations (
+    uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX org_name_idx ON organizations (name);
+
+CREATE TABLE org_members (
+    org_uuid UUID NOT NULL REFERENCES organizations(uuid),
+    user_uuid UUID NOT NULL REFERENCES users(uuid),
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'operator', 'admin', 'owner')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (org_uuid, user_uuid)
+);
+
+CREATE INDEX org_member_user_idx ON org_members (user_uuid);
+
+CREATE TABLE teams (
+    uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_uuid UUID NOT NULL REFERENCES organizations(uuid),
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX team_name_idx ON teams (org_uuid, name);
+
+CREATE TABLE team_members (
+    team_uuid UUID NOT NULL REFERENCES teams(uuid),
+    user_uuid UUID NOT NULL REFERENCES users(uuid),
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'operator', 'admin', 'owner')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_uuid, user_uuid)
+);
+
+CREATE INDEX team_member_user_idx ON team_members (user_uuid);
+
+CREATE TABLE authorities (
+    uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    parent_uuid UUID REFERENCES authorities(uuid),
+    name TEXT UNIQUE NOT NULL,
+    private_key BYTEA NOT NULL,
+    data_key BYTEA NOT NULL,
+    master_key_id TEXT NOT NULL,
+    body VARCHAR NOT NULL,
+    subject TEXT NOT NULL,
+    subject_key_id TEXT,
+    not_after TIMESTAMP NOT NULL,
+    crl_number BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX authority_master_key_idx ON authorities (master_key_id);
+CREATE INDEX authority_subject_key_idx ON authorities (subject_key_id);
+
+CREATE TABLE chain_certificates (
+    uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    body VARCHAR NOT NULL,
+    subject TEXT NOT NULL,
+    subject_key_id TEXT NOT NULL,
+    authority_key_id TEXT,
+    trusted BOOL NOT NULL DEFAULT FALSE,
+    not_after TIMESTAMP NOT NULL,
+    fingerprint TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX chain_subject_key_idx ON chain_certificates (subject_key_id);
+
 CREATE TABLE certificates (
     uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     user_uuid UUID REFERENCES users(uuid),
-    private_key VARCHAR NOT NULL,
-    body VARCHAR NOT NULL,
+    team_uuid UUID REFERENCES teams(uuid),
+    authority_uuid UUID REFERENCES authorities(uuid),
+    replaces_uuid UUID REFERENCES certificates(uuid),
+    status TEXT NOT NULL DEFAULT 'issued' CHECK (status IN ('pending', 'issued', 'revoked')),
+    private_key BYTEA,
+    data_key BYTEA,
+    master_key_id TEXT,
+    csr VARCHAR,
+    body VARCHAR,
+    subject TEXT NOT NULL,
+    issuer TEXT,
+    serial_number TEXT,
+    sans TEXT[] NOT NULL DEFAULT '{}',
+    key_algorithm TEXT NOT NULL,
+    not_before TIMESTAMP,
+    not_after TIMESTAMP,
+    fingerprint TEXT,
+    revoked_at TIMESTAMP,
+    revocation_reason INT,
+    labels JSONB NOT NULL DEFAULT '{}',
     active BOOL DEFAULT TRUE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE INDEX user_idx ON certificates (user_uuid, active);
+CREATE INDEX user_created_idx ON certificates (user_uuid, created_at, uuid);
+CREATE INDEX team_created_idx ON certificates (team_uuid, created_at, uuid) WHERE team_uuid IS NOT NULL;
+CREATE INDEX master_key_idx ON certificates (master_key_id);
+CREATE INDEX not_after_idx ON certificates (not_after) WHERE active;
+CREATE UNIQUE INDEX serial_number_idx ON certificates (authority_uuid, serial_number) WHERE authority_uuid IS NOT NULL;
+CREATE UNIQUE INDEX user_fingerprint_idx ON certificates (user_uuid, fingerprint);
+CREATE INDEX fingerprint_idx ON certificates (fingerprint);
+CREATE UNIQUE INDEX user_issuer_serial_idx ON certificates (user_uuid, issuer, serial_number);
+CREATE INDEX issuer_serial_idx ON certificates (issuer, serial_number);
+CREATE INDEX revoked_idx ON certificates (authority_uuid) WHERE status = 'revoked';
+CREATE INDEX sans_idx ON certificates USING GIN (sans);
+CREATE INDEX subject_trgm_idx ON certificates USING GIN (subject gin_trgm_ops);
+CREATE INDEX issuer_trgm_idx ON certificates USING GIN (issuer gin_trgm_ops);
+CREATE INDEX user_not_after_idx ON certificates (user_uuid, not_after);
+CREATE INDEX labels_idx ON certificates USING GIN (labels jsonb_path_ops);
+
+CREATE TABLE certificate_events (
+    uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    cert_uuid UUID NOT NULL REFERENCES certificates(uuid),
+    actor TEXT NOT NULL,
+    event TEXT NOT NULL,
+    old_value JSONB,
+    new_value JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT clock_timestamp()
+);
+
+CREATE INDEX cert_event_idx ON certificate_events (cert_uuid, created_at);
+CREATE RULE certificate_events_no_update AS ON UPDATE TO certificate_events DO INSTEAD NOTHING;
+CREATE RULE certificate_events_no_delete AS ON DELETE TO certificate_events DO INSTEAD NOTHING;
+
+CREATE TABLE private_key_exports (
+    uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    cert_uuid UUID REFERENCES certificates(uuid),
+    user_uuid UUID REFERENCES users(uuid),
+    actor TEXT NOT NULL,
+    remote_addr TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE renewal_policies (
+    cert_uuid UUID PRIMARY KEY REFERENCES certificates(uuid),
+    method TEXT NOT NULL CHECK (method IN ('ca', 'acme')),
+    days_before_expiry INT NOT NULL CHECK (days_before_expiry > 0),
+    profile TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE expiry_notifications (
+    cert_uuid UUID REFERENCES certificates(uuid),
+    threshold_days INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cert_uuid, threshold_days)
+);
+
+CREATE TABLE crls (
+    authority_uuid UUID PRIMARY KEY REFERENCES authorities(uuid),
+    number BIGINT NOT NULL,
+    der BYTEA NOT NULL,
+    this_update TIMESTAMP NOT NULL,
+    next_update TIMESTAMP NOT NULL
+);
 
 GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO docker;
\ No newline at end of file
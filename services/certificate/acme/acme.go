@@ -0,0 +1,182 @@
+package acme
+
+import (
+	"certificate/pki"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/acme"
+	"net"
+	"sync"
+)
+
+// Challenge types that solvers can be registered for.
+const (
+	ChallengeHTTP01 = "http-01"
+	ChallengeDNS01  = "dns-01"
+)
+
+// Client obtains certificates from an ACME (RFC 8555) directory, registering
+// its account on first use.
+type Client struct {
+	client  *acme.Client
+	contact []string
+	solvers map[string]Solver
+
+	mu         sync.Mutex
+	registered bool
+}
+
+// New returns a Client for the ACME directory at `directoryURL` using account
+// key `accountKey`.
+func New(directoryURL string, accountKey crypto.Signer) *Client {
+	return &Client{
+		client:  &acme.Client{DirectoryURL: directoryURL, Key: accountKey},
+		solvers: map[string]Solver{},
+	}
+}
+
+// WithContact sets the email address the account is registered with.
+func (c *Client) WithContact(email string) *Client {
+	c.contact = []string{"mailto:" + email}
+	return c
+}
+
+// WithSolver uses `solver` for challenges of type `challengeType`.
+func (c *Client) WithSolver(challengeType string, solver Solver) *Client {
+	c.solvers[challengeType] = solver
+	return c
+}
+
+// Obtain orders a certificate for `sans` with the public key of `key`,
+// solves the challenges of all its authorizations and finalizes the order.
+// It returns the issued certificate followed by its chain.
+func (c *Client) Obtain(ctx context.Context, key crypto.Signer, sans []string) ([]*x509.Certificate, error) {
+	if len(sans) == 0 {
+		return nil, errors.New("no SANs to order a certificate for")
+	}
+	if err := c.register(ctx); err != nil {
+		return nil, err
+	}
+
+	// authorize every identifier of the order
+	var ids []acme.AuthzID
+	for _, san := range sans {
+		if net.ParseIP(san) != nil {
+			ids = append(ids, acme.IPIDs(san)...)
+		} else {
+			ids = append(ids, acme.DomainIDs(san)...)
+		}
+	}
+	order, err := c.client.AuthorizeOrder(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := c.authorize(ctx, authzURL); err != nil {
+			return nil, err
+		}
+	}
+	if order, err = c.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, fmt.Errorf("failed to wait for order: %w", err)
+	}
+
+	// finalize the order with a CSR for `key`
+	csr, err := pki.CreateCSR(key, pkix.Name{CommonName: sans[0]}, sans)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(csr))
+	chain, _, err := c.client.CreateOrderCert(ctx, order.FinalizeURL, block.Bytes, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize order: %w", err)
+	}
+	certs := make([]*x509.Certificate, 0, len(chain))
+	for _, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse issued certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// register registers the account of `c` unless it already is.
+func (c *Client) register(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.registered {
+		return nil
+	}
+	_, err := c.client.Register(ctx, &acme.Account{Contact: c.contact}, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("failed to register account: %w", err)
+	}
+	c.registered = true
+	return nil
+}
+
+// authorize solves a challenge of the authorization at `authzURL` with one of
+// the registered solvers, unless the authorization is already valid.
+func (c *Client) authorize(ctx context.Context, authzURL string) (err error) {
+	authz, err := c.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	// pick the first challenge we have a solver for
+	var challenge *acme.Challenge
+	var solver Solver
+	for _, ch := range authz.Challenges {
+		if s, ok := c.solvers[ch.Type]; ok {
+			challenge, solver = ch, s
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no solver for any challenge of %q", authz.Identifier.Value)
+	}
+
+	// present the challenge response and let the server validate it
+	value, err := c.challengeValue(challenge)
+	if err != nil {
+		return err
+	}
+	domain := authz.Identifier.Value
+	if err := solver.Present(ctx, domain, challenge.Token, value); err != nil {
+		return fmt.Errorf("failed to present %s challenge for %q: %w", challenge.Type, domain, err)
+	}
+	defer func() {
+		if cleanUpErr := solver.CleanUp(ctx, domain, challenge.Token, value); cleanUpErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to clean up %s challenge for %q: %w",
+				challenge.Type, domain, cleanUpErr))
+		}
+	}()
+	if _, err := c.client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept %s challenge for %q: %w", challenge.Type, domain, err)
+	}
+	if _, err := c.client.WaitAuthorization(ctx, authzURL); err != nil {
+		return fmt.Errorf("failed to authorize %q: %w", domain, err)
+	}
+	return nil
+}
+
+// challengeValue returns what has to be presented to solve `challenge`.
+func (c *Client) challengeValue(challenge *acme.Challenge) (string, error) {
+	switch challenge.Type {
+	case ChallengeHTTP01:
+		return c.client.HTTP01ChallengeResponse(challenge.Token)
+	case ChallengeDNS01:
+		return c.client.DNS01ChallengeRecord(challenge.Token)
+	default:
+		return "", fmt.Errorf("unsupported challenge type %q", challenge.Type)
+	}
+}
@@ -0,0 +1,330 @@
+package acme_test
+
+import (
+	"certificate/acme"
+	"certificate/ca"
+	"certificate/pki/pkitest"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	xacme "golang.org/x/crypto/acme"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockServer is an in-process ACME server that validates challenges through
+// `lookup` instead of HTTP or DNS, and signs orders with `ca`.
+type mockServer struct {
+	*httptest.Server
+	ca             *ca.CA
+	challengeTypes []string
+	// lookup returns what the client presented for a challenge
+	lookup func(challengeType, domain, token string) string
+	// keyAuth returns the key authorization of `token` for the account key
+	keyAuth func(token string) string
+
+	mu          sync.Mutex
+	identifiers []string
+	authzStatus []string
+	chain       []byte
+}
+
+func newMockServer(t *testing.T, accountKey crypto.Signer, challengeTypes ...string) *mockServer {
+	root, err := ca.NewRoot(pkix.Name{CommonName: "Mock ACME CA"}, time.Hour)
+	assert.Nil(t, err)
+	thumbprint, err := xacme.JWKThumbprint(accountKey.Public())
+	assert.Nil(t, err)
+
+	s := &mockServer{
+		ca:             root,
+		challengeTypes: challengeTypes,
+		keyAuth:        func(token string) string { return token + "." + thumbprint },
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", s.directory)
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/account", s.account)
+	mux.HandleFunc("/order", s.newOrder)
+	mux.HandleFunc("/order/1", s.order)
+	mux.HandleFunc("/authz/", s.authz)
+	mux.HandleFunc("/challenge/", s.challenge)
+	mux.HandleFunc("/finalize", s.finalize)
+	mux.HandleFunc("/cert", s.cert)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// payload decodes the payload of the JWS in the body of `r` into `v`.
+func payload(r *http.Request, v any) error {
+	jws := struct {
+		Payload string `json:"payload"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil || len(b) == 0 {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *mockServer) directory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"newNonce":   s.URL + "/nonce",
+		"newAccount": s.URL + "/account",
+		"newOrder":   s.URL + "/order",
+	})
+}
+
+func (s *mockServer) account(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Location", s.URL+"/account/1")
+	writeJSON(w, http.StatusCreated, map[string]string{"status": "valid"})
+}
+
+func (s *mockServer) newOrder(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Identifiers []struct {
+			Value string `json:"value"`
+		} `json:"identifiers"`
+	}{}
+	if err := payload(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range req.Identifiers {
+		s.identifiers = append(s.identifiers, id.Value)
+		s.authzStatus = append(s.authzStatus, xacme.StatusPending)
+	}
+	w.Header().Set("Location", s.URL+"/order/1")
+	writeJSON(w, http.StatusCreated, s.orderLocked())
+}
+
+func (s *mockServer) order(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Location", s.URL+"/order/1")
+	writeJSON(w, http.StatusOK, s.orderLocked())
+}
+
+// orderLocked returns the only order, s.mu must be held.
+func (s *mockServer) orderLocked() map[string]any {
+	status := xacme.StatusReady
+	var authzURLs []string
+	for i, authzStatus := range s.authzStatus {
+		authzURLs = append(authzURLs, fmt.Sprintf("%s/authz/%d", s.URL, i))
+		if authzStatus == xacme.StatusInvalid {
+			status = xacme.StatusInvalid
+		} else if authzStatus != xacme.StatusValid && status != xacme.StatusInvalid {
+			status = xacme.StatusPending
+		}
+	}
+	order := map[string]any{
+		"status":         status,
+		"authorizations": authzURLs,
+		"finalize":       s.URL + "/finalize",
+	}
+	if s.chain != nil {
+		order["status"] = xacme.StatusValid
+		order["certificate"] = s.URL + "/cert"
+	}
+	return order
+}
+
+func (s *mockServer) authz(w http.ResponseWriter, r *http.Request) {
+	var i int
+	fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/authz/"), "%d", &i)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var challenges []map[string]string
+	for _, challengeType := range s.challengeTypes {
+		challenges = append(challenges, map[string]string{
+			"type":   challengeType,
+			"url":    fmt.Sprintf("%s/challenge/%d/%s", s.URL, i, challengeType),
+			"token":  fmt.Sprintf("token%d", i),
+			"status": s.authzStatus[i],
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"identifier": map[string]string{"type": "dns", "value": s.identifiers[i]},
+		"status":     s.authzStatus[i],
+		"challenges": challenges,
+	})
+}
+
+func (s *mockServer) challenge(w http.ResponseWriter, r *http.Request) {
+	var i int
+	var challengeType string
+	fmt.Sscanf(strings.Replace(strings.TrimPrefix(r.URL.Path, "/challenge/"), "/", " ", 1),
+		"%d %s", &i, &challengeType)
+	token := fmt.Sprintf("token%d", i)
+
+	// validate the challenge response right away
+	expected := s.keyAuth(token)
+	if challengeType == acme.ChallengeDNS01 {
+		digest := sha256.Sum256([]byte(expected))
+		expected = base64.RawURLEncoding.EncodeToString(digest[:])
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authzStatus[i] = xacme.StatusInvalid
+	if s.lookup(challengeType, s.identifiers[i], token) == expected {
+		s.authzStatus[i] = xacme.StatusValid
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"type":   challengeType,
+		"url":    s.URL + r.URL.Path,
+		"token":  token,
+		"status": s.authzStatus[i],
+	})
+}
+
+func (s *mockServer) finalize(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		CSR string `json:"csr"`
+	}{}
+	if err := payload(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cert, err := s.ca.Sign(csr, ca.DefaultProfiles["server"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chain = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.ca.Cert.Raw})...)
+	w.Header().Set("Location", s.URL+"/order/1")
+	writeJSON(w, http.StatusOK, s.orderLocked())
+}
+
+func (s *mockServer) cert(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Write(s.chain)
+}
+
+// mockDNSSolver keeps the TXT records it is asked to present.
+type mockDNSSolver struct {
+	records map[string]string
+}
+
+func (s *mockDNSSolver) Present(_ context.Context, domain, _, value string) error {
+	s.records[domain] = value
+	return nil
+}
+
+func (s *mockDNSSolver) CleanUp(_ context.Context, domain, _, _ string) error {
+	delete(s.records, domain)
+	return nil
+}
+
+func TestClient_Obtain(t *testing.T) {
+	accountKey := pkitest.NewKey()
+	sans := []string{"dog.example.com", "cat.example.com"}
+
+	t.Run("happy_path_http01", func(t *testing.T) {
+		solver := acme.NewHTTP01Solver()
+		server := newMockServer(t, accountKey, acme.ChallengeDNS01, acme.ChallengeHTTP01)
+		var presented []string
+		server.lookup = func(challengeType, _, token string) string {
+			presented = append(presented, challengeType)
+			value, _ := solver.Response(token)
+			return value
+		}
+		client := acme.New(server.URL+"/directory", accountKey).
+			WithContact("dog@example.com").
+			WithSolver(acme.ChallengeHTTP01, solver)
+
+		key := pkitest.NewKey()
+		chain, err := client.Obtain(context.Background(), key, sans)
+		assert.Nil(t, err)
+		assert.Len(t, chain, 2)
+		assert.Equal(t, sans, chain[0].DNSNames)
+		assert.True(t, key.PublicKey.Equal(chain[0].PublicKey))
+		assert.Nil(t, chain[0].CheckSignatureFrom(chain[1]))
+		assert.Equal(t, []string{acme.ChallengeHTTP01, acme.ChallengeHTTP01}, presented)
+		_, ok := solver.Response("token0")
+		assert.False(t, ok)
+	})
+	t.Run("happy_path_dns01", func(t *testing.T) {
+		solver := &mockDNSSolver{records: map[string]string{}}
+		server := newMockServer(t, accountKey, acme.ChallengeHTTP01, acme.ChallengeDNS01)
+		server.lookup = func(challengeType, domain, _ string) string {
+			assert.Equal(t, acme.ChallengeDNS01, challengeType)
+			return solver.records[domain]
+		}
+		client := acme.New(server.URL+"/directory", accountKey).
+			WithSolver(acme.ChallengeDNS01, solver)
+
+		chain, err := client.Obtain(context.Background(), pkitest.NewKey(), sans)
+		assert.Nil(t, err)
+		assert.Len(t, chain, 2)
+		assert.Equal(t, sans, chain[0].DNSNames)
+		assert.Empty(t, solver.records)
+	})
+	t.Run("error_invalid_challenge_response", func(t *testing.T) {
+		server := newMockServer(t, accountKey, acme.ChallengeHTTP01)
+		server.lookup = func(_, _, _ string) string {
+			return "wrong"
+		}
+		client := acme.New(server.URL+"/directory", accountKey).
+			WithSolver(acme.ChallengeHTTP01, acme.NewHTTP01Solver())
+
+		chain, err := client.Obtain(context.Background(), pkitest.NewKey(), sans)
+		assert.NotNil(t, err)
+		assert.Nil(t, chain)
+	})
+	t.Run("error_no_solver", func(t *testing.T) {
+		server := newMockServer(t, accountKey, acme.ChallengeDNS01)
+		client := acme.New(server.URL+"/directory", accountKey).
+			WithSolver(acme.ChallengeHTTP01, acme.NewHTTP01Solver())
+
+		chain, err := client.Obtain(context.Background(), pkitest.NewKey(), sans)
+		assert.NotNil(t, err)
+		assert.Nil(t, chain)
+	})
+	t.Run("error_no_sans", func(t *testing.T) {
+		client := acme.New("http://127.0.0.1:0/directory", accountKey)
+
+		chain, err := client.Obtain(context.Background(), pkitest.NewKey(), nil)
+		assert.NotNil(t, err)
+		assert.Nil(t, chain)
+	})
+}
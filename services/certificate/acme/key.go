@@ -0,0 +1,39 @@
+package acme
+
+import (
+	"certificate/pki"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadAccountKey reads a PEM encoded account key from file `path`.
+func LoadAccountKey(path string) (crypto.Signer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account key file: %w", err)
+	}
+	return pki.ParsePrivateKey(string(content))
+}
+
+// GenerateAccountKeyFile writes a new PEM encoded ECDSA P-256 account key to
+// file `path`, it errors out if the file already exists.
+func GenerateAccountKeyFile(path string) error {
+	key, err := pki.GenerateKey(pki.KeyTypeECDSAP256)
+	if err != nil {
+		return fmt.Errorf("failed to generate account key: %w", err)
+	}
+	encoded, err := pki.EncodePrivateKey(key)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create account key file: %w", err)
+	}
+	if _, err := f.WriteString(encoded); err != nil {
+		return errors.Join(fmt.Errorf("failed to write account key file: %w", err), f.Close())
+	}
+	return f.Close()
+}
@@ -0,0 +1,31 @@
+package acme_test
+
+import (
+	"certificate/acme"
+	"crypto/ecdsa"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateAccountKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "account.key")
+
+	t.Run("happy_path", func(t *testing.T) {
+		assert.Nil(t, acme.GenerateAccountKeyFile(path))
+		key, err := acme.LoadAccountKey(path)
+		assert.Nil(t, err)
+		assert.IsType(t, &ecdsa.PrivateKey{}, key)
+	})
+	t.Run("error_file_exists", func(t *testing.T) {
+		assert.NotNil(t, acme.GenerateAccountKeyFile(path))
+	})
+}
+
+func TestLoadAccountKey(t *testing.T) {
+	t.Run("error_missing_file", func(t *testing.T) {
+		key, err := acme.LoadAccountKey(filepath.Join(t.TempDir(), "account.key"))
+		assert.NotNil(t, err)
+		assert.Nil(t, key)
+	})
+}
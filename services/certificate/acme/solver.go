@@ -0,0 +1,77 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Solver presents the responses to one type of ACME challenge.
+type Solver interface {
+	// Present makes `value`, the response to the challenge with `token`,
+	// available where the ACME server looks for it when validating `domain`.
+	Present(ctx context.Context, domain, token, value string) error
+	// CleanUp removes what Present added.
+	CleanUp(ctx context.Context, domain, token, value string) error
+}
+
+// HTTP01Solver solves HTTP-01 challenges by keeping the responses in memory,
+// they have to be served at /.well-known/acme-challenge/{token} on port 80 of
+// every domain being validated.
+type HTTP01Solver struct {
+	mu        sync.RWMutex
+	responses map[string]string
+}
+
+// NewHTTP01Solver returns an HTTP01Solver without any responses.
+func NewHTTP01Solver() *HTTP01Solver {
+	return &HTTP01Solver{responses: map[string]string{}}
+}
+
+func (s *HTTP01Solver) Present(_ context.Context, _, token, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[token] = value
+	return nil
+}
+
+func (s *HTTP01Solver) CleanUp(_ context.Context, _, token, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, token)
+	return nil
+}
+
+// Response returns the response to serve for `token`, if any.
+func (s *HTTP01Solver) Response(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.responses[token]
+	return value, ok
+}
+
+// DNS01Hook solves DNS-01 challenges by running an external command that
+// manages the TXT records, as `<command> present|cleanup <fqdn> <value>`.
+type DNS01Hook struct {
+	Command string
+}
+
+func (h *DNS01Hook) Present(ctx context.Context, domain, _, value string) error {
+	return h.run(ctx, "present", domain, value)
+}
+
+func (h *DNS01Hook) CleanUp(ctx context.Context, domain, _, value string) error {
+	return h.run(ctx, "cleanup", domain, value)
+}
+
+// run runs the hook command for `action` on the TXT record of `domain`.
+func (h *DNS01Hook) run(ctx context.Context, action, domain, value string) error {
+	fqdn := "_acme-challenge." + strings.TrimPrefix(domain, "*.") + "."
+	output, err := exec.CommandContext(ctx, h.Command, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", h.Command, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// apiKeyPrefix starts every API key, telling them apart from session tokens
+// and making leaked keys easy to scan for.
+const apiKeyPrefix = "cert_"
+
+// API keys are `apiKeyPrefix`, a random public ID of apiKeyIDSize bytes and a
+// random secret of apiKeySecretSize bytes, hex and base64url encoded.
+const (
+	apiKeyIDSize     = 4
+	apiKeySecretSize = 32
+)
+
+// GenerateAPIKey returns a new random API key along with its prefix, which
+// identifies the key without revealing its secret.
+func GenerateAPIKey() (string, string, error) {
+	id := make([]byte, apiKeyIDSize)
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key ID: %w", err)
+	}
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key secret: %w", err)
+	}
+	prefix := apiKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// IsAPIKey returns whether bearer token `token` is an API key rather than a
+// session token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash API key `key` is stored as.
+// API keys are random enough that a fast hash cannot be brute forced.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,43 @@
+package auth_test
+
+import (
+	"certificate/auth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		key, prefix, err := auth.GenerateAPIKey()
+		assert.Nil(t, err)
+		assert.True(t, auth.IsAPIKey(key))
+		assert.True(t, strings.HasPrefix(key, prefix+"_"))
+		assert.Regexp(t, `^cert_[0-9a-f]{8}$`, prefix)
+		assert.Len(t, key, len(prefix)+1+43)
+
+		other, otherPrefix, err := auth.GenerateAPIKey()
+		assert.Nil(t, err)
+		assert.NotEqual(t, key, other)
+		assert.NotEqual(t, prefix, otherPrefix)
+	})
+}
+
+func TestIsAPIKey(t *testing.T) {
+	t.Run("happy_path_session_token", func(t *testing.T) {
+		token, _, err := mockTokens(t).Issue(mockUserUUID)
+		assert.Nil(t, err)
+		assert.False(t, auth.IsAPIKey(token))
+	})
+}
+
+func TestHashAPIKey(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		key, _, err := auth.GenerateAPIKey()
+		assert.Nil(t, err)
+		hash := auth.HashAPIKey(key)
+		assert.Len(t, hash, 64)
+		assert.Equal(t, hash, auth.HashAPIKey(key))
+		assert.NotContains(t, hash, key)
+	})
+}
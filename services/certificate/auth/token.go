@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// keySize is the size of signing keys in bytes.
+const keySize = 32
+
+// DefaultExpiry is how long tokens are valid unless configured otherwise.
+const DefaultExpiry = time.Hour
+
+// ErrInvalidToken is returned when a token is malformed, expired or not signed
+// by any of the signing keys.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Tokens issues and verifies session tokens, which are JWTs signed with
+// HMAC-SHA256 whose subject is the UUID of the authenticated user. New tokens
+// are signed with the current signing key, and retired signing keys are kept
+// around to verify tokens that have not expired yet.
+type Tokens struct {
+	currentID string
+	keys      map[string][]byte
+	expiry    time.Duration
+}
+
+// LoadTokens returns a Tokens using the signing key in file `path` as its
+// current signing key.
+func LoadTokens(path string) (*Tokens, error) {
+	key, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	id := keyID(key)
+	return &Tokens{
+		currentID: id,
+		keys:      map[string][]byte{id: key},
+		expiry:    DefaultExpiry,
+	}, nil
+}
+
+// AddRetiredKeyFile loads the signing key in file `path` so that tokens signed
+// with it can still be verified.
+func (t *Tokens) AddRetiredKeyFile(path string) error {
+	key, err := readKeyFile(path)
+	if err != nil {
+		return err
+	}
+	t.keys[keyID(key)] = key
+	return nil
+}
+
+// WithExpiry sets how long issued tokens are valid.
+func (t *Tokens) WithExpiry(expiry time.Duration) *Tokens {
+	t.expiry = expiry
+	return t
+}
+
+// GenerateKeyFile writes a new random hex encoded signing key to file `path`,
+// it errors out if the file already exists.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create signing key file: %w", err)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return errors.Join(fmt.Errorf("failed to write signing key file: %w", err), f.Close())
+	}
+	return f.Close()
+}
+
+// readKeyFile reads a hex encoded signing key from file `path`.
+func readKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key file: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signing key: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("signing key is %d bytes, should be %d", len(key), keySize)
+	}
+	return key, nil
+}
+
+// keyID derives a non-secret identifier from signing key `key`, which is set
+// as the `kid` header of the tokens it signs.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Issue returns a token for the user with UUID `userUUID` signed with the
+// current signing key, along with the time it expires at.
+func (t *Tokens) Issue(userUUID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(t.expiry)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
+		Subject:   userUUID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	token.Header["kid"] = t.currentID
+	signed, err := token.SignedString(t.keys[t.currentID])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// Verify checks that `token` is signed with one of the signing keys and has
+// not expired, and returns the UUID of the user it was issued to. It returns
+// ErrInvalidToken otherwise.
+func (t *Tokens) Verify(token string) (string, error) {
+	claims := &jwt.StandardClaims{}
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}}
+	if _, err := parser.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
+		id, _ := token.Header["kid"].(string)
+		key, ok := t.keys[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", id)
+		}
+		return key, nil
+	}); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.ExpiresAt == 0 || claims.Subject == "" {
+		return "", fmt.Errorf("%w: token has no expiry or subject", ErrInvalidToken)
+	}
+	return claims.Subject, nil
+}
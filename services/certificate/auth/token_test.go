@@ -0,0 +1,125 @@
+package auth_test
+
+import (
+	"certificate/auth"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mockUserUUID = "mock_user_uuid"
+
+// mockKeyFile generates a signing key file in a temporary directory and
+// returns its path.
+func mockKeyFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "auth.key")
+	assert.Nil(t, auth.GenerateKeyFile(path))
+	return path
+}
+
+// mockTokens returns a Tokens with a new signing key.
+func mockTokens(t *testing.T) *auth.Tokens {
+	tokens, err := auth.LoadTokens(mockKeyFile(t))
+	assert.Nil(t, err)
+	return tokens
+}
+
+func TestGenerateKeyFile(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		path := mockKeyFile(t)
+		info, err := os.Stat(path)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+	t.Run("error_file_exists", func(t *testing.T) {
+		assert.NotNil(t, auth.GenerateKeyFile(mockKeyFile(t)))
+	})
+}
+
+func TestLoadTokens(t *testing.T) {
+	t.Run("error_missing_file", func(t *testing.T) {
+		tokens, err := auth.LoadTokens(filepath.Join(t.TempDir(), "missing.key"))
+		assert.NotNil(t, err)
+		assert.Nil(t, tokens)
+	})
+	t.Run("error_short_key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "short.key")
+		assert.Nil(t, os.WriteFile(path, []byte("abcd\n"), 0600))
+		tokens, err := auth.LoadTokens(path)
+		assert.NotNil(t, err)
+		assert.Nil(t, tokens)
+	})
+}
+
+func TestTokens_Verify(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		tokens := mockTokens(t)
+		token, expiresAt, err := tokens.Issue(mockUserUUID)
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now().Add(auth.DefaultExpiry), expiresAt, time.Minute)
+
+		userUUID, err := tokens.Verify(token)
+		assert.Nil(t, err)
+		assert.Equal(t, mockUserUUID, userUUID)
+	})
+
+	t.Run("happy_path_retired_key", func(t *testing.T) {
+		retiredKey := mockKeyFile(t)
+		retired, err := auth.LoadTokens(retiredKey)
+		assert.Nil(t, err)
+		token, _, err := retired.Issue(mockUserUUID)
+		assert.Nil(t, err)
+
+		tokens := mockTokens(t)
+		assert.Nil(t, tokens.AddRetiredKeyFile(retiredKey))
+		userUUID, err := tokens.Verify(token)
+		assert.Nil(t, err)
+		assert.Equal(t, mockUserUUID, userUUID)
+	})
+
+	t.Run("error_unknown_key", func(t *testing.T) {
+		token, _, err := mockTokens(t).Issue(mockUserUUID)
+		assert.Nil(t, err)
+
+		userUUID, err := mockTokens(t).Verify(token)
+		assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+		assert.Empty(t, userUUID)
+	})
+
+	t.Run("error_expired", func(t *testing.T) {
+		tokens := mockTokens(t).WithExpiry(-time.Minute)
+		token, _, err := tokens.Issue(mockUserUUID)
+		assert.Nil(t, err)
+
+		userUUID, err := tokens.Verify(token)
+		assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+		assert.Empty(t, userUUID)
+	})
+
+	t.Run("error_tampered", func(t *testing.T) {
+		tokens := mockTokens(t)
+		token, _, err := tokens.Issue(mockUserUUID)
+		assert.Nil(t, err)
+		parts := strings.Split(token, ".")
+		other, _, err := tokens.Issue("other_user_uuid")
+		assert.Nil(t, err)
+		parts[1] = strings.Split(other, ".")[1]
+
+		userUUID, err := tokens.Verify(strings.Join(parts, "."))
+		assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+		assert.Empty(t, userUUID)
+	})
+
+	t.Run("error_none_algorithm", func(t *testing.T) {
+		// {"alg":"none","typ":"JWT"}.{"sub":"mock_user_uuid","exp":9999999999}.
+		token := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." +
+			"eyJzdWIiOiJtb2NrX3VzZXJfdXVpZCIsImV4cCI6OTk5OTk5OTk5OX0."
+		userUUID, err := mockTokens(t).Verify(token)
+		assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+		assert.Empty(t, userUUID)
+	})
+}
@@ -0,0 +1,139 @@
+package ca
+
+import (
+	"certificate/pki"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// serialNumberBits is the size of randomly generated serial numbers.
+const serialNumberBits = 128
+
+// CA is a certificate authority that signs certificates with its private
+// key. `CRLDistributionPoints` and `OCSPServer` are the URLs the certificates
+// it signs point relying parties to for their revocation status.
+type CA struct {
+	Cert                  *x509.Certificate
+	Key                   crypto.Signer
+	CRLDistributionPoints []string
+	OCSPServer            []string
+}
+
+// NewRoot returns a new self-signed root CA for `subject` valid for
+// `validity`, with a newly generated ECDSA P-384 key.
+func NewRoot(subject pkix.Name, validity time.Duration) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	template, err := caTemplate(subject, validity)
+	if err != nil {
+		return nil, err
+	}
+	return create(template, template, key, key)
+}
+
+// NewIntermediate returns a new intermediate CA for `subject` valid for
+// `validity` and signed by `ca`, with a newly generated ECDSA P-384 key. Its
+// validity is capped by the validity of `ca`.
+func (ca *CA) NewIntermediate(subject pkix.Name, validity time.Duration) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	template, err := caTemplate(subject, validity)
+	if err != nil {
+		return nil, err
+	}
+	if template.NotAfter.After(ca.Cert.NotAfter) {
+		template.NotAfter = ca.Cert.NotAfter
+	}
+	return create(template, ca.Cert, key, ca.Key)
+}
+
+// Load returns the CA with PEM encoded certificate `body` and private key
+// `privateKey`. It errors out if the certificate is not a CA certificate or
+// the key does not belong to it.
+func Load(body, privateKey string) (*CA, error) {
+	cert, err := pki.ParseCertificate(body)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, errors.New("certificate is not a CA certificate")
+	}
+	key, err := pki.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := pki.CheckKeyPair(cert, key); err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// WithRevocationURLs points the certificates `ca` signs to the CRL and the
+// OCSP responder the service publishes under `baseURL` for authority
+// `authorityUUID`, which are `<baseURL>/ca/<authorityUUID>/crl` and
+// `<baseURL>/ocsp`. An empty `baseURL` leaves them out.
+func (ca *CA) WithRevocationURLs(baseURL, authorityUUID string) *CA {
+	if baseURL == "" {
+		ca.CRLDistributionPoints, ca.OCSPServer = nil, nil
+		return ca
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	ca.CRLDistributionPoints = []string{baseURL + "/ca/" + authorityUUID + "/crl"}
+	ca.OCSPServer = []string{baseURL + "/ocsp"}
+	return ca
+}
+
+// caTemplate returns the template of a CA certificate for `subject` that is
+// valid from now on for `validity`.
+func caTemplate(subject pkix.Name, validity time.Duration) (*x509.Certificate, error) {
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		NotBefore:             now,
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil
+}
+
+// create signs `template` for `key` with `parentKey` and returns the result as
+// a CA.
+func create(template, parent *x509.Certificate, key, parentKey crypto.Signer) (*CA, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created certificate: %w", err)
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// newSerialNumber returns a random positive serial number.
+func newSerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), serialNumberBits))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serialNumber.Add(serialNumber, big.NewInt(1)), nil
+}
@@ -0,0 +1,76 @@
+package ca_test
+
+import (
+	"certificate/ca"
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var mockRootSubject = pkix.Name{CommonName: "Mock Root CA", Organization: []string{"Dog"}}
+
+var mockIntermediateSubject = pkix.Name{CommonName: "Mock Intermediate CA"}
+
+// mockRoot returns a new root CA valid for a year.
+func mockRoot(t *testing.T) *ca.CA {
+	root, err := ca.NewRoot(mockRootSubject, 365*24*time.Hour)
+	assert.Nil(t, err)
+	return root
+}
+
+func TestNewRoot(t *testing.T) {
+	root := mockRoot(t)
+	assert.True(t, root.Cert.IsCA)
+	assert.Equal(t, mockRootSubject.String(), root.Cert.Subject.String())
+	assert.Equal(t, mockRootSubject.String(), root.Cert.Issuer.String())
+	assert.Nil(t, root.Cert.CheckSignatureFrom(root.Cert))
+	assert.Nil(t, pki.CheckKeyPair(root.Cert, root.Key))
+	assert.WithinDuration(t, time.Now().Add(365*24*time.Hour), root.Cert.NotAfter, time.Minute)
+}
+
+func TestCA_NewIntermediate(t *testing.T) {
+	root := mockRoot(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		intermediate, err := root.NewIntermediate(mockIntermediateSubject, 30*24*time.Hour)
+		assert.Nil(t, err)
+		assert.True(t, intermediate.Cert.IsCA)
+		assert.Equal(t, mockRootSubject.String(), intermediate.Cert.Issuer.String())
+		assert.Nil(t, intermediate.Cert.CheckSignatureFrom(root.Cert))
+		assert.Nil(t, pki.CheckKeyPair(intermediate.Cert, intermediate.Key))
+	})
+	t.Run("happy_path_validity_capped_by_parent", func(t *testing.T) {
+		intermediate, err := root.NewIntermediate(mockIntermediateSubject, 10*365*24*time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, root.Cert.NotAfter, intermediate.Cert.NotAfter)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	root := mockRoot(t)
+	body := pki.EncodeCertificate(root.Cert)
+	privateKey, err := pki.EncodePrivateKey(root.Key)
+	assert.Nil(t, err)
+
+	t.Run("happy_path", func(t *testing.T) {
+		loaded, err := ca.Load(body, privateKey)
+		assert.Nil(t, err)
+		assert.Equal(t, root.Cert.Raw, loaded.Cert.Raw)
+		assert.Equal(t, root.Key, loaded.Key)
+	})
+	t.Run("error_not_ca", func(t *testing.T) {
+		_, leafPEM, leafKey := pkitest.SelfSigned(&x509.Certificate{Subject: pkix.Name{CommonName: "dog"}})
+		loaded, err := ca.Load(leafPEM, leafKey)
+		assert.NotNil(t, err)
+		assert.Nil(t, loaded)
+	})
+	t.Run("error_mismatched_key", func(t *testing.T) {
+		loaded, err := ca.Load(body, pkitest.EncodeKey(pkitest.NewKey()))
+		assert.NotNil(t, err)
+		assert.Nil(t, loaded)
+	})
+}
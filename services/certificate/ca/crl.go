@@ -0,0 +1,55 @@
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// oidReasonCode is the OID of the CRL entry extension holding the reason
+// code, see RFC 5280 section 5.3.1.
+var oidReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// Revocation is a certificate listed in a CRL.
+type Revocation struct {
+	SerialNumber *big.Int
+	RevokedAt    time.Time
+	// Reason is an RFC 5280 reason code, it is left out of the CRL entry if
+	// it is 0 (unspecified).
+	Reason int
+}
+
+// CreateCRL returns a DER encoded CRL with sequence number `number` listing
+// `revocations`, signed by `ca` and valid from `thisUpdate` until
+// `nextUpdate`.
+func (ca *CA) CreateCRL(number *big.Int, revocations []Revocation, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	revoked := make([]pkix.RevokedCertificate, 0, len(revocations))
+	for _, revocation := range revocations {
+		entry := pkix.RevokedCertificate{
+			SerialNumber:   revocation.SerialNumber,
+			RevocationTime: revocation.RevokedAt.UTC(),
+		}
+		if revocation.Reason != 0 {
+			reason, err := asn1.Marshal(asn1.Enumerated(revocation.Reason))
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal reason code: %w", err)
+			}
+			entry.Extensions = []pkix.Extension{{Id: oidReasonCode, Value: reason}}
+		}
+		revoked = append(revoked, entry)
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: revoked,
+		Number:              number,
+		ThisUpdate:          thisUpdate.UTC(),
+		NextUpdate:          nextUpdate.UTC(),
+	}, ca.Cert, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CRL: %w", err)
+	}
+	return der, nil
+}
@@ -0,0 +1,56 @@
+package ca_test
+
+import (
+	"certificate/ca"
+	"certificate/pki"
+	"crypto/x509"
+	"encoding/asn1"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCA_CreateCRL(t *testing.T) {
+	root := mockRoot(t)
+	thisUpdate := time.Now().UTC().Truncate(time.Second)
+	revokedAt := thisUpdate.Add(-time.Hour)
+
+	t.Run("happy_path", func(t *testing.T) {
+		der, err := root.CreateCRL(big.NewInt(7), []ca.Revocation{
+			{SerialNumber: big.NewInt(42), RevokedAt: revokedAt, Reason: pki.ReasonKeyCompromise},
+			{SerialNumber: big.NewInt(43), RevokedAt: revokedAt},
+		}, thisUpdate, thisUpdate.Add(24*time.Hour))
+		assert.Nil(t, err)
+
+		crl, err := x509.ParseRevocationList(der)
+		assert.Nil(t, err)
+		assert.Nil(t, crl.CheckSignatureFrom(root.Cert))
+		assert.Equal(t, big.NewInt(7), crl.Number)
+		assert.Equal(t, thisUpdate, crl.ThisUpdate)
+		assert.Equal(t, thisUpdate.Add(24*time.Hour), crl.NextUpdate)
+		assert.Len(t, crl.RevokedCertificates, 2)
+		assert.Equal(t, big.NewInt(42), crl.RevokedCertificates[0].SerialNumber)
+		assert.Equal(t, revokedAt, crl.RevokedCertificates[0].RevocationTime)
+		assert.Len(t, crl.RevokedCertificates[0].Extensions, 1)
+		var reason asn1.Enumerated
+		_, err = asn1.Unmarshal(crl.RevokedCertificates[0].Extensions[0].Value, &reason)
+		assert.Nil(t, err)
+		assert.Equal(t, asn1.Enumerated(pki.ReasonKeyCompromise), reason)
+		assert.Equal(t, big.NewInt(43), crl.RevokedCertificates[1].SerialNumber)
+		assert.Empty(t, crl.RevokedCertificates[1].Extensions)
+	})
+	t.Run("happy_path_empty", func(t *testing.T) {
+		der, err := root.CreateCRL(big.NewInt(1), nil, thisUpdate, thisUpdate.Add(time.Hour))
+		assert.Nil(t, err)
+
+		crl, err := x509.ParseRevocationList(der)
+		assert.Nil(t, err)
+		assert.Empty(t, crl.RevokedCertificates)
+	})
+	t.Run("error_next_update_before_this_update", func(t *testing.T) {
+		der, err := root.CreateCRL(big.NewInt(1), nil, thisUpdate, thisUpdate.Add(-time.Hour))
+		assert.NotNil(t, err)
+		assert.Nil(t, der)
+	})
+}
@@ -0,0 +1,75 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"time"
+)
+
+// oidOCSPNoCheck is the OID of the extension telling clients not to check the
+// revocation status of an OCSP responder certificate, see RFC 6960 section
+// 4.2.2.2.1.
+var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+// OCSPResponder is a delegated OCSP responder of a CA, which signs OCSP
+// responses about the certificates issued by the CA with its own key.
+type OCSPResponder struct {
+	Issuer *x509.Certificate
+	Cert   *x509.Certificate
+	Key    crypto.Signer
+}
+
+// NewOCSPResponder returns a new delegated OCSP responder signed by `ca`
+// valid for `validity`, with a newly generated ECDSA P-256 key. Its validity
+// is capped by the validity of `ca`.
+func (ca *CA) NewOCSPResponder(validity time.Duration) (*OCSPResponder, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:    serialNumber,
+		Subject:         pkix.Name{CommonName: ca.Cert.Subject.CommonName + " OCSP Responder"},
+		NotBefore:       now,
+		NotAfter:        now.Add(validity),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidOCSPNoCheck, Value: asn1.NullBytes}},
+	}
+	if template.NotAfter.After(ca.Cert.NotAfter) {
+		template.NotAfter = ca.Cert.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created certificate: %w", err)
+	}
+	return &OCSPResponder{Issuer: ca.Cert, Cert: cert, Key: key}, nil
+}
+
+// Sign returns the DER encoded OCSP response for `template` signed by `r`,
+// which includes the responder certificate so that clients can verify it
+// against the CA.
+func (r *OCSPResponder) Sign(template ocsp.Response) ([]byte, error) {
+	template.Certificate = r.Cert
+	der, err := ocsp.CreateResponse(r.Issuer, r.Cert, template, r.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP response: %w", err)
+	}
+	return der, nil
+}
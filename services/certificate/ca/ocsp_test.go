@@ -0,0 +1,55 @@
+package ca_test
+
+import (
+	"crypto/x509"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ocsp"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCA_NewOCSPResponder(t *testing.T) {
+	root := mockRoot(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		responder, err := root.NewOCSPResponder(24 * time.Hour)
+		assert.Nil(t, err)
+		assert.Nil(t, responder.Cert.CheckSignatureFrom(root.Cert))
+		assert.False(t, responder.Cert.IsCA)
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, responder.Cert.ExtKeyUsage)
+		assert.Equal(t, 24*time.Hour, responder.Cert.NotAfter.Sub(responder.Cert.NotBefore))
+		assert.Equal(t, root.Cert, responder.Issuer)
+	})
+	t.Run("happy_path_validity_capped_by_ca", func(t *testing.T) {
+		responder, err := root.NewOCSPResponder(10 * 365 * 24 * time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, root.Cert.NotAfter, responder.Cert.NotAfter)
+	})
+}
+
+func TestOCSPResponder_Sign(t *testing.T) {
+	root := mockRoot(t)
+	responder, err := root.NewOCSPResponder(24 * time.Hour)
+	assert.Nil(t, err)
+	thisUpdate := time.Now().UTC().Truncate(time.Second)
+	revokedAt := thisUpdate.Add(-time.Hour)
+
+	der, err := responder.Sign(ocsp.Response{
+		Status:           ocsp.Revoked,
+		SerialNumber:     big.NewInt(42),
+		ThisUpdate:       thisUpdate,
+		NextUpdate:       thisUpdate.Add(time.Hour),
+		RevokedAt:        revokedAt,
+		RevocationReason: ocsp.KeyCompromise,
+	})
+	assert.Nil(t, err)
+
+	resp, err := ocsp.ParseResponse(der, root.Cert)
+	assert.Nil(t, err)
+	assert.Equal(t, ocsp.Revoked, resp.Status)
+	assert.Equal(t, big.NewInt(42), resp.SerialNumber)
+	assert.Equal(t, revokedAt, resp.RevokedAt)
+	assert.Equal(t, ocsp.KeyCompromise, resp.RevocationReason)
+	assert.Equal(t, responder.Cert.Raw, resp.Certificate.Raw)
+}
@@ -0,0 +1,179 @@
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that is encoded in JSON as a string like "24h".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Profile describes what kind of certificates a CA may sign.
+type Profile struct {
+	// Validity is how long signed certificates are valid for.
+	Validity Duration `json:"validity"`
+	// KeyUsage and ExtKeyUsage name the key usages of signed certificates, see
+	// `keyUsages` and `extKeyUsages`.
+	KeyUsage    []string `json:"key_usage"`
+	ExtKeyUsage []string `json:"ext_key_usage"`
+	// AllowedDomains restricts DNS SANs to these domains and their
+	// subdomains, any domain is allowed if it is empty.
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	// AllowWildcards allows DNS SANs like "*.example.com".
+	AllowWildcards bool `json:"allow_wildcards"`
+	// AllowIPAddresses allows IP address SANs.
+	AllowIPAddresses bool `json:"allow_ip_addresses"`
+	// AllowURIs allows URI SANs.
+	AllowURIs bool `json:"allow_uris"`
+}
+
+// Profiles maps profile names to profiles.
+type Profiles map[string]*Profile
+
+// DefaultProfiles are used when no profiles are configured.
+var DefaultProfiles = Profiles{
+	"server": {
+		Validity:         Duration(90 * 24 * time.Hour),
+		KeyUsage:         []string{"digital_signature", "key_encipherment"},
+		ExtKeyUsage:      []string{"server_auth"},
+		AllowWildcards:   true,
+		AllowIPAddresses: true,
+	},
+	"client": {
+		Validity:    Duration(30 * 24 * time.Hour),
+		KeyUsage:    []string{"digital_signature"},
+		ExtKeyUsage: []string{"client_auth"},
+		AllowURIs:   true,
+	},
+	"short-lived": {
+		Validity:         Duration(24 * time.Hour),
+		KeyUsage:         []string{"digital_signature", "key_encipherment"},
+		ExtKeyUsage:      []string{"server_auth", "client_auth"},
+		AllowIPAddresses: true,
+		AllowURIs:        true,
+	},
+}
+
+var keyUsages = map[string]x509.KeyUsage{
+	"digital_signature":  x509.KeyUsageDigitalSignature,
+	"content_commitment": x509.KeyUsageContentCommitment,
+	"key_encipherment":   x509.KeyUsageKeyEncipherment,
+	"data_encipherment":  x509.KeyUsageDataEncipherment,
+	"key_agreement":      x509.KeyUsageKeyAgreement,
+}
+
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"server_auth":      x509.ExtKeyUsageServerAuth,
+	"client_auth":      x509.ExtKeyUsageClientAuth,
+	"code_signing":     x509.ExtKeyUsageCodeSigning,
+	"email_protection": x509.ExtKeyUsageEmailProtection,
+	"time_stamping":    x509.ExtKeyUsageTimeStamping,
+}
+
+// LoadProfiles reads profiles from JSON file `path` and validates them.
+func LoadProfiles(path string) (Profiles, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles file: %w", err)
+	}
+	var profiles Profiles
+	if err := json.Unmarshal(content, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to decode profiles: %w", err)
+	}
+	for name, profile := range profiles {
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("invalid profile %q: %w", name, err)
+		}
+	}
+	return profiles, nil
+}
+
+// Restricted reports whether `p` only allows DNS SANs in its allowed
+// domains, i.e. it has allowed domains and allows neither IP address nor URI
+// SANs.
+func (p *Profile) Restricted() bool {
+	return len(p.AllowedDomains) > 0 && !p.AllowIPAddresses && !p.AllowURIs
+}
+
+// validate checks that all fields of `p` have known values.
+func (p *Profile) validate() error {
+	if p.Validity <= 0 {
+		return fmt.Errorf("validity must be positive")
+	}
+	if _, err := p.keyUsage(); err != nil {
+		return err
+	}
+	_, err := p.extKeyUsage()
+	return err
+}
+
+// keyUsage returns the x509 key usage named by p.KeyUsage.
+func (p *Profile) keyUsage() (x509.KeyUsage, error) {
+	var usage x509.KeyUsage
+	for _, name := range p.KeyUsage {
+		u, ok := keyUsages[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown key usage %q", name)
+		}
+		usage |= u
+	}
+	return usage, nil
+}
+
+// extKeyUsage returns the x509 extended key usages named by p.ExtKeyUsage.
+func (p *Profile) extKeyUsage() ([]x509.ExtKeyUsage, error) {
+	var usages []x509.ExtKeyUsage
+	for _, name := range p.ExtKeyUsage {
+		u, ok := extKeyUsages[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown extended key usage %q", name)
+		}
+		usages = append(usages, u)
+	}
+	return usages, nil
+}
+
+// checkDNSName checks if DNS SAN `name` is allowed by `p`.
+func (p *Profile) checkDNSName(name string) error {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "*.") {
+		if !p.AllowWildcards {
+			return fmt.Errorf("wildcard SAN %q is not allowed", name)
+		}
+		name = strings.TrimPrefix(name, "*.")
+	}
+	if strings.Contains(name, "*") {
+		return fmt.Errorf("SAN %q has a misplaced wildcard", name)
+	}
+	if len(p.AllowedDomains) == 0 {
+		return nil
+	}
+	for _, domain := range p.AllowedDomains {
+		domain = strings.ToLower(domain)
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("SAN %q is not in an allowed domain", name)
+}
@@ -0,0 +1,87 @@
+package ca_test
+
+import (
+	"certificate/ca"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDuration_JSON(t *testing.T) {
+	b, err := json.Marshal(ca.Duration(36 * time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, `"36h0m0s"`, string(b))
+
+	var d ca.Duration
+	assert.Nil(t, json.Unmarshal([]byte(`"90m"`), &d))
+	assert.Equal(t, ca.Duration(90*time.Minute), d)
+	assert.NotNil(t, json.Unmarshal([]byte(`"ninety minutes"`), &d))
+}
+
+func TestLoadProfiles(t *testing.T) {
+	// writeProfiles writes `content` to a temporary file and returns its path
+	writeProfiles := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "profiles.json")
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	t.Run("happy_path", func(t *testing.T) {
+		profiles, err := ca.LoadProfiles(writeProfiles(t, `{
+	"internal": {
+		"validity": "72h",
+		"key_usage": ["digital_signature"],
+		"ext_key_usage": ["server_auth", "client_auth"],
+		"allowed_domains": ["internal.example.com"]
+	}
+}`))
+		assert.Nil(t, err)
+		assert.Equal(t, ca.Profiles{
+			"internal": {
+				Validity:       ca.Duration(72 * time.Hour),
+				KeyUsage:       []string{"digital_signature"},
+				ExtKeyUsage:    []string{"server_auth", "client_auth"},
+				AllowedDomains: []string{"internal.example.com"},
+			},
+		}, profiles)
+	})
+	t.Run("error_unknown_key_usage", func(t *testing.T) {
+		profiles, err := ca.LoadProfiles(writeProfiles(t, `{"p": {"validity": "1h", "key_usage": ["fly"]}}`))
+		assert.NotNil(t, err)
+		assert.Nil(t, profiles)
+	})
+	t.Run("error_unknown_ext_key_usage", func(t *testing.T) {
+		profiles, err := ca.LoadProfiles(writeProfiles(t, `{"p": {"validity": "1h", "ext_key_usage": ["fly"]}}`))
+		assert.NotNil(t, err)
+		assert.Nil(t, profiles)
+	})
+	t.Run("error_missing_validity", func(t *testing.T) {
+		profiles, err := ca.LoadProfiles(writeProfiles(t, `{"p": {}}`))
+		assert.NotNil(t, err)
+		assert.Nil(t, profiles)
+	})
+	t.Run("error_missing_file", func(t *testing.T) {
+		profiles, err := ca.LoadProfiles(filepath.Join(t.TempDir(), "missing.json"))
+		assert.NotNil(t, err)
+		assert.Nil(t, profiles)
+	})
+}
+
+func TestProfile_Restricted(t *testing.T) {
+	for name, tc := range map[string]struct {
+		profile    ca.Profile
+		restricted bool
+	}{
+		"happy_path_allowed_domains": {ca.Profile{AllowedDomains: []string{"example.com"}, AllowWildcards: true}, true},
+		"happy_path_any_domain":      {ca.Profile{}, false},
+		"happy_path_ip_addresses":    {ca.Profile{AllowedDomains: []string{"example.com"}, AllowIPAddresses: true}, false},
+		"happy_path_uris":            {ca.Profile{AllowedDomains: []string{"example.com"}, AllowURIs: true}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.restricted, tc.profile.Restricted())
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const pemTypeCSR = "CERTIFICATE REQUEST"
+
+// ParseCSR decodes `csr` as a PKCS#10 certificate signing request and checks
+// its signature. `csr` can be PEM encoded or base64 encoded DER.
+func ParseCSR(csr string) (*x509.CertificateRequest, error) {
+	trimmed := strings.TrimSpace(csr)
+	var der []byte
+	if block, _ := pem.Decode([]byte(trimmed)); block != nil {
+		if block.Type != pemTypeCSR && block.Type != "NEW "+pemTypeCSR {
+			return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+		der = block.Bytes
+	} else {
+		var err error
+		if der, err = base64.StdEncoding.DecodeString(trimmed); err != nil {
+			return nil, errors.New("CSR is neither PEM nor base64 encoded")
+		}
+	}
+	req, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+	if err := req.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid CSR signature: %w", err)
+	}
+	return req, nil
+}
+
+// Sign issues a certificate for `csr` under `profile`. The SANs and the
+// common name are taken from `csr` after checking them against the SAN rules
+// of `profile`, the rest of the subject of `csr` is dropped. Everything else
+// comes from `profile` and the revocation URLs of `ca`. The validity is
+// capped by the validity of `ca`.
+func (ca *CA) Sign(csr *x509.CertificateRequest, profile *Profile) (*x509.Certificate, error) {
+	if err := profile.checkSANs(csr); err != nil {
+		return nil, err
+	}
+	keyUsage, err := profile.keyUsage()
+	if err != nil {
+		return nil, err
+	}
+	extKeyUsage, err := profile.extKeyUsage()
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: csr.Subject.CommonName},
+		DNSNames:              csr.DNSNames,
+		IPAddresses:           csr.IPAddresses,
+		URIs:                  csr.URIs,
+		NotBefore:             now,
+		NotAfter:              now.Add(time.Duration(profile.Validity)),
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		BasicConstraintsValid: true,
+		CRLDistributionPoints: ca.CRLDistributionPoints,
+		OCSPServer:            ca.OCSPServer,
+	}
+	if template.NotAfter.After(ca.Cert.NotAfter) {
+		template.NotAfter = ca.Cert.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// checkSANs checks the SANs requested by `csr` against the SAN rules of `p`.
+func (p *Profile) checkSANs(csr *x509.CertificateRequest) error {
+	if len(csr.DNSNames)+len(csr.IPAddresses)+len(csr.URIs) == 0 {
+		return errors.New("CSR has no SANs")
+	}
+	for _, name := range csr.DNSNames {
+		if err := p.checkDNSName(name); err != nil {
+			return err
+		}
+	}
+	if len(csr.IPAddresses) > 0 && !p.AllowIPAddresses {
+		return errors.New("IP address SANs are not allowed")
+	}
+	if len(csr.URIs) > 0 && !p.AllowURIs {
+		return errors.New("URI SANs are not allowed")
+	}
+	if len(csr.EmailAddresses) > 0 {
+		return errors.New("email address SANs are not allowed")
+	}
+	return checkCommonName(csr)
+}
+
+// checkCommonName checks that the common name of `csr` is empty or one of
+// its DNS or IP address SANs, so that it cannot name anything the SAN rules
+// did not allow.
+func checkCommonName(csr *x509.CertificateRequest) error {
+	commonName := csr.Subject.CommonName
+	if commonName == "" {
+		return nil
+	}
+	for _, name := range csr.DNSNames {
+		if strings.EqualFold(name, commonName) {
+			return nil
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if ip.String() == commonName {
+			return nil
+		}
+	}
+	return fmt.Errorf("common name %q is not one of the SANs", commonName)
+}
@@ -0,0 +1,123 @@
+package ca_test
+
+import (
+	"certificate/ca"
+	"certificate/pki/pkitest"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var mockProfile = &ca.Profile{
+	Validity:       ca.Duration(24 * time.Hour),
+	KeyUsage:       []string{"digital_signature"},
+	ExtKeyUsage:    []string{"server_auth"},
+	AllowedDomains: []string{"example.com"},
+	AllowWildcards: true,
+}
+
+// mockCSR returns a CSR for `template` signed by a new key.
+func mockCSR(t *testing.T, template *x509.CertificateRequest) *x509.CertificateRequest {
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, pkitest.NewKey())
+	assert.Nil(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	assert.Nil(t, err)
+	return csr
+}
+
+func TestParseCSR(t *testing.T) {
+	csr := mockCSR(t, &x509.CertificateRequest{DNSNames: []string{"dog.example.com"}})
+
+	t.Run("happy_path_pem", func(t *testing.T) {
+		parsed, err := ca.ParseCSR(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})))
+		assert.Nil(t, err)
+		assert.Equal(t, csr.Raw, parsed.Raw)
+	})
+	t.Run("happy_path_base64_der", func(t *testing.T) {
+		parsed, err := ca.ParseCSR(base64.StdEncoding.EncodeToString(csr.Raw))
+		assert.Nil(t, err)
+		assert.Equal(t, csr.Raw, parsed.Raw)
+	})
+	t.Run("error_wrong_pem_type", func(t *testing.T) {
+		parsed, err := ca.ParseCSR(pkitest.EncodeKey(pkitest.NewKey()))
+		assert.NotNil(t, err)
+		assert.Nil(t, parsed)
+	})
+	t.Run("error_malformed", func(t *testing.T) {
+		parsed, err := ca.ParseCSR("csr")
+		assert.NotNil(t, err)
+		assert.Nil(t, parsed)
+	})
+}
+
+func TestCA_Sign(t *testing.T) {
+	root := mockRoot(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		csr := mockCSR(t, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: "Dog.example.com", Organization: []string{"Mock Org"}},
+			DNSNames: []string{"dog.example.com", "*.cat.example.com", "example.com"},
+		})
+		cert, err := root.Sign(csr, mockProfile)
+		assert.Nil(t, err)
+		assert.Nil(t, cert.CheckSignatureFrom(root.Cert))
+		assert.False(t, cert.IsCA)
+		assert.Equal(t, "CN=Dog.example.com", cert.Subject.String())
+		assert.Equal(t, csr.DNSNames, cert.DNSNames)
+		assert.Equal(t, x509.KeyUsageDigitalSignature, cert.KeyUsage)
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+		assert.Equal(t, csr.PublicKey, cert.PublicKey)
+		assert.Equal(t, 24*time.Hour, cert.NotAfter.Sub(cert.NotBefore))
+	})
+	t.Run("happy_path_revocation_urls", func(t *testing.T) {
+		authority := &ca.CA{Cert: root.Cert, Key: root.Key}
+		authority.WithRevocationURLs("https://certs.example.com/", "mock_authority_uuid")
+		csr := mockCSR(t, &x509.CertificateRequest{DNSNames: []string{"dog.example.com"}})
+		cert, err := authority.Sign(csr, mockProfile)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"https://certs.example.com/ca/mock_authority_uuid/crl"}, cert.CRLDistributionPoints)
+		assert.Equal(t, []string{"https://certs.example.com/ocsp"}, cert.OCSPServer)
+	})
+	t.Run("happy_path_without_revocation_urls", func(t *testing.T) {
+		csr := mockCSR(t, &x509.CertificateRequest{DNSNames: []string{"dog.example.com"}})
+		cert, err := root.Sign(csr, mockProfile)
+		assert.Nil(t, err)
+		assert.Empty(t, cert.CRLDistributionPoints)
+		assert.Empty(t, cert.OCSPServer)
+	})
+	t.Run("happy_path_validity_capped_by_ca", func(t *testing.T) {
+		csr := mockCSR(t, &x509.CertificateRequest{DNSNames: []string{"dog.example.com"}})
+		cert, err := root.Sign(csr, &ca.Profile{Validity: ca.Duration(10 * 365 * 24 * time.Hour)})
+		assert.Nil(t, err)
+		assert.Equal(t, root.Cert.NotAfter, cert.NotAfter)
+	})
+
+	for name, template := range map[string]*x509.CertificateRequest{
+		"error_no_sans":              {Subject: pkix.Name{CommonName: "dog.example.com"}},
+		"error_domain_not_allowed":   {DNSNames: []string{"dog.example.org"}},
+		"error_domain_suffix_only":   {DNSNames: []string{"dogexample.com"}},
+		"error_misplaced_wildcard":   {DNSNames: []string{"dog.*.example.com"}},
+		"error_ip_not_allowed":       {DNSNames: []string{"dog.example.com"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}},
+		"error_uri_not_allowed":      {DNSNames: []string{"dog.example.com"}, URIs: []*url.URL{{Scheme: "spiffe", Host: "example.com"}}},
+		"error_email_not_allowed":    {DNSNames: []string{"dog.example.com"}, EmailAddresses: []string{"dog@example.com"}},
+		"error_wildcard_not_allowed": {DNSNames: []string{"*.example.com"}},
+		"error_common_name_not_san":  {Subject: pkix.Name{CommonName: "dog.example.org"}, DNSNames: []string{"dog.example.com"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			profile := *mockProfile
+			if name == "error_wildcard_not_allowed" {
+				profile.AllowWildcards = false
+			}
+			cert, err := root.Sign(mockCSR(t, template), &profile)
+			assert.NotNil(t, err)
+			assert.Nil(t, cert)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"archive/tar"
+	"certificate/db"
+	"certificate/db/postgres"
+	"certificate/encrypter"
+	"certificate/pki"
+	"compress/gzip"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importPrincipal is the principal importCerts adds certificates to any user
+// as, which is their actor in the certificate history.
+var importPrincipal = db.SystemPrincipal("import")
+
+// importCert is a certificate found by importCerts, along with its private key
+// if one was found.
+type importCert struct {
+	file string
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// skippedEntry is a file, or a certificate or key in it, that importCerts
+// did not import.
+type skippedEntry struct {
+	name   string
+	reason string
+}
+
+// importCerts adds the certificates in the PEM files under a directory or
+// tar.gz archive to the user given by `-user`, paired with the private keys
+// found in the same files or any other file by their public key. CA
+// certificates without a private key are added as chain certificates.
+// Certificates are added `-batch-size` at a time, a batch that fails is
+// retried one certificate at a time to skip only the rejected ones.
+func importCerts(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	userUUID := flags.String("user", "", "UUID of the user the certificates are added to")
+	masterKey := flags.String("master-key", os.Getenv("MASTER_KEY_FILE"), "path of the master key file private keys are encrypted with")
+	batchSize := flags.Int("batch-size", 100, "number of certificates added per transaction")
+	dryRun := flags.Bool("dry-run", false, "print what would be imported without adding anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userUUID == "" || flags.NArg() != 1 {
+		return errors.New("-user and a directory or tar.gz archive are required")
+	}
+	if *batchSize < 1 {
+		return errors.New("-batch-size must be positive")
+	}
+	if !*dryRun && *masterKey == "" {
+		return errors.New("-master-key is required unless -dry-run is set")
+	}
+
+	// collect the certificates and keys of all files
+	var certs []*x509.Certificate
+	var certFiles []string
+	var keys []crypto.Signer
+	var keyFiles []string
+	var skipped []skippedEntry
+	err := walkImportSource(flags.Arg(0), func(name string, data []byte) {
+		fileKeys, fileCerts, err := pki.ParsePEM(data)
+		switch {
+		case err != nil:
+			skipped = append(skipped, skippedEntry{name, err.Error()})
+		case len(fileKeys) == 0 && len(fileCerts) == 0:
+			skipped = append(skipped, skippedEntry{name, "no certificates or private keys"})
+		}
+		for _, cert := range fileCerts {
+			certs, certFiles = append(certs, cert), append(certFiles, name)
+		}
+		for _, key := range fileKeys {
+			keys, keyFiles = append(keys, key), append(keyFiles, name)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// pair certificates with keys by public key, and set aside CA
+	// certificates without one as chain certificates
+	keyIndex := map[string]int{}
+	skippedKeys := map[int]bool{}
+	for i, key := range keys {
+		id, err := publicKeyID(key.Public())
+		if err != nil {
+			skipped = append(skipped, skippedEntry{keyFiles[i], err.Error()})
+			skippedKeys[i] = true
+			continue
+		}
+		if j, ok := keyIndex[id]; ok {
+			skipped = append(skipped, skippedEntry{keyFiles[i], fmt.Sprintf("private key is a duplicate of a private key in %s", keyFiles[j])})
+			skippedKeys[i] = true
+			continue
+		}
+		keyIndex[id] = i
+	}
+	usedKeys := map[int]bool{}
+	seen := map[string]string{}
+	var leaves, chain []*importCert
+	for i, cert := range certs {
+		fingerprint := pki.Fingerprint(cert)
+		if file, ok := seen[fingerprint]; ok {
+			skipped = append(skipped, skippedEntry{certFiles[i], fmt.Sprintf("%s is a duplicate of a certificate in %s", cert.Subject, file)})
+			continue
+		}
+		seen[fingerprint] = certFiles[i]
+		imported := &importCert{file: certFiles[i], cert: cert}
+		if id, err := publicKeyID(cert.PublicKey); err == nil {
+			if j, ok := keyIndex[id]; ok {
+				imported.key = keys[j]
+				usedKeys[j] = true
+			}
+		}
+		if imported.key == nil && cert.IsCA {
+			chain = append(chain, imported)
+		} else {
+			leaves = append(leaves, imported)
+		}
+	}
+	for i := range keys {
+		if !usedKeys[i] && !skippedKeys[i] {
+			skipped = append(skipped, skippedEntry{keyFiles[i], "private key matches no certificate"})
+		}
+	}
+
+	if *dryRun {
+		for _, imported := range chain {
+			fmt.Printf("would import chain certificate %s from %s\n", imported.cert.Subject, imported.file)
+		}
+		for _, imported := range leaves {
+			withKey := "without private key"
+			if imported.key != nil {
+				withKey = "with private key"
+			}
+			fmt.Printf("would import certificate %s %s from %s\n", imported.cert.Subject, withKey, imported.file)
+		}
+		printImportSummary("would import", len(leaves), len(chain), skipped)
+		return nil
+	}
+
+	// connect to the database with the master key private keys are encrypted
+	// with
+	keyEncrypter, err := encrypter.LoadLocal(*masterKey)
+	if err != nil {
+		return err
+	}
+	pg, err := postgres.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to db: %w", err)
+	}
+	defer pg.Close()
+	pg.WithKeyEncrypter(keyEncrypter)
+
+	// add chain certificates first, so that the chains of the certificates
+	// can be built as soon as they are added
+	addedChain := 0
+	for _, imported := range chain {
+		if err := pg.AddChainCert(&db.ChainCert{Body: pki.EncodeCertificate(imported.cert)}); err != nil {
+			skipped = append(skipped, skippedEntry{imported.file, err.Error()})
+		} else {
+			addedChain++
+		}
+	}
+
+	// add certificates in batches
+	added := 0
+	for start := 0; start < len(leaves); start += *batchSize {
+		end := start + *batchSize
+		if end > len(leaves) {
+			end = len(leaves)
+		}
+		batch := leaves[start:end]
+		dbCerts := make([]*db.Cert, len(batch))
+		for i, imported := range batch {
+			if dbCerts[i], err = newImportDBCert(*userUUID, imported); err != nil {
+				return err
+			}
+		}
+		if err := pg.AddCerts(dbCerts, importPrincipal); err == nil {
+			added += len(batch)
+			continue
+		}
+		for i, dbCert := range dbCerts {
+			if err := pg.AddCert(dbCert, importPrincipal); err != nil {
+				skipped = append(skipped, skippedEntry{batch[i].file, err.Error()})
+			} else {
+				added++
+			}
+		}
+	}
+	printImportSummary("imported", added, addedChain, skipped)
+	return nil
+}
+
+// newImportDBCert returns the db.Cert of `imported` for the user with UUID
+// `userUUID`.
+func newImportDBCert(userUUID string, imported *importCert) (*db.Cert, error) {
+	dbCert := &db.Cert{UserUUID: userUUID, Body: pki.EncodeCertificate(imported.cert)}
+	if imported.key != nil {
+		privateKey, err := pki.EncodePrivateKey(imported.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode private key from %s: %w", imported.file, err)
+		}
+		dbCert.PrivateKey = privateKey
+	}
+	return dbCert, nil
+}
+
+// printImportSummary prints the number of imported certificates and the
+// files, certificates and keys that were skipped.
+func printImportSummary(verb string, certs, chainCerts int, skipped []skippedEntry) {
+	fmt.Println(verb, certs, "certificates and", chainCerts, "chain certificates")
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Println("skipped", len(skipped), "entries:")
+	for _, s := range skipped {
+		fmt.Printf("  %s: %s\n", s.name, s.reason)
+	}
+}
+
+// publicKeyID returns a string identifying `pub`, that is equal for the
+// certificate and the private key of a key pair.
+func publicKeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	return string(der), nil
+}
+
+// walkImportSource calls `fn` with the name and content of every regular file
+// under `path`, which is either a directory or a tar.gz archive.
+func walkImportSource(path string, fn func(name string, data []byte)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return err
+			}
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			fn(name, data)
+			return nil
+		})
+	}
+	if !strings.HasSuffix(path, ".tar.gz") && !strings.HasSuffix(path, ".tgz") {
+		return fmt.Errorf("%s is neither a directory nor a tar.gz archive", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip: %w", err)
+	}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from tar: %w", header.Name, err)
+		}
+		fn(header.Name, data)
+	}
+}
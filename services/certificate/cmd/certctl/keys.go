@@ -0,0 +1,63 @@
+package main
+
+import (
+	"certificate/db/postgres"
+	"certificate/encrypter"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// genMasterKey writes a new master key to the file given by `-out`.
+func genMasterKey(args []string) error {
+	flags := flag.NewFlagSet("gen-master-key", flag.ExitOnError)
+	out := flags.String("out", "", "path of the master key file to create")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	if err := encrypter.GenerateKeyFile(*out); err != nil {
+		return err
+	}
+	fmt.Println("master key written to", *out)
+	return nil
+}
+
+// rotateKeys re-wraps the data keys of all stored private keys with the
+// master key given by `-master-key`. Master keys the data keys are currently
+// wrapped with are given by `-retired-keys`.
+func rotateKeys(args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	masterKey := flags.String("master-key", "", "path of the new master key file")
+	retiredKeys := flags.String("retired-keys", "", "comma separated paths of the master key files being rotated out")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *masterKey == "" || *retiredKeys == "" {
+		return errors.New("-master-key and -retired-keys are required")
+	}
+
+	// load the new master key along with the retired ones
+	keyEncrypter, err := encrypter.LoadLocal(*masterKey)
+	if err != nil {
+		return err
+	}
+	for _, path := range strings.Split(*retiredKeys, ",") {
+		if err := keyEncrypter.AddRetiredKeyFile(path); err != nil {
+			return err
+		}
+	}
+
+	// re-wrap all rows that are not using the new master key yet
+	pg, err := postgres.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to db: %w", err)
+	}
+	defer pg.Close()
+	count, err := pg.WithKeyEncrypter(keyEncrypter).RotateMasterKey()
+	fmt.Println("re-wrapped", count, "data keys with master key", keyEncrypter.MasterKeyID())
+	return err
+}
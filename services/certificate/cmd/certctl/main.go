@@ -0,0 +1,47 @@
+// Command certctl runs administrative tasks against the certificate service's
+// database.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a certctl subcommand that parses its own flags from `args`.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{name: "gen-master-key", usage: "generate a new master key file", run: genMasterKey},
+	{name: "rotate-keys", usage: "re-wrap private key data keys with a new master key", run: rotateKeys},
+	{name: "import", usage: "add the certificates and keys of a directory or tar.gz to a user", run: importCerts},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: certctl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, fmt.Errorf("%s: %w", c.name, err))
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
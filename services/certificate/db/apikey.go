@@ -0,0 +1,46 @@
+package db
+
+import (
+	"time"
+)
+
+// API key scopes, each one allows the requests of an API key to a group of
+// endpoints.
+const (
+	ScopeCertRead  = "cert:read"
+	ScopeCertWrite = "cert:write"
+	ScopeUserRead  = "user:read"
+	ScopeUserWrite = "user:write"
+	ScopeOrgRead   = "org:read"
+	ScopeOrgWrite  = "org:write"
+)
+
+// Scopes are all API key scopes.
+var Scopes = []string{ScopeCertRead, ScopeCertWrite, ScopeUserRead, ScopeUserWrite,
+	ScopeOrgRead, ScopeOrgWrite}
+
+// APIKey represents the database schema of the API keys service accounts
+// authenticate with on behalf of a user. Only the hash of a key is stored,
+// `Key` is only set when the key is created, and `Prefix` identifies it
+// afterwards. An API key without `Scopes` has all of them.
+type APIKey struct {
+	UUID       string    `json:"uuid"`
+	UserUUID   string    `json:"user_uuid"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Key        string    `json:"key,omitempty"`
+	Scopes     []string  `json:"scopes,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+}
+
+// APIKeyDatabase is the interface that wraps all database operations related
+// to API keys.
+type APIKeyDatabase interface {
+	AddAPIKey(key *APIKey, principal *Principal) error
+	GetAPIKeys(userUUID string, principal *Principal) ([]*APIKey, error)
+	RevokeAPIKey(uuid, userUUID string, principal *Principal) error
+	AuthenticateAPIKey(key string) (*Principal, error)
+}
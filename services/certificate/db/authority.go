@@ -0,0 +1,39 @@
+package db
+
+import (
+	"time"
+)
+
+// Authority represents the database schema for the internal certificate
+// authorities.
+type Authority struct {
+	UUID       string    `json:"uuid"`
+	ParentUUID string    `json:"parent_uuid,omitempty"`
+	Name       string    `json:"name"`
+	PrivateKey string    `json:"private_key,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	Subject    string    `json:"subject,omitempty"`
+	NotAfter   time.Time `json:"not_after,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+}
+
+// CRL represents the database schema for the latest certificate revocation
+// list of an authority.
+type CRL struct {
+	AuthorityUUID string
+	Number        int64
+	DER           []byte
+	ThisUpdate    time.Time
+	NextUpdate    time.Time
+}
+
+// AuthorityDatabase is the interface that wraps all database operations
+// related to certificate authorities.
+type AuthorityDatabase interface {
+	AddAuthority(authority *Authority) error
+	GetAuthority(uuid string) (*Authority, error)
+	GetAuthorities() ([]*Authority, error)
+	NextCRLNumber(authorityUUID string) (int64, error)
+	SaveCRL(crl *CRL) error
+	GetCRL(authorityUUID string) (*CRL, error)
+}
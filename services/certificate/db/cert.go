@@ -4,20 +4,113 @@ import (
 	"time"
 )
 
-// Cert represents the database schema for certificates.
+// Certificate statuses.
+const (
+	// CertStatusPending is the status of a certificate whose private key and
+	// CSR were generated by the service, and whose signed certificate has not
+	// been uploaded yet.
+	CertStatusPending = "pending"
+	// CertStatusIssued is the status of a certificate with a body.
+	CertStatusIssued = "issued"
+	// CertStatusRevoked is the status of an issued certificate that was
+	// revoked, it can never be activated again.
+	CertStatusRevoked = "revoked"
+)
+
+// NoTeam is the team a certificate is transferred to in order to take it out
+// of its team, as opposed to an empty team, which keeps the current one.
+const NoTeam = "none"
+
+// Cert represents the database schema for certificates. `Chain` holds the PEM
+// encoded intermediates a certificate is added with, which are stored as
+// chain certificates rather than with the certificate.
 type Cert struct {
+	UUID             string            `json:"uuid"`
+	UserUUID         string            `json:"user_uuid"`
+	TeamUUID         string            `json:"team_uuid,omitempty"`
+	AuthorityUUID    string            `json:"authority_uuid,omitempty"`
+	ReplacesUUID     string            `json:"replaces_uuid,omitempty"`
+	Status           string            `json:"status,omitempty"`
+	PrivateKey       string            `json:"private_key,omitempty"`
+	CSR              string            `json:"csr,omitempty"`
+	Body             string            `json:"body,omitempty"`
+	Chain            []string          `json:"-"`
+	Subject          string            `json:"subject,omitempty"`
+	Issuer           string            `json:"issuer,omitempty"`
+	SerialNumber     string            `json:"serial_number,omitempty"`
+	SANs             []string          `json:"sans,omitempty"`
+	KeyAlgorithm     string            `json:"key_algorithm,omitempty"`
+	NotBefore        time.Time         `json:"not_before,omitempty"`
+	NotAfter         time.Time         `json:"not_after,omitempty"`
+	Fingerprint      string            `json:"fingerprint,omitempty"`
+	RevokedAt        time.Time         `json:"revoked_at,omitempty"`
+	RevocationReason int               `json:"revocation_reason,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Active           bool              `json:"active"`
+	CreatedAt        time.Time         `json:"created_at,omitempty"`
+}
+
+// CertQuery filters the certificates of the user with UUID `UserUUID`, or of
+// the team with UUID `TeamUUID` if it is set, zero fields match every
+// certificate. `SAN` matches certificates with a subject alternative name
+// that covers it, `Subject` and `Issuer` match case-insensitive substrings,
+// and `Active` is nil to match both active and inactive certificates.
+// `Labels` matches certificates that have all of them. `Cursor` is the
+// `NextCursor` of the previous page, and `Limit` the maximum number of
+// certificates of the page, or 0 for all.
+type CertQuery struct {
+	UserUUID      string
+	TeamUUID      string
+	SAN           string
+	Subject       string
+	Issuer        string
+	ExpiresBefore time.Time
+	ExpiresAfter  time.Time
+	Active        *bool
+	Labels        map[string]string
+	Cursor        string
+	Limit         int
+}
+
+// CertPage is a page of the certificates matching a CertQuery, `NextCursor`
+// is empty on the last page.
+type CertPage struct {
+	Certs      []*Cert `json:"certs"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// KeyExport represents the database schema for the audit log of private key
+// exports.
+type KeyExport struct {
 	UUID       string    `json:"uuid"`
+	CertUUID   string    `json:"cert_uuid"`
 	UserUUID   string    `json:"user_uuid"`
-	PrivateKey string    `json:"private_key,omitempty"`
-	Body       string    `json:"body,omitempty"`
-	Active     bool      `json:"active"`
+	Actor      string    `json:"actor"`
+	RemoteAddr string    `json:"remote_addr"`
 	CreatedAt  time.Time `json:"created_at,omitempty"`
 }
 
 // CertDatabase is the interface that wraps all certificate related database
 // operations.
 type CertDatabase interface {
-	AddCert(cert *Cert) error
-	GetCerts(userUUID string) ([]*Cert, error)
-	SetCertActiveStatus(certUUID, userUUID string, active bool) error
+	AddCert(cert *Cert, principal *Principal) error
+	AddCerts(certs []*Cert, principal *Principal) error
+	CheckCertOwners(userUUID, teamUUID string, principal *Principal) error
+	GetCerts(userUUID, cursor string, limit int, principal *Principal) (*CertPage, error)
+	GetCert(uuid, userUUID string, principal *Principal) (*Cert, error)
+	QueryCerts(query *CertQuery, principal *Principal) (*CertPage, error)
+	SetCertActiveStatus(certUUID, userUUID string, active bool, principal *Principal) (*Cert, error)
+	GetCertHistory(uuid, userUUID string, principal *Principal) ([]*CertEvent, error)
+	ExportPrivateKey(export *KeyExport, password string, principal *Principal) (string, error)
+	AddPendingCert(cert *Cert, principal *Principal) error
+	CompletePendingCert(cert *Cert, principal *Principal) error
+	GetExpiringCerts(now time.Time, thresholdDays int) ([]*Cert, error)
+	AddExpiryNotification(certUUID string, thresholdDays int) error
+	RevokeCert(cert *Cert, principal *Principal) error
+	GetRevokedCerts(authorityUUID string) ([]*Cert, error)
+	GetCertBySerialNumber(authorityUUID, serialNumber string) (*Cert, error)
+	GetCertsByFingerprint(fingerprint string, principal *Principal) ([]*Cert, error)
+	GetCertsByIssuerSerial(issuer, serialNumber string, principal *Principal) ([]*Cert, error)
+	SetCertLabels(uuid, userUUID string, labels map[string]string, principal *Principal) (*Cert, error)
+	TransferCert(uuid, userUUID, toUserUUID, toTeamUUID string, principal *Principal) (*Cert, error)
 }
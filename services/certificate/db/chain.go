@@ -0,0 +1,28 @@
+package db
+
+import (
+	"time"
+)
+
+// ChainCert represents the database schema for intermediate and root
+// certificates, which are linked to the certificates they signed by their
+// subject key identifier. Trusted certificates are the trust anchors chains
+// are built to, along with the root CAs, and are only added by admins.
+type ChainCert struct {
+	UUID           string    `json:"uuid"`
+	Body           string    `json:"body"`
+	Subject        string    `json:"subject,omitempty"`
+	SubjectKeyID   string    `json:"subject_key_id,omitempty"`
+	AuthorityKeyID string    `json:"authority_key_id,omitempty"`
+	Trusted        bool      `json:"trusted"`
+	NotAfter       time.Time `json:"not_after,omitempty"`
+	Fingerprint    string    `json:"fingerprint,omitempty"`
+	CreatedAt      time.Time `json:"created_at,omitempty"`
+}
+
+// ChainDatabase is the interface that wraps all database operations related
+// to certificate chains.
+type ChainDatabase interface {
+	AddChainCert(cert *ChainCert) error
+	GetIssuerCerts(subjectKeyID string) ([]*ChainCert, error)
+}
@@ -4,4 +4,9 @@ package db
 type Database interface {
 	UserDatabase
 	CertDatabase
+	AuthorityDatabase
+	RenewalDatabase
+	ChainDatabase
+	APIKeyDatabase
+	OrgDatabase
 }
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnauthorized is returned when the credentials of a user are rejected.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrForbidden is returned when the caller of an operation may not access
+// the records it operates on.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrConflict is returned when a record clashes with an existing one, like a
+// certificate that its user already added.
+var ErrConflict = errors.New("conflict")
+
+// ValidationError is returned when a field of a record is rejected before it
+// is written to the database.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Certificate event types.
+const (
+	CertEventAdded       = "added"
+	CertEventCompleted   = "completed"
+	CertEventActivated   = "activated"
+	CertEventDeactivated = "deactivated"
+	CertEventRevoked     = "revoked"
+	CertEventTransferred = "transferred"
+	CertEventLabeled     = "labeled"
+)
+
+// CertEvent represents the database schema for the append-only history of
+// certificate changes. `OldValue` and `NewValue` are JSON objects of the
+// changed fields before and after the change, `OldValue` is null for added
+// certificates. `Actor` is the UUID of the user who made the change, or the
+// name of the job for changes made by the service itself.
+type CertEvent struct {
+	UUID      string          `json:"uuid"`
+	CertUUID  string          `json:"cert_uuid"`
+	Actor     string          `json:"actor"`
+	Event     string          `json:"event"`
+	OldValue  json.RawMessage `json:"old_value"`
+	NewValue  json.RawMessage `json:"new_value"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"time"
+)
+
+// Roles of the members of organizations and teams, from the least to the most
+// privileged. Viewers read the certificates of a team, operators also add and
+// manage them, admins also manage the members and the teams, and owners also
+// manage the other owners of an organization.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+	RoleOwner    = "owner"
+)
+
+// Roles are all roles, ordered from the least to the most privileged.
+var Roles = []string{RoleViewer, RoleOperator, RoleAdmin, RoleOwner}
+
+// RoleAtLeast returns whether `role` is known and has the privileges of
+// `minRole`.
+func RoleAtLeast(role, minRole string) bool {
+	rank := func(role string) int {
+		for i, r := range Roles {
+			if r == role {
+				return i
+			}
+		}
+		return -1
+	}
+	return rank(role) >= 0 && rank(role) >= rank(minRole)
+}
+
+// Org represents the database schema of organizations, which group users and
+// their teams.
+type Org struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// Team represents the database schema of the teams of an organization.
+// Certificates that belong to a team outlive the membership of the user who
+// added them.
+type Team struct {
+	UUID      string    `json:"uuid"`
+	OrgUUID   string    `json:"org_uuid"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// Member represents the database schema of the membership of a user in an
+// organization or a team. The admins and owners of an organization have
+// their role in all of its teams.
+type Member struct {
+	UserUUID  string    `json:"user_uuid"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// OrgDatabase is the interface that wraps all database operations related to
+// organizations and teams.
+type OrgDatabase interface {
+	AddOrg(org *Org, principal *Principal) error
+	GetOrg(uuid string, principal *Principal) (*Org, error)
+	SetOrgMember(orgUUID string, member *Member, principal *Principal) error
+	RemoveOrgMember(orgUUID, userUUID string, principal *Principal) error
+	GetOrgMembers(orgUUID string, principal *Principal) ([]*Member, error)
+	AddTeam(team *Team, principal *Principal) error
+	GetTeams(orgUUID string, principal *Principal) ([]*Team, error)
+	SetTeamMember(teamUUID string, member *Member, principal *Principal) error
+	RemoveTeamMember(teamUUID, userUUID string, principal *Principal) error
+	GetTeamMembers(teamUUID string, principal *Principal) ([]*Member, error)
+}
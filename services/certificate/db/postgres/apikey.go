@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"certificate/auth"
+	"certificate/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"sort"
+	"time"
+)
+
+// apiKeyColumns are the columns of api_keys scanned by scanAPIKey, the hash
+// of the key is never read back.
+const apiKeyColumns = `uuid, user_uuid, name, prefix, scopes, expires_at,
+	last_used_at, revoked_at, created_at`
+
+// scanAPIKey scans a row of `apiKeyColumns` into a new db.APIKey.
+func scanAPIKey(row scanner) (*db.APIKey, error) {
+	key := &db.APIKey{}
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&key.UUID, &key.UserUUID, &key.Name, &key.Prefix,
+		pq.Array(&key.Scopes), &expiresAt, &lastUsedAt, &revokedAt,
+		&key.CreatedAt); err != nil {
+		return nil, err
+	}
+	key.ExpiresAt, key.LastUsedAt, key.RevokedAt = expiresAt.Time, lastUsedAt.Time, revokedAt.Time
+	if len(key.Scopes) == 0 {
+		key.Scopes = nil
+	}
+	return key, nil
+}
+
+// checkScopes returns the sorted unique `scopes` of a new API key created by
+// `principal`, which cannot hand out scopes it does not have. A principal
+// limited to some scopes creates keys with the same scopes by default. It
+// returns a *db.ValidationError if a scope is unknown or not allowed.
+func checkScopes(scopes []string, principal *db.Principal) ([]string, error) {
+	if len(scopes) == 0 {
+		return principal.Scopes, nil
+	}
+	unique := map[string]bool{}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range db.Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return nil, &db.ValidationError{Field: "scopes", Err: fmt.Errorf("unknown scope %q", scope)}
+		}
+		if !principal.HasScope(scope) {
+			return nil, &db.ValidationError{Field: "scopes", Err: fmt.Errorf("scope %q is not allowed", scope)}
+		}
+		unique[scope] = true
+	}
+	checked := make([]string, 0, len(unique))
+	for scope := range unique {
+		checked = append(checked, scope)
+	}
+	sort.Strings(checked)
+	return checked, nil
+}
+
+// AddAPIKey generates a new API key for `key.UserUUID`, with name `key.Name`,
+// optional scopes `key.Scopes` and optional expiry `key.ExpiresAt`, and fills
+// `key` with the generated `Key`, which is only stored hashed, and the other
+// db-generated fields. It errors out if the user does not exist or is not
+// active, returns a *db.ValidationError if a field is rejected, and
+// db.ErrForbidden if `principal` may not access the user.
+func (pg *Postgres) AddAPIKey(key *db.APIKey, principal *db.Principal) error {
+	if err := authorize(principal, key.UserUUID); err != nil {
+		return err
+	}
+	if key.Name == "" {
+		return &db.ValidationError{Field: "name", Err: errors.New("must not be empty")}
+	}
+	if !key.ExpiresAt.IsZero() && !key.ExpiresAt.After(time.Now()) {
+		return &db.ValidationError{Field: "expires_at", Err: errors.New("must be in the future")}
+	}
+	scopes, err := checkScopes(key.Scopes, principal)
+	if err != nil {
+		return err
+	}
+	secret, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+	var expiresAt any
+	if !key.ExpiresAt.IsZero() {
+		expiresAt = key.ExpiresAt.UTC()
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := checkUser(tx, key.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+INSERT INTO api_keys (user_uuid, name, prefix, key_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING ` + apiKeyColumns
+	added, err := scanAPIKey(tx.QueryRow(query, key.UserUUID, key.Name, prefix,
+		auth.HashAPIKey(secret), pq.Array(append([]string{}, scopes...)), expiresAt))
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to insert API key: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	*key = *added
+	key.Key = secret
+	return nil
+}
+
+// GetAPIKeys returns the API keys of `userUUID`, revoked ones included and
+// oldest first, without their secrets. It errors out if the user does not
+// exist or is not active, and returns db.ErrForbidden if `principal` may not
+// access the user.
+func (pg *Postgres) GetAPIKeys(userUUID string, principal *db.Principal) ([]*db.APIKey, error) {
+	if err := authorize(principal, userUUID); err != nil {
+		return nil, err
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := checkUser(tx, userUUID); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+SELECT ` + apiKeyColumns + `
+FROM api_keys
+WHERE user_uuid = $1
+ORDER BY created_at`
+	rows, err := tx.Query(query, userUUID)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to query: %w", err), tx.Rollback())
+	}
+	keys := []*db.APIKey{}
+	for rows.Next() {
+		if key, errScan := scanAPIKey(rows); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose), tx.Rollback())
+	}
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes API key `uuid` of `userUUID` for good. It returns a
+// *db.ValidationError if the key does not exist or is already revoked, and
+// db.ErrForbidden if `principal` may not access the user.
+func (pg *Postgres) RevokeAPIKey(uuid, userUUID string, principal *db.Principal) error {
+	if err := authorize(principal, userUUID); err != nil {
+		return err
+	}
+
+	query := `
+UPDATE api_keys
+SET revoked_at = $3
+WHERE uuid = $1 AND user_uuid = $2 AND revoked_at IS NULL`
+	res, err := pg.Exec(query, uuid, userUUID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if count != 1 {
+		return &db.ValidationError{Field: "uuid", Err: errors.New("API key does not exist or is already revoked")}
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the principal of API key `key`, limited to its
+// scopes, and records that the key was used. It returns db.ErrUnauthorized if
+// the key does not exist, is revoked or expired, or its user is not active.
+func (pg *Postgres) AuthenticateAPIKey(key string) (*db.Principal, error) {
+	principal := &db.Principal{}
+	query := `
+UPDATE api_keys k
+SET last_used_at = $2
+FROM users u
+WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+	AND (k.expires_at IS NULL OR k.expires_at > $2)
+	AND u.uuid = k.user_uuid AND u.active
+RETURNING u.uuid, u.admin, k.scopes`
+	if err := pg.QueryRow(query, auth.HashAPIKey(key), time.Now().UTC()).
+		Scan(&principal.UUID, &principal.Admin, pq.Array(&principal.Scopes)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("API key does not exist, is revoked or expired, or user is not active: %w", db.ErrUnauthorized)
+		}
+		return nil, fmt.Errorf("failed to query for API key: %w", err)
+	}
+	if len(principal.Scopes) == 0 {
+		principal.Scopes = nil
+	}
+	return principal, nil
+}
@@ -0,0 +1,237 @@
+package postgres_test
+
+import (
+	"certificate/auth"
+	"certificate/db"
+	"database/sql/driver"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mockAPIKey = &db.APIKey{
+	UUID:      "mock_api_key_uuid",
+	UserUUID:  mockUser.UUID,
+	Name:      "ci",
+	Prefix:    "cert_0123abcd",
+	Scopes:    []string{db.ScopeCertRead, db.ScopeCertWrite},
+	ExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+var apiKeyColumns = []string{"uuid", "user_uuid", "name", "prefix", "scopes",
+	"expires_at", "last_used_at", "revoked_at", "created_at"}
+
+// apiKeyValues returns the values of the `apiKeyColumns` of `key`.
+func apiKeyValues(key *db.APIKey) []driver.Value {
+	var lastUsedAt, revokedAt driver.Value
+	if !key.LastUsedAt.IsZero() {
+		lastUsedAt = key.LastUsedAt
+	}
+	if !key.RevokedAt.IsZero() {
+		revokedAt = key.RevokedAt
+	}
+	return []driver.Value{key.UUID, key.UserUUID, key.Name, key.Prefix,
+		"{" + strings.Join(key.Scopes, ",") + "}", key.ExpiresAt, lastUsedAt,
+		revokedAt, key.CreatedAt}
+}
+
+func TestPostgres_AddAPIKey(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		key := &db.APIKey{
+			UserUUID:  mockUser.UUID,
+			Name:      mockAPIKey.Name,
+			Scopes:    []string{db.ScopeCertWrite, db.ScopeCertRead, db.ScopeCertWrite},
+			ExpiresAt: mockAPIKey.ExpiresAt,
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(apiKeyColumns).
+			AddRow(apiKeyValues(mockAPIKey)...)
+		mock.ExpectQuery(`
+^INSERT INTO api_keys (.+)
+VALUES (.+)
+RETURNING (.+)`).
+			WithArgs(mockUser.UUID, mockAPIKey.Name, sqlmock.AnyArg(),
+				sqlmock.AnyArg(), "{\"cert:read\",\"cert:write\"}", mockAPIKey.ExpiresAt).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddAPIKey(key, mockPrincipal))
+		assert.True(t, auth.IsAPIKey(key.Key))
+		secret := key.Key
+		key.Key = ""
+		assert.Equal(t, mockAPIKey, key)
+		assert.NotEmpty(t, secret)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_forbidden", func(t *testing.T) {
+		key := &db.APIKey{UserUUID: "other_user_uuid", Name: mockAPIKey.Name}
+		assert.ErrorIs(t, pg.AddAPIKey(key, mockPrincipal), db.ErrForbidden)
+		assert.Empty(t, key.Key)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_unknown_scope", func(t *testing.T) {
+		key := &db.APIKey{UserUUID: mockUser.UUID, Name: mockAPIKey.Name, Scopes: []string{"ca:write"}}
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddAPIKey(key, mockPrincipal), &validationErr)
+		assert.Equal(t, "scopes", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_scope_not_allowed", func(t *testing.T) {
+		limited := &db.Principal{UUID: mockUser.UUID, Scopes: []string{db.ScopeCertRead}}
+		key := &db.APIKey{UserUUID: mockUser.UUID, Name: mockAPIKey.Name, Scopes: []string{db.ScopeUserWrite}}
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddAPIKey(key, limited), &validationErr)
+		assert.Equal(t, "scopes", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_expired", func(t *testing.T) {
+		key := &db.APIKey{UserUUID: mockUser.UUID, Name: mockAPIKey.Name, ExpiresAt: time.Now().Add(-time.Hour)}
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddAPIKey(key, mockPrincipal), &validationErr)
+		assert.Equal(t, "expires_at", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetAPIKeys(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		revoked := *mockAPIKey
+		revoked.UUID = "mock_revoked_api_key_uuid"
+		revoked.Scopes = nil
+		revoked.LastUsedAt = time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+		revoked.RevokedAt = time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(apiKeyColumns).
+			AddRow(apiKeyValues(mockAPIKey)...).
+			AddRow(apiKeyValues(&revoked)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM api_keys
+WHERE user_uuid = \$1
+ORDER BY created_at`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		keys, err := pg.GetAPIKeys(mockUser.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.APIKey{mockAPIKey, &revoked}, keys)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_forbidden", func(t *testing.T) {
+		keys, err := pg.GetAPIKeys("other_user_uuid", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, keys)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_RevokeAPIKey(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectExec(`
+^UPDATE api_keys
+SET revoked_at = \$3
+WHERE uuid = \$1 AND user_uuid = \$2 AND revoked_at IS NULL`).
+			WithArgs(mockAPIKey.UUID, mockUser.UUID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, pg.RevokeAPIKey(mockAPIKey.UUID, mockUser.UUID, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_already_revoked", func(t *testing.T) {
+		mock.ExpectExec(`
+^UPDATE api_keys
+SET (.+)`).
+			WithArgs(mockAPIKey.UUID, mockUser.UUID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.RevokeAPIKey(mockAPIKey.UUID, mockUser.UUID, mockPrincipal), &validationErr)
+		assert.Equal(t, "uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_AuthenticateAPIKey(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+	key, _, err := auth.GenerateAPIKey()
+	assert.Nil(t, err)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"uuid", "admin", "scopes"}).
+			AddRow(mockUser.UUID, false, "{cert:read}")
+		mock.ExpectQuery(`
+^UPDATE api_keys k
+SET last_used_at = \$2
+FROM users u
+WHERE k.key_hash = \$1 (.+)
+RETURNING u.uuid, u.admin, k.scopes`).
+			WithArgs(auth.HashAPIKey(key), sqlmock.AnyArg()).
+			WillReturnRows(rows)
+
+		principal, err := pg.AuthenticateAPIKey(key)
+		assert.Nil(t, err)
+		assert.Equal(t, &db.Principal{UUID: mockUser.UUID, Scopes: []string{db.ScopeCertRead}}, principal)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_all_scopes", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"uuid", "admin", "scopes"}).
+			AddRow(mockUser.UUID, false, "{}")
+		mock.ExpectQuery(`
+^UPDATE api_keys k
+SET (.+)`).
+			WithArgs(auth.HashAPIKey(key), sqlmock.AnyArg()).
+			WillReturnRows(rows)
+
+		principal, err := pg.AuthenticateAPIKey(key)
+		assert.Nil(t, err)
+		assert.Equal(t, mockPrincipal, principal)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_revoked_or_expired", func(t *testing.T) {
+		mock.ExpectQuery(`
+^UPDATE api_keys k
+SET (.+)`).
+			WithArgs(auth.HashAPIKey(key), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid", "admin", "scopes"}))
+
+		principal, err := pg.AuthenticateAPIKey(key)
+		assert.True(t, errors.Is(err, db.ErrUnauthorized))
+		assert.Nil(t, principal)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
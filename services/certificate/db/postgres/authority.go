@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"certificate/db"
+	"certificate/encrypter"
+	"certificate/pki"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// AddAuthority adds `authority` to the database and fills its db-generated
+// fields like `UUID` and `CreatedAt` as well as the fields parsed from
+// `authority.Body`. It errors out if `authority.Body` is not a CA certificate,
+// `authority.PrivateKey` is not its private key, or `authority.ParentUUID` is
+// set but did not sign it. The private key is encrypted with pg.KeyEncrypter
+// before it is stored.
+func (pg *Postgres) AddAuthority(authority *db.Authority) error {
+	cert, err := pki.ParseCertificate(authority.Body)
+	if err != nil {
+		return &db.ValidationError{Field: "body", Err: err}
+	}
+	if !cert.IsCA {
+		return &db.ValidationError{Field: "body", Err: errors.New("certificate is not a CA certificate")}
+	}
+	key, err := pki.ParsePrivateKey(authority.PrivateKey)
+	if err != nil {
+		return &db.ValidationError{Field: "private_key", Err: err}
+	}
+	if err := pki.CheckKeyPair(cert, key); err != nil {
+		return &db.ValidationError{Field: "private_key", Err: err}
+	}
+	authority.Body = pki.EncodeCertificate(cert)
+	authority.Subject = cert.Subject.String()
+	authority.NotAfter = cert.NotAfter.UTC()
+	encrypted, err := pg.encryptPrivateKey(authority.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if authority.ParentUUID != "" {
+		if err := checkAuthority(tx, authority.ParentUUID, cert); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+	}
+
+	// insert authority and fill the auto generated fields
+	query := `
+INSERT INTO authorities (parent_uuid, name, private_key, data_key,
+	master_key_id, body, subject, subject_key_id, not_after)
+VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+RETURNING uuid, created_at`
+	if err := tx.QueryRow(query, authority.ParentUUID, authority.Name,
+		encrypted.Ciphertext, encrypted.WrappedDataKey, encrypted.MasterKeyID,
+		authority.Body, authority.Subject, pki.KeyID(cert.SubjectKeyId),
+		authority.NotAfter).
+		Scan(&authority.UUID, &authority.CreatedAt); err != nil {
+		return errors.Join(fmt.Errorf("failed to insert authority: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetAuthority returns the authority with UUID `uuid` including its decrypted
+// private key. It returns a *db.ValidationError if the authority does not
+// exist.
+func (pg *Postgres) GetAuthority(uuid string) (*db.Authority, error) {
+	authority := &db.Authority{}
+	encrypted := &encrypter.EncryptedKey{}
+	query := `
+SELECT uuid, COALESCE(parent_uuid::text, ''), name, private_key, data_key,
+	master_key_id, body, subject, not_after, created_at
+FROM authorities
+WHERE uuid = $1`
+	if err := pg.QueryRow(query, uuid).
+		Scan(&authority.UUID, &authority.ParentUUID, &authority.Name,
+			&encrypted.Ciphertext, &encrypted.WrappedDataKey, &encrypted.MasterKeyID,
+			&authority.Body, &authority.Subject, &authority.NotAfter,
+			&authority.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &db.ValidationError{Field: "authority_uuid", Err: errors.New("authority does not exist")}
+		}
+		return nil, fmt.Errorf("failed to query for authority: %w", err)
+	}
+	privateKey, err := pg.decryptPrivateKey(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	authority.PrivateKey = privateKey
+	return authority, nil
+}
+
+// GetAuthorities returns all authorities without their private keys.
+func (pg *Postgres) GetAuthorities() ([]*db.Authority, error) {
+	query := `
+SELECT uuid, COALESCE(parent_uuid::text, ''), name, body, subject, not_after,
+	created_at
+FROM authorities`
+	rows, err := pg.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	var authorities []*db.Authority
+	for rows.Next() {
+		authority := &db.Authority{}
+		if errScan := rows.Scan(&authority.UUID, &authority.ParentUUID,
+			&authority.Name, &authority.Body, &authority.Subject,
+			&authority.NotAfter, &authority.CreatedAt); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			authorities = append(authorities, authority)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	return authorities, err
+}
@@ -0,0 +1,215 @@
+package postgres_test
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var mockCA, mockAuthority = newMockAuthority()
+
+// mockIssuedCertBody is a certificate for "dog.example.com" issued by
+// `mockCA`.
+var mockIssuedCertBody = newMockIssuedCertBody()
+
+// newMockAuthority returns a new root CA along with its database record.
+func newMockAuthority() (*ca.CA, *db.Authority) {
+	root, err := ca.NewRoot(pkix.Name{CommonName: "Mock Root CA"}, 24*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+	privateKey, err := pki.EncodePrivateKey(root.Key)
+	if err != nil {
+		panic(err)
+	}
+	return root, &db.Authority{
+		UUID:       "mock_authority_uuid",
+		Name:       "mock_authority",
+		PrivateKey: privateKey,
+		Body:       pki.EncodeCertificate(root.Cert),
+		Subject:    "CN=Mock Root CA",
+		NotAfter:   root.Cert.NotAfter.UTC(),
+		CreatedAt:  time.Now(),
+	}
+}
+
+func newMockIssuedCertBody() string {
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "dog.example.com"},
+		DNSNames: []string{"dog.example.com"},
+	}, pkitest.NewKey())
+	if err != nil {
+		panic(err)
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := mockCA.Sign(csr, ca.DefaultProfiles["server"])
+	if err != nil {
+		panic(err)
+	}
+	return pki.EncodeCertificate(cert)
+}
+
+func TestPostgres_AddAuthority(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		authority := &db.Authority{
+			Name:       mockAuthority.Name,
+			PrivateKey: mockAuthority.PrivateKey,
+			Body:       mockAuthority.Body,
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid", "created_at"}).
+			AddRow(mockAuthority.UUID, mockAuthority.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO authorities (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WithArgs("", mockAuthority.Name, []byte(mockAuthority.PrivateKey),
+				mockDataKey, mockMasterKeyID, mockAuthority.Body,
+				mockAuthority.Subject, pki.KeyID(mockCA.Cert.SubjectKeyId),
+				mockAuthority.NotAfter).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddAuthority(authority))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, mockAuthority, authority)
+	})
+
+	t.Run("happy_path_intermediate", func(t *testing.T) {
+		intermediate, err := mockCA.NewIntermediate(pkix.Name{CommonName: "Mock Intermediate CA"}, time.Hour)
+		assert.Nil(t, err)
+		privateKey, err := pki.EncodePrivateKey(intermediate.Key)
+		assert.Nil(t, err)
+		authority := &db.Authority{
+			ParentUUID: mockAuthority.UUID,
+			Name:       "mock_intermediate",
+			PrivateKey: privateKey,
+			Body:       pki.EncodeCertificate(intermediate.Cert),
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"body"}).
+			AddRow(mockAuthority.Body)
+		mock.ExpectQuery(`
+^SELECT body FROM authorities
+WHERE (.+)*`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "created_at"}).
+			AddRow("mock_intermediate_uuid", time.Now())
+		mock.ExpectQuery(`
+^INSERT INTO authorities (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WithArgs(mockAuthority.UUID, authority.Name, []byte(privateKey),
+				mockDataKey, mockMasterKeyID, authority.Body,
+				"CN=Mock Intermediate CA", pki.KeyID(intermediate.Cert.SubjectKeyId),
+				intermediate.Cert.NotAfter.UTC()).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddAuthority(authority))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, "mock_intermediate_uuid", authority.UUID)
+	})
+
+	t.Run("error_not_ca", func(t *testing.T) {
+		authority := &db.Authority{
+			Name:       mockAuthority.Name,
+			PrivateKey: mockCert0.PrivateKey,
+			Body:       mockCert0.Body,
+		}
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddAuthority(authority), &validationErr)
+		assert.Equal(t, "body", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_mismatched_private_key", func(t *testing.T) {
+		authority := &db.Authority{
+			Name:       mockAuthority.Name,
+			PrivateKey: mockCert0.PrivateKey,
+			Body:       mockAuthority.Body,
+		}
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddAuthority(authority), &validationErr)
+		assert.Equal(t, "private_key", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetAuthority(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"uuid", "parent_uuid", "name",
+			"private_key", "data_key", "master_key_id", "body", "subject",
+			"not_after", "created_at"}).
+			AddRow(mockAuthority.UUID, "", mockAuthority.Name,
+				[]byte(mockAuthority.PrivateKey), mockDataKey, mockMasterKeyID,
+				mockAuthority.Body, mockAuthority.Subject, mockAuthority.NotAfter,
+				mockAuthority.CreatedAt)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM authorities
+WHERE (.+)*`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(rows)
+
+		authority, err := pg.GetAuthority(mockAuthority.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, mockAuthority, authority)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_no_rows_returned", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM authorities
+WHERE (.+)*`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+
+		authority, err := pg.GetAuthority(mockAuthority.UUID)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Nil(t, authority)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetAuthorities(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	rows := sqlmock.NewRows([]string{"uuid", "parent_uuid", "name", "body",
+		"subject", "not_after", "created_at"}).
+		AddRow(mockAuthority.UUID, "", mockAuthority.Name, mockAuthority.Body,
+			mockAuthority.Subject, mockAuthority.NotAfter, mockAuthority.CreatedAt)
+	mock.ExpectQuery(`
+^SELECT (.+)
+FROM authorities`).
+		WillReturnRows(rows)
+
+	authorities, err := pg.GetAuthorities()
+	assert.Nil(t, err)
+	public := *mockAuthority
+	public.PrivateKey = ""
+	assert.Equal(t, []*db.Authority{&public}, authorities)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -2,12 +2,82 @@ package postgres
 
 import (
 	"certificate/db"
+	"certificate/encrypter"
+	"certificate/pki"
 	"context"
+	"crypto/x509"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/lib/pq"
 )
 
+// certColumns are the columns of the certificates table read by scanCert.
+// Columns that are NULL for pending certificates are coalesced into empty
+// strings, except timestamps. Revocation fields are NULL unless the
+// certificate is revoked.
+const certColumns = `uuid, user_uuid, COALESCE(team_uuid::text, ''),
+	COALESCE(authority_uuid::text, ''),
+	COALESCE(replaces_uuid::text, ''), status, COALESCE(csr, ''), COALESCE(body, ''), subject, COALESCE(issuer, ''),
+	COALESCE(serial_number, ''), sans, key_algorithm, not_before, not_after,
+	COALESCE(fingerprint, ''), revoked_at, COALESCE(revocation_reason, 0),
+	labels, active, created_at`
+
+// The unique indexes that keep users from adding the same certificate twice,
+// and internal CAs from issuing a serial number twice.
+const (
+	userFingerprintIndex  = "user_fingerprint_idx"
+	userIssuerSerialIndex = "user_issuer_serial_idx"
+	serialNumberIndex     = "serial_number_idx"
+)
+
+// isUniqueViolation reports whether `err` is a violation of one of the unique
+// indexes `indexes`.
+func isUniqueViolation(err error, indexes ...string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return false
+	}
+	for _, index := range indexes {
+		if pqErr.Constraint == index {
+			return true
+		}
+	}
+	return false
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanCert scans a row of `certColumns` into a new db.Cert, followed by
+// `extra` columns if the row has any.
+func scanCert(row scanner, extra ...any) (*db.Cert, error) {
+	cert := &db.Cert{}
+	var notBefore, notAfter, revokedAt sql.NullTime
+	var labels []byte
+	dest := append([]any{&cert.UUID, &cert.UserUUID, &cert.TeamUUID, &cert.AuthorityUUID,
+		&cert.ReplacesUUID, &cert.Status, &cert.CSR, &cert.Body, &cert.Subject,
+		&cert.Issuer, &cert.SerialNumber, pq.Array(&cert.SANs),
+		&cert.KeyAlgorithm, &notBefore, &notAfter, &cert.Fingerprint,
+		&revokedAt, &cert.RevocationReason, &labels, &cert.Active,
+		&cert.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	cert.NotBefore, cert.NotAfter = notBefore.Time, notAfter.Time
+	cert.RevokedAt = revokedAt.Time
+	if err := json.Unmarshal(labels, &cert.Labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
+	}
+	if len(cert.Labels) == 0 {
+		cert.Labels = nil
+	}
+	return cert, nil
+}
+
 // checkUser checks if userUUID is valid and active
 func checkUser(tx *sql.Tx, userUUID string) error {
 	query := `
@@ -23,27 +93,250 @@ WHERE uuid = $1 AND active`
 	return nil
 }
 
-// AddCert adds cert to the database if `cert.UserUUID` exists and is active,
-// and fills `cert` with db-generated fields like `UUID` and `CreatedAt`.
-func (pg *Postgres) AddCert(cert *db.Cert) error {
+// authorize checks that `principal` may access the records of `userUUID`, it
+// returns db.ErrForbidden otherwise.
+func authorize(principal *db.Principal, userUUID string) error {
+	if !principal.CanAccess(userUUID) {
+		return fmt.Errorf("not allowed to access the records of user %s: %w", userUUID, db.ErrForbidden)
+	}
+	return nil
+}
+
+// authorizeCert checks that `principal` may manage certificate `uuid`
+// belonging to `userUUID`. Certificates of a team are managed by the
+// operators of the team only, so that members who left the team lose control
+// over the certificates they added, and other certificates by `userUUID`,
+// which must be active. It returns a *db.ValidationError if the certificate
+// does not exist, and db.ErrForbidden if `principal` may not manage it.
+func authorizeCert(tx *sql.Tx, principal *db.Principal, uuid, userUUID string) error {
+	teamUUID, err := certTeam(tx, uuid, userUUID)
+	if err != nil {
+		return err
+	}
+	if teamUUID != "" {
+		_, _, err := authorizeTeam(tx, principal, teamUUID, db.RoleOperator)
+		return err
+	}
+	if err := authorize(principal, userUUID); err != nil {
+		return err
+	}
+	return checkUser(tx, userUUID)
+}
+
+// authorizeCertRead checks that `principal` may read certificate `uuid`
+// belonging to `userUUID`. Certificates of a team are read by all members of
+// the team, other certificates by `userUUID`, also after it was deactivated.
+// It returns a *db.ValidationError if the certificate does not exist, and
+// db.ErrForbidden if `principal` may not read it.
+func authorizeCertRead(tx *sql.Tx, principal *db.Principal, uuid, userUUID string) error {
+	teamUUID, err := certTeam(tx, uuid, userUUID)
+	if err != nil {
+		return err
+	}
+	if teamUUID != "" {
+		_, _, err := authorizeTeam(tx, principal, teamUUID, db.RoleViewer)
+		return err
+	}
+	return authorize(principal, userUUID)
+}
+
+// certTeam returns the team of certificate `uuid` belonging to `userUUID`,
+// or an empty string if it has none. It returns a *db.ValidationError if the
+// certificate does not exist.
+func certTeam(tx *sql.Tx, uuid, userUUID string) (string, error) {
+	query := `
+SELECT COALESCE(team_uuid::text, '') FROM certificates
+WHERE uuid = $1 AND user_uuid = $2`
+	var teamUUID string
+	if err := tx.QueryRow(query, uuid, userUUID).Scan(&teamUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", &db.ValidationError{Field: "uuid", Err: errors.New("certificate does not exist")}
+		}
+		return "", fmt.Errorf("failed to query for certificate: %w", err)
+	}
+	return teamUUID, nil
+}
+
+// checkUserPassword checks if userUUID is active and `password` is its
+// password, it returns db.ErrUnauthorized otherwise.
+func checkUserPassword(tx *sql.Tx, userUUID, password string) error {
+	query := `
+SELECT uuid FROM users
+WHERE uuid = $1 AND active AND password = crypt($2, password)`
+	err := tx.QueryRow(query, userUUID, password).Scan(&userUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("wrong password or user is not active: %w", db.ErrUnauthorized)
+		}
+		return fmt.Errorf("failed to query for user_uuid: %w", err)
+	}
+	return nil
+}
+
+// parseCert decodes `cert.Body` as an X.509 certificate, checks that it
+// belongs together with `cert.PrivateKey` if there is one, normalizes it to
+// PEM and fills the fields of `cert` that are derived from it. It returns a
+// *db.ValidationError if either of them is rejected.
+func parseCert(cert *db.Cert) (*x509.Certificate, error) {
+	x509Cert, err := pki.ParseCertificate(cert.Body)
+	if err != nil {
+		return nil, &db.ValidationError{Field: "body", Err: err}
+	}
+	if cert.PrivateKey != "" {
+		key, err := pki.ParsePrivateKey(cert.PrivateKey)
+		if err != nil {
+			return nil, &db.ValidationError{Field: "private_key", Err: err}
+		}
+		if err := pki.CheckKeyPair(x509Cert, key); err != nil {
+			return nil, &db.ValidationError{Field: "private_key", Err: err}
+		}
+	}
+	cert.Body = pki.EncodeCertificate(x509Cert)
+	cert.Subject = x509Cert.Subject.String()
+	cert.Issuer = x509Cert.Issuer.String()
+	cert.SerialNumber = pki.SerialNumber(x509Cert)
+	cert.SANs = pki.SANs(x509Cert)
+	cert.KeyAlgorithm = pki.KeyAlgorithm(x509Cert)
+	cert.NotBefore = x509Cert.NotBefore.UTC()
+	cert.NotAfter = x509Cert.NotAfter.UTC()
+	cert.Fingerprint = pki.Fingerprint(x509Cert)
+	return x509Cert, nil
+}
+
+// checkAuthority checks that `cert` was signed by the authority with UUID
+// `authorityUUID`.
+func checkAuthority(tx *sql.Tx, authorityUUID string, cert *x509.Certificate) error {
+	var body string
+	query := `
+SELECT body FROM authorities
+WHERE uuid = $1`
+	if err := tx.QueryRow(query, authorityUUID).Scan(&body); err != nil {
+		return fmt.Errorf("failed to query for authority: %w", err)
+	}
+	authorityCert, err := pki.ParseCertificate(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse authority certificate: %w", err)
+	}
+	if err := cert.CheckSignatureFrom(authorityCert); err != nil {
+		return &db.ValidationError{Field: "authority_uuid", Err: err}
+	}
+	return nil
+}
+
+// checkUserCert checks that the certificate with UUID `uuid` belongs to
+// `userUUID`, it returns a *db.ValidationError for `field` otherwise.
+func checkUserCert(tx *sql.Tx, field, uuid, userUUID string) error {
+	query := `
+SELECT uuid FROM certificates
+WHERE uuid = $1 AND user_uuid = $2`
+	err := tx.QueryRow(query, uuid, userUUID).Scan(&uuid)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &db.ValidationError{Field: field, Err: errors.New("certificate does not exist")}
+		}
+		return fmt.Errorf("failed to query for certificate: %w", err)
+	}
+	return nil
+}
+
+// AddCert adds cert to the database if `cert.UserUUID` exists and is active or
+// `cert.TeamUUID` is set, and fills `cert` with db-generated fields like
+// `UUID` and `CreatedAt` as well as the fields parsed from `cert.Body`. It
+// errors out if `cert.Body` is not a valid X.509 certificate,
+// `cert.PrivateKey` is set but is not its private key, `cert.AuthorityUUID` is
+// set but did not sign it, or `cert.ReplacesUUID` is set but is not a
+// certificate of the same user. The renewal policy of the replaced certificate
+// moves to `cert`, and the intermediates in `cert.Chain`, which must be CA
+// certificates with a subject key identifier, are added as untrusted chain
+// certificates. The private key is encrypted with pg.KeyEncrypter before it is
+// stored. It returns db.ErrForbidden if `principal` may not access
+// `cert.UserUUID`, or `cert.TeamUUID` is set and `principal` is not an
+// operator of the team. The addition is recorded in the certificate history as
+// done by `principal`.
+func (pg *Postgres) AddCert(cert *db.Cert, principal *db.Principal) error {
+	return pg.AddCerts([]*db.Cert{cert}, principal)
+}
+
+// AddCerts adds `certs` like AddCert in a single transaction, either all of
+// them are added or none is.
+func (pg *Postgres) AddCerts(certs []*db.Cert, principal *db.Principal) error {
+	return pg.addCerts(certs, principal, nil)
+}
+
+// addCerts adds `certs` like AddCerts, and calls `then` if it is not nil in
+// the same transaction once they are added.
+func (pg *Postgres) addCerts(certs []*db.Cert, principal *db.Principal, then func(tx *sql.Tx) error) error {
+	x509Certs := make([]*x509.Certificate, len(certs))
+	encryptedKeys := make([]*encrypter.EncryptedKey, len(certs))
+	chainCerts := make([][]*db.ChainCert, len(certs))
+	for i, cert := range certs {
+		if err := authorize(principal, cert.UserUUID); err != nil {
+			return err
+		}
+		x509Cert, err := parseCert(cert)
+		if err != nil {
+			return err
+		}
+		x509Certs[i] = x509Cert
+		if err := checkLabels(cert.Labels); err != nil {
+			return err
+		}
+		for _, body := range cert.Chain {
+			chainCert := &db.ChainCert{Body: body}
+			if err := parseChainCert(chainCert, "chain"); err != nil {
+				return err
+			}
+			chainCerts[i] = append(chainCerts[i], chainCert)
+		}
+
+		// encrypt the private key if there is one, certificates issued from a
+		// CSR do not come with one
+		if cert.PrivateKey != "" {
+			if encryptedKeys[i], err = pg.encryptPrivateKey(cert.PrivateKey); err != nil {
+				return err
+			}
+		}
+	}
+
 	// use transaction for atomicity
 	tx, err := pg.BeginTx(context.Background(), nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin tx: %w", err)
 	}
 
-	if err := checkUser(tx, cert.UserUUID); err != nil {
-		return errors.Join(err, tx.Rollback())
+	checkedUsers, checkedTeams := map[string]bool{}, map[string]bool{}
+	for i, cert := range certs {
+		// certificates of a team belong to the team, so that e.g. they are
+		// still renewed after the user who added them was deactivated
+		if cert.TeamUUID == "" && !checkedUsers[cert.UserUUID] {
+			if err := checkUser(tx, cert.UserUUID); err != nil {
+				return errors.Join(err, tx.Rollback())
+			}
+			checkedUsers[cert.UserUUID] = true
+		}
+		if cert.TeamUUID != "" && !checkedTeams[cert.TeamUUID] {
+			if _, _, err := authorizeTeam(tx, principal, cert.TeamUUID, db.RoleOperator); err != nil {
+				return errors.Join(err, tx.Rollback())
+			}
+			checkedTeams[cert.TeamUUID] = true
+		}
+		if err := insertCert(tx, cert, x509Certs[i], encryptedKeys[i]); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+		if err := addCertEvent(tx, cert.UUID, principal.UUID, db.CertEventAdded, nil,
+			map[string]any{"status": cert.Status, "active": cert.Active, "fingerprint": cert.Fingerprint}); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+		for _, chainCert := range chainCerts[i] {
+			if err := insertChainCert(tx, chainCert); err != nil {
+				return errors.Join(err, tx.Rollback())
+			}
+		}
 	}
-
-	// insert cert and fills the auto generated fields in Cert
-	query := `
-INSERT INTO certificates (user_uuid, private_key, body)
-VALUES ($1, $2, $3)
-RETURNING uuid, active, created_at`
-	if err := tx.QueryRow(query, cert.UserUUID, cert.PrivateKey, cert.Body).
-		Scan(&cert.UUID, &cert.Active, &cert.CreatedAt); err != nil {
-		return errors.Join(fmt.Errorf("failed to insert certificate: %w", err), tx.Rollback())
+	if then != nil {
+		if err := then(tx); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
 	}
 
 	// commit the transaction
@@ -53,88 +346,255 @@ RETURNING uuid, active, created_at`
 	return nil
 }
 
-// GetCerts returns all active certificates belonging to `userUUID`, it errors
-// out if the user does not exist or is not active.
-func (pg *Postgres) GetCerts(userUUID string) ([]*db.Cert, error) {
+// CheckCertOwners checks that `principal` may add certificates belonging to
+// `userUUID` and, if it is not empty, team `teamUUID`, like AddCert does, so
+// that certificates are only issued for owners they can be added to. It
+// errors out if the user does not exist or is not active, and returns
+// db.ErrForbidden if `principal` may not access `userUUID` or is not an
+// operator of `teamUUID`.
+func (pg *Postgres) CheckCertOwners(userUUID, teamUUID string, principal *db.Principal) error {
+	if err := authorize(principal, userUUID); err != nil {
+		return err
+	}
+
 	// use transaction for atomicity
 	tx, err := pg.BeginTx(context.Background(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin tx: %w", err)
+		return fmt.Errorf("failed to begin tx: %w", err)
 	}
 
 	if err := checkUser(tx, userUUID); err != nil {
-		return nil, errors.Join(err, tx.Rollback())
+		return errors.Join(err, tx.Rollback())
+	}
+	if teamUUID != "" {
+		if _, _, err := authorizeTeam(tx, principal, teamUUID, db.RoleOperator); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
 	}
 
-	// query for active certificates
-	query := `
-SELECT uuid, private_key, body, active, created_at FROM certificates
-WHERE user_uuid = $1 AND active`
-	rows, err := tx.Query(query, userUUID)
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// insertCert inserts `cert`, parsed as `x509Cert`, with its private key
+// `encrypted` if it has one, after checking its authority and the certificate
+// it replaces.
+func insertCert(tx *sql.Tx, cert *db.Cert, x509Cert *x509.Certificate, encrypted *encrypter.EncryptedKey) error {
+	if cert.AuthorityUUID != "" {
+		if err := checkAuthority(tx, cert.AuthorityUUID, x509Cert); err != nil {
+			return err
+		}
+	}
+
+	if cert.ReplacesUUID != "" {
+		if err := checkUserCert(tx, "replaces_uuid", cert.ReplacesUUID, cert.UserUUID); err != nil {
+			return err
+		}
+	}
+
+	// insert cert and fills the auto generated fields in Cert
+	var privateKey, dataKey, masterKeyID any
+	if encrypted != nil {
+		privateKey, dataKey, masterKeyID = encrypted.Ciphertext, encrypted.WrappedDataKey, encrypted.MasterKeyID
+	}
+	labels, err := marshalLabels(cert.Labels)
 	if err != nil {
-		return nil, errors.Join(fmt.Errorf("failed to query: %w", err), tx.Rollback())
+		return err
 	}
-	var certs []*db.Cert
-	for rows.Next() {
-		cert := &db.Cert{UserUUID: userUUID}
-		if errScan := rows.Scan(&cert.UUID, &cert.PrivateKey, &cert.Body, &cert.Active, &cert.CreatedAt); errScan != nil {
-			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
-		} else {
-			certs = append(certs, cert)
+	query := `
+INSERT INTO certificates (user_uuid, team_uuid, authority_uuid, replaces_uuid,
+	private_key, data_key, master_key_id, body, subject, issuer, serial_number,
+	sans, key_algorithm, not_before, not_after, fingerprint, labels)
+VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, NULLIF($4, '')::uuid,
+	$5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+RETURNING uuid, status, active, created_at`
+	if err := tx.QueryRow(query, cert.UserUUID, cert.TeamUUID, cert.AuthorityUUID,
+		cert.ReplacesUUID, privateKey, dataKey, masterKeyID, cert.Body,
+		cert.Subject, cert.Issuer, cert.SerialNumber, pq.Array(cert.SANs),
+		cert.KeyAlgorithm, cert.NotBefore, cert.NotAfter, cert.Fingerprint,
+		labels).
+		Scan(&cert.UUID, &cert.Status, &cert.Active, &cert.CreatedAt); err != nil {
+		if isUniqueViolation(err, userFingerprintIndex, userIssuerSerialIndex) {
+			return fmt.Errorf("certificate %s is already added: %w", cert.Fingerprint, db.ErrConflict)
+		}
+		if isUniqueViolation(err, serialNumberIndex) {
+			return fmt.Errorf("serial number %s is already issued: %w", cert.SerialNumber, db.ErrConflict)
 		}
+		return fmt.Errorf("failed to insert certificate: %w", err)
+	}
+
+	// the replacement inherits the renewal policy of the replaced cert
+	if cert.ReplacesUUID != "" {
+		query := `
+UPDATE renewal_policies
+SET cert_uuid = $2, updated_at = CURRENT_TIMESTAMP
+WHERE cert_uuid = $1`
+		if _, err := tx.Exec(query, cert.ReplacesUUID, cert.UUID); err != nil {
+			return fmt.Errorf("failed to move renewal policy: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetCerts returns a page of the public metadata of the active certificates
+// belonging to `userUUID`, it errors out if the user does not exist or is not
+// active, and returns db.ErrForbidden if `principal` may not access it.
+// Private keys are only available through ExportPrivateKey.
+func (pg *Postgres) GetCerts(userUUID, cursor string, limit int, principal *db.Principal) (*db.CertPage, error) {
+	active := true
+	return pg.QueryCerts(&db.CertQuery{
+		UserUUID: userUUID,
+		Active:   &active,
+		Cursor:   cursor,
+		Limit:    limit,
+	}, principal)
+}
+
+// GetCert returns the public metadata of certificate `uuid` belonging to
+// `userUUID`. It returns a *db.ValidationError if the certificate does not
+// exist, and db.ErrForbidden if `principal` may not read it, see
+// authorizeCertRead.
+func (pg *Postgres) GetCert(uuid, userUUID string, principal *db.Principal) (*db.Cert, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
 	}
-	if err := rows.Close(); err != nil {
-		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", err), tx.Rollback())
+
+	if err := authorizeCertRead(tx, principal, uuid, userUUID); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+SELECT ` + certColumns + `
+FROM certificates
+WHERE uuid = $1 AND user_uuid = $2`
+	cert, err := scanCert(tx.QueryRow(query, uuid, userUUID))
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to query for certificate: %w", err), tx.Rollback())
 	}
 
 	// commit the transaction
-	if err = tx.Commit(); err != nil {
-		return nil, errors.Join(err, fmt.Errorf("failed to commit tx: %w", err))
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
 	}
-	return certs, err
+	return cert, nil
 }
 
-func updateCertActiveStatus(tx *sql.Tx, uuid string, active bool) error {
-	// update db only if active status is different from cert.active
+// updateCertActiveStatus sets the active field of certificate `uuid`
+// belonging to `userUUID` to `active` and returns the updated certificate. It
+// errors out if the certificate is already in that state, pending or revoked.
+func updateCertActiveStatus(tx *sql.Tx, uuid, userUUID string, active bool) (*db.Cert, error) {
+	// update db only if active status is different from cert.active, pending
+	// and revoked certificates cannot be toggled
 	query := `
 UPDATE certificates
 SET active = $2
-WHERE uuid = $1 AND active != $2`
-	res, err := tx.Exec(query, uuid, active)
+WHERE uuid = $1 AND active != $2 AND status = 'issued' AND user_uuid = $3
+RETURNING ` + certColumns
+	cert, err := scanCert(tx.QueryRow(query, uuid, active, userUUID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("certificate is not an issued certificate with active = %t", !active)
+		}
+		return nil, fmt.Errorf("failed to update certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// SetCertActiveStatus updates the active field of a certificate if needed and
+// returns the updated certificate, it errors out if the certificate does not
+// belong to the user, or the user does not exist or is not active and the
+// certificate has no team, and returns db.ErrForbidden if `principal` may not
+// manage the certificate, see authorizeCert. The change is recorded in the
+// certificate history as done by `principal`.
+// TODO: assumption - cert status cannot be changed after user deletion
+func (pg *Postgres) SetCertActiveStatus(uuid, userUUID string, active bool, principal *db.Principal) (*db.Cert, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to execute sql statement: %w", err)
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
 	}
-	count, err := res.RowsAffected()
+
+	if err = authorizeCert(tx, principal, uuid, userUUID); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	cert, err := updateCertActiveStatus(tx, uuid, userUUID, active)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, errors.Join(err, tx.Rollback())
 	}
-	if count != 1 {
-		return fmt.Errorf("rows affected = %d, should be 1", count)
+
+	event := db.CertEventDeactivated
+	if active {
+		event = db.CertEventActivated
 	}
-	return nil
+	if err = addCertEvent(tx, uuid, principal.UUID, event,
+		map[string]any{"active": !active}, map[string]any{"active": active}); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to commit tx: %w", err))
+	}
+	return cert, nil
 }
 
-// SetCertActiveStatus updates the active field of a certificate if needed, it
-// errors out if the user does not exist or is not active.
-// TODO: assumption - cert status cannot be changed after user deletion
-func (pg *Postgres) SetCertActiveStatus(uuid, userUUID string, active bool) error {
+// ExportPrivateKey returns the decrypted private key of certificate
+// `export.CertUUID` belonging to `export.UserUUID` if `password` is the
+// password of `principal`, and records `export` in the audit log of private
+// key exports as done by `principal`, filling its db-generated fields and
+// `Actor`. It returns db.ErrForbidden if `principal` may not manage the
+// certificate, see authorizeCert.
+func (pg *Postgres) ExportPrivateKey(export *db.KeyExport, password string, principal *db.Principal) (string, error) {
+	if principal == nil {
+		return "", fmt.Errorf("not allowed to export private keys: %w", db.ErrForbidden)
+	}
+
 	// use transaction for atomicity
 	tx, err := pg.BeginTx(context.Background(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin tx: %w", err)
+		return "", fmt.Errorf("failed to begin tx: %w", err)
 	}
 
-	if err = checkUser(tx, userUUID); err != nil {
-		return errors.Join(err, tx.Rollback())
+	if err := authorizeCert(tx, principal, export.CertUUID, export.UserUUID); err != nil {
+		return "", errors.Join(err, tx.Rollback())
+	}
+	if err := checkUserPassword(tx, principal.UUID, password); err != nil {
+		return "", errors.Join(err, tx.Rollback())
 	}
 
-	if err = updateCertActiveStatus(tx, uuid, active); err != nil {
-		return errors.Join(err, tx.Rollback())
+	// query for the encrypted private key of the user's certificate
+	query := `
+SELECT private_key, data_key, master_key_id FROM certificates
+WHERE uuid = $1 AND user_uuid = $2 AND private_key IS NOT NULL`
+	encrypted := &encrypter.EncryptedKey{}
+	if err := tx.QueryRow(query, export.CertUUID, export.UserUUID).
+		Scan(&encrypted.Ciphertext, &encrypted.WrappedDataKey, &encrypted.MasterKeyID); err != nil {
+		return "", errors.Join(fmt.Errorf("failed to query for private key: %w", err), tx.Rollback())
+	}
+	privateKey, err := pg.decryptPrivateKey(encrypted)
+	if err != nil {
+		return "", errors.Join(err, tx.Rollback())
+	}
+
+	// record the export before handing out the key
+	query = `
+INSERT INTO private_key_exports (cert_uuid, user_uuid, actor, remote_addr)
+VALUES ($1, $2, $3, $4)
+RETURNING uuid, actor, created_at`
+	if err := tx.QueryRow(query, export.CertUUID, export.UserUUID, principal.UUID, export.RemoteAddr).
+		Scan(&export.UUID, &export.Actor, &export.CreatedAt); err != nil {
+		return "", errors.Join(fmt.Errorf("failed to insert private key export: %w", err), tx.Rollback())
 	}
 
 	// commit the transaction
-	if err = tx.Commit(); err != nil {
-		return errors.Join(err, fmt.Errorf("failed to commit tx: %w", err))
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit tx: %w", err)
 	}
-	return nil
+	return privateKey, nil
 }
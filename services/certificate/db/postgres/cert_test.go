@@ -2,28 +2,116 @@ package postgres_test
 
 import (
 	"certificate/db"
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
 	"testing"
 	"time"
 )
 
+var mockX509Cert, mockCertBody, mockPrivateKey = pkitest.SelfSigned(&x509.Certificate{
+	SerialNumber: big.NewInt(42),
+	Subject:      pkix.Name{CommonName: "mock.example.com"},
+	DNSNames:     []string{"mock.example.com"},
+	NotBefore:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	NotAfter:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+})
+
 var mockCert0 = &db.Cert{
-	UUID:       "mock_cert_uuid_0",
-	UserUUID:   mockUser.UUID,
-	PrivateKey: "private_key",
-	Body:       "cert_body",
-	Active:     true,
-	CreatedAt:  time.Now(),
+	UUID:         "mock_cert_uuid_0",
+	UserUUID:     mockUser.UUID,
+	Status:       db.CertStatusIssued,
+	PrivateKey:   mockPrivateKey,
+	Body:         mockCertBody,
+	Subject:      "CN=mock.example.com",
+	Issuer:       "CN=mock.example.com",
+	SerialNumber: "2a",
+	SANs:         []string{"mock.example.com"},
+	KeyAlgorithm: "ECDSA-P256",
+	NotBefore:    mockX509Cert.NotBefore,
+	NotAfter:     mockX509Cert.NotAfter,
+	Fingerprint:  pki.Fingerprint(mockX509Cert),
+	Active:       true,
+	CreatedAt:    time.Now(),
 }
 
 var mockCert1 = &db.Cert{
-	UUID:       "mock_cert_uuid_1",
-	UserUUID:   mockUser.UUID,
-	PrivateKey: "private_key",
-	Body:       "cert_body",
-	Active:     true,
-	CreatedAt:  time.Now(),
+	UUID:         "mock_cert_uuid_1",
+	UserUUID:     mockUser.UUID,
+	Status:       db.CertStatusIssued,
+	PrivateKey:   mockPrivateKey,
+	Body:         mockCertBody,
+	Subject:      "CN=mock.example.com",
+	Issuer:       "CN=mock.example.com",
+	SerialNumber: "2a",
+	SANs:         []string{"mock.example.com"},
+	KeyAlgorithm: "ECDSA-P256",
+	NotBefore:    mockX509Cert.NotBefore,
+	NotAfter:     mockX509Cert.NotAfter,
+	Fingerprint:  pki.Fingerprint(mockX509Cert),
+	Active:       true,
+	CreatedAt:    time.Now(),
+}
+
+// certColumns are the columns returned when querying certificates.
+var certColumns = []string{"uuid", "user_uuid", "team_uuid", "authority_uuid",
+	"replaces_uuid", "status", "csr", "body", "subject", "issuer", "serial_number", "sans",
+	"key_algorithm", "not_before", "not_after", "fingerprint", "revoked_at",
+	"revocation_reason", "labels", "active", "created_at"}
+
+// certValues returns the values of `cert` in the order of `certColumns`.
+func certValues(cert *db.Cert) []driver.Value {
+	var revokedAt driver.Value
+	if !cert.RevokedAt.IsZero() {
+		revokedAt = cert.RevokedAt
+	}
+	labels := []byte("{}")
+	if cert.Labels != nil {
+		labels, _ = json.Marshal(cert.Labels)
+	}
+	return []driver.Value{cert.UUID, cert.UserUUID, cert.TeamUUID, cert.AuthorityUUID,
+		cert.ReplacesUUID, cert.Status, cert.CSR, cert.Body, cert.Subject, cert.Issuer,
+		cert.SerialNumber, "{" + strings.Join(cert.SANs, ",") + "}",
+		cert.KeyAlgorithm, cert.NotBefore, cert.NotAfter, cert.Fingerprint,
+		revokedAt, cert.RevocationReason, labels, cert.Active, cert.CreatedAt}
+}
+
+// publicCert returns a copy of `cert` without its private key.
+func publicCert(cert *db.Cert) *db.Cert {
+	public := *cert
+	public.PrivateKey = ""
+	return &public
+}
+
+// expectCertEvent expects an `event` of certificate `certUUID` made by
+// `actor` to be added to the certificate history.
+func expectCertEvent(mock sqlmock.Sqlmock, certUUID, actor, event string) {
+	mock.ExpectExec(`
+^INSERT INTO certificate_events (.+)
+VALUES (.+)`).
+		WithArgs(certUUID, actor, event, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectCertTeam expects the team of certificate `certUUID` of `userUUID`
+// to be queried, which is `teamUUID` or none if it is empty.
+func expectCertTeam(mock sqlmock.Sqlmock, certUUID, userUUID, teamUUID string) {
+	rows := sqlmock.NewRows([]string{"team_uuid"}).
+		AddRow(teamUUID)
+	mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE uuid = \$1 AND user_uuid = \$2$`).
+		WithArgs(certUUID, userUUID).
+		WillReturnRows(rows)
 }
 
 func TestPostgres_AddCert(t *testing.T) {
@@ -47,22 +135,101 @@ WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
 
-		rows = sqlmock.NewRows([]string{"uuid", "active", "created_at"}).
-			AddRow(mockCert0.UUID, mockCert0.Active, mockCert0.CreatedAt)
+		rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockCert0.UUID, mockCert0.Status, mockCert0.Active, mockCert0.CreatedAt)
 
 		mock.ExpectQuery(`
 ^INSERT INTO certificates (.+)
 VALUES (.+)
 RETURNING (.+)*`).
-			WithArgs(cert.UserUUID, cert.PrivateKey, cert.Body).
+			WithArgs(cert.UserUUID, "", "", "", []byte(cert.PrivateKey), mockDataKey,
+				mockMasterKeyID, mockCert0.Body,
+				mockCert0.Subject, mockCert0.Issuer, mockCert0.SerialNumber,
+				pq.Array(mockCert0.SANs), mockCert0.KeyAlgorithm,
+				mockCert0.NotBefore, mockCert0.NotAfter, mockCert0.Fingerprint, "{}").
 			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventAdded)
 		mock.ExpectCommit()
 
-		assert.Nil(t, pg.AddCert(cert))
+		assert.Nil(t, pg.AddCert(cert, mockPrincipal))
 		assert.Nil(t, mock.ExpectationsWereMet())
 		assert.Equal(t, mockCert0, cert)
 	})
-	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+	t.Run("happy_path_replaces_cert", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:     mockCert1.UserUUID,
+			ReplacesUUID: mockCert0.UUID,
+			PrivateKey:   mockCert1.PrivateKey,
+			Body:         mockCert1.Body,
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockCert0.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM certificates
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockCert1.UUID, mockCert1.Status, mockCert1.Active, mockCert1.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WithArgs(cert.UserUUID, "", "", mockCert0.UUID, []byte(cert.PrivateKey),
+				mockDataKey, mockMasterKeyID, mockCert1.Body,
+				mockCert1.Subject, mockCert1.Issuer, mockCert1.SerialNumber,
+				pq.Array(mockCert1.SANs), mockCert1.KeyAlgorithm,
+				mockCert1.NotBefore, mockCert1.NotAfter, mockCert1.Fingerprint, "{}").
+			WillReturnRows(rows)
+		mock.ExpectExec(`
+^UPDATE renewal_policies
+SET cert_uuid = (.+)
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, mockCert1.UUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectCertEvent(mock, mockCert1.UUID, mockUser.UUID, db.CertEventAdded)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		expected := *mockCert1
+		expected.ReplacesUUID = mockCert0.UUID
+		assert.Equal(t, &expected, cert)
+	})
+	t.Run("error_duplicate_cert_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID: mockCert0.UserUUID,
+			Body:     mockCert0.Body,
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "user_fingerprint_idx"})
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, pg.AddCert(cert, mockPrincipal), db.ErrConflict)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_duplicate_serial_number_with_tx_rollback", func(t *testing.T) {
 		cert := &db.Cert{
 			UserUUID:   mockCert0.UserUUID,
 			PrivateKey: mockCert0.PrivateKey,
@@ -70,53 +237,236 @@ RETURNING (.+)*`).
 		}
 
 		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"uuid"})
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
 		mock.ExpectQuery(`
 ^SELECT uuid FROM users
 WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "serial_number_idx"})
 		mock.ExpectRollback()
 
-		assert.NotNil(t, pg.AddCert(cert))
+		assert.ErrorIs(t, pg.AddCert(cert, mockPrincipal), db.ErrConflict)
 		assert.Nil(t, mock.ExpectationsWereMet())
-		assert.NotEqual(t, mockCert0, cert)
 	})
-}
+	t.Run("error_replaces_other_users_cert_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:     mockCert1.UserUUID,
+			ReplacesUUID: mockCert0.UUID,
+			Body:         mockCert1.Body,
+		}
 
-func TestPostgres_GetCerts(t *testing.T) {
-	pg, mock, _ := MockConnect(t)
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^SELECT uuid FROM certificates
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "replaces_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Empty(t, cert.UUID)
+	})
+	t.Run("happy_path_issued_without_private_key", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:      mockUser.UUID,
+			AuthorityUUID: mockAuthority.UUID,
+			Body:          mockIssuedCertBody,
+		}
 
-	t.Run("happy_path", func(t *testing.T) {
 		mock.ExpectBegin()
 
 		rows := sqlmock.NewRows([]string{"uuid"}).
 			AddRow(mockUser.UUID)
-
 		mock.ExpectQuery(`
 ^SELECT uuid FROM users
 WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
 
-		rows = sqlmock.NewRows([]string{"uuid", "private_key", "body", "active", "created_at"}).
-			AddRow(mockCert0.UUID, mockCert0.PrivateKey, mockCert0.Body, mockCert0.Active, mockCert0.CreatedAt).
-			AddRow(mockCert1.UUID, mockCert1.PrivateKey, mockCert1.Body, mockCert1.Active, mockCert1.CreatedAt)
+		rows = sqlmock.NewRows([]string{"body"}).
+			AddRow(mockAuthority.Body)
+		mock.ExpectQuery(`
+^SELECT body FROM authorities
+WHERE (.+)*`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(rows)
+
+		rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockCert0.UUID, db.CertStatusIssued, true, mockCert0.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WithArgs(mockUser.UUID, "", mockAuthority.UUID, "", nil, nil, nil,
+				mockIssuedCertBody, "CN=dog.example.com", mockAuthority.Subject,
+				sqlmock.AnyArg(), pq.Array([]string{"dog.example.com"}),
+				"ECDSA-P256", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "{}").
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventAdded)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, mockCert0.UUID, cert.UUID)
+	})
+	t.Run("error_not_signed_by_authority_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:      mockCert0.UserUUID,
+			AuthorityUUID: mockAuthority.UUID,
+			PrivateKey:    mockCert0.PrivateKey,
+			Body:          mockCert0.Body,
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
 		mock.ExpectQuery(`
-^SELECT (.+) FROM certificates
+^SELECT uuid FROM users
 WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"body"}).
+			AddRow(mockAuthority.Body)
+		mock.ExpectQuery(`
+^SELECT body FROM authorities
+WHERE (.+)*`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "authority_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_team_cert", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:   mockCert0.UserUUID,
+			TeamUUID:   mockTeam.UUID,
+			PrivateKey: mockCert0.PrivateKey,
+			Body:       mockCert0.Body,
+		}
 
+		// the certificate belongs to the team, its user is not checked
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockCert0.UUID, mockCert0.Status, mockCert0.Active, mockCert0.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WithArgs(cert.UserUUID, mockTeam.UUID, "", "", []byte(cert.PrivateKey), mockDataKey,
+				mockMasterKeyID, mockCert0.Body,
+				mockCert0.Subject, mockCert0.Issuer, mockCert0.SerialNumber,
+				pq.Array(mockCert0.SANs), mockCert0.KeyAlgorithm,
+				mockCert0.NotBefore, mockCert0.NotAfter, mockCert0.Fingerprint, "{}").
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventAdded)
 		mock.ExpectCommit()
 
-		certs, err := pg.GetCerts(mockUser.UUID)
-		assert.Nil(t, err)
-		assert.EqualValues(t, []*db.Cert{mockCert0, mockCert1}, certs)
+		assert.Nil(t, pg.AddCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, mockTeam.UUID, cert.TeamUUID)
+		assert.Equal(t, mockCert0.UUID, cert.UUID)
+	})
+	t.Run("error_team_viewer_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:   mockCert0.UserUUID,
+			TeamUUID:   mockTeam.UUID,
+			PrivateKey: mockCert0.PrivateKey,
+			Body:       mockCert0.Body,
+		}
+
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, pg.AddCert(cert, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_with_chain", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:   mockCert0.UserUUID,
+			PrivateKey: mockCert0.PrivateKey,
+			Body:       mockCert0.Body,
+			Chain:      []string{mockAuthority.Body},
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockCert0.UUID, mockCert0.Status, mockCert0.Active, mockCert0.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)`).
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventAdded)
+		rows = sqlmock.NewRows([]string{"uuid", "trusted", "created_at"}).
+			AddRow("mock_chain_cert_uuid", false, time.Now())
+		mock.ExpectQuery(`
+^INSERT INTO chain_certificates (.+)`).
+			WithArgs(mockAuthority.Body, mockAuthority.Subject,
+				pki.KeyID(mockCA.Cert.SubjectKeyId), "", false,
+				mockAuthority.NotAfter, pki.Fingerprint(mockCA.Cert)).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, mockCert0.UUID, cert.UUID)
+	})
+	t.Run("error_other_user_with_chain", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID: "other_user_uuid",
+			Body:     mockCert0.Body,
+			Chain:    []string{mockAuthority.Body},
+		}
+
+		assert.ErrorIs(t, pg.AddCert(cert, mockPrincipal), db.ErrForbidden)
 		assert.Nil(t, mock.ExpectationsWereMet())
 	})
+	t.Run("error_chain_not_ca", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID: mockCert0.UserUUID,
+			Body:     mockCert0.Body,
+			Chain:    []string{mockCert1.Body},
+		}
 
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "chain", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Empty(t, cert.UUID)
+	})
 	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:   mockCert0.UserUUID,
+			PrivateKey: mockCert0.PrivateKey,
+			Body:       mockCert0.Body,
+		}
+
 		mock.ExpectBegin()
 		rows := sqlmock.NewRows([]string{"uuid"})
 		mock.ExpectQuery(`
@@ -126,54 +476,715 @@ WHERE (.+)*`).
 			WillReturnRows(rows)
 		mock.ExpectRollback()
 
-		certs, err := pg.GetCerts(mockUser.UUID)
-		assert.NotNil(t, err)
-		assert.Nil(t, certs)
+		assert.NotNil(t, pg.AddCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.NotEqual(t, mockCert0, cert)
+	})
+	t.Run("error_invalid_body", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:   mockCert0.UserUUID,
+			PrivateKey: mockCert0.PrivateKey,
+			Body:       "cert_body",
+		}
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "body", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Empty(t, cert.UUID)
+	})
+	t.Run("error_invalid_labels", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID: mockCert0.UserUUID,
+			Body:     mockCert0.Body,
+			Labels:   map[string]string{"env": ""},
+		}
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "labels", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Empty(t, cert.UUID)
+	})
+	t.Run("error_mismatched_private_key", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:   mockCert0.UserUUID,
+			PrivateKey: pkitest.EncodeKey(pkitest.NewKey()),
+			Body:       mockCert0.Body,
+		}
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "private_key", validationErr.Field)
 		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Empty(t, cert.UUID)
 	})
 }
 
-func TestPostgres_SetCertActiveStatus(t *testing.T) {
+func TestPostgres_AddCerts(t *testing.T) {
 	pg, mock, _ := MockConnect(t)
 
 	t.Run("happy_path", func(t *testing.T) {
-		mock.ExpectBegin()
+		certs := []*db.Cert{
+			{UserUUID: mockCert0.UserUUID, PrivateKey: mockCert0.PrivateKey, Body: mockCert0.Body},
+			{UserUUID: mockCert1.UserUUID, PrivateKey: mockCert1.PrivateKey, Body: mockCert1.Body},
+		}
 
+		mock.ExpectBegin()
 		rows := sqlmock.NewRows([]string{"uuid"}).
 			AddRow(mockUser.UUID)
-
 		mock.ExpectQuery(`
 ^SELECT uuid FROM users
 WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
+		for _, expected := range []*db.Cert{mockCert0, mockCert1} {
+			rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+				AddRow(expected.UUID, expected.Status, expected.Active, expected.CreatedAt)
+			mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+				WithArgs(expected.UserUUID, "", "", "", []byte(expected.PrivateKey),
+					mockDataKey, mockMasterKeyID, expected.Body,
+					expected.Subject, expected.Issuer, expected.SerialNumber,
+					pq.Array(expected.SANs), expected.KeyAlgorithm,
+					expected.NotBefore, expected.NotAfter, expected.Fingerprint, "{}").
+				WillReturnRows(rows)
+			expectCertEvent(mock, expected.UUID, mockUser.UUID, db.CertEventAdded)
+		}
+		mock.ExpectCommit()
 
-		mock.ExpectExec(`
-^UPDATE certificates
-SET (.+)*
+		assert.Nil(t, pg.AddCerts(certs, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, []*db.Cert{mockCert0, mockCert1}, certs)
+	})
+	t.Run("error_insert_with_tx_rollback", func(t *testing.T) {
+		certs := []*db.Cert{
+			{UserUUID: mockCert0.UserUUID, Body: mockCert0.Body},
+			{UserUUID: mockCert1.UserUUID, Body: mockCert1.Body},
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
 WHERE (.+)*`).
-			WithArgs(mockCert0.UUID, mockCert0.Active).
-			WillReturnResult(sqlmock.NewResult(0, 1))
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockCert0.UUID, mockCert0.Status, mockCert0.Active, mockCert0.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventAdded)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WillReturnError(errors.New("mock_error"))
+		mock.ExpectRollback()
 
-		mock.ExpectCommit()
+		assert.NotNil(t, pg.AddCerts(certs, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_invalid_body", func(t *testing.T) {
+		certs := []*db.Cert{
+			{UserUUID: mockCert0.UserUUID, Body: mockCert0.Body},
+			{UserUUID: mockCert1.UserUUID, Body: "cert_body"},
+		}
 
-		err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, mockCert0.Active)
-		assert.Nil(t, err)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddCerts(certs, mockPrincipal), &validationErr)
+		assert.Equal(t, "body", validationErr.Field)
 		assert.Nil(t, mock.ExpectationsWereMet())
 	})
+}
 
-	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+func TestPostgres_GetCerts(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
 		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"uuid"})
+
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+
 		mock.ExpectQuery(`
 ^SELECT uuid FROM users
 WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
-		mock.ExpectRollback()
 
-		err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, mockCert0.Active)
-		assert.NotNil(t, err)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...).
+			AddRow(certValues(mockCert1)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE user_uuid = \$1 AND active = \$2
+ORDER BY created_at, uuid$`).
+			WithArgs(mockUser.UUID, true).
+			WillReturnRows(rows)
+
+		mock.ExpectCommit()
+
+		page, err := pg.GetCerts(mockUser.UUID, "", 0, mockPrincipal)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []*db.Cert{publicCert(mockCert0), publicCert(mockCert1)}, page.Certs)
+		assert.Empty(t, page.NextCursor)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_pages", func(t *testing.T) {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...).
+			AddRow(certValues(mockCert1)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE user_uuid = \$1 AND active = \$2
+ORDER BY created_at, uuid
+LIMIT 2$`).
+			WithArgs(mockUser.UUID, true).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		page, err := pg.GetCerts(mockUser.UUID, "", 1, mockPrincipal)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []*db.Cert{publicCert(mockCert0)}, page.Certs)
+		assert.NotEmpty(t, page.NextCursor)
+		assert.Nil(t, mock.ExpectationsWereMet())
+
+		// the next page starts after the last certificate of this one
+		mock.ExpectBegin()
+		rows = sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert1)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE user_uuid = \$1 AND active = \$2 AND \(created_at, uuid\) > \(\$3, \$4\)
+ORDER BY created_at, uuid
+LIMIT 2$`).
+			WithArgs(mockUser.UUID, true, mockCert0.CreatedAt.UTC(), mockCert0.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		page, err = pg.GetCerts(mockUser.UUID, page.NextCursor, 1, mockPrincipal)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []*db.Cert{publicCert(mockCert1)}, page.Certs)
+		assert.Empty(t, page.NextCursor)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_invalid_cursor", func(t *testing.T) {
+		page, err := pg.GetCerts(mockUser.UUID, "cursor", 1, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "cursor", validationErr.Field)
+		assert.Nil(t, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"})
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		page, err := pg.GetCerts(mockUser.UUID, "", 0, mockPrincipal)
+		assert.NotNil(t, err)
+		assert.Nil(t, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_forbidden", func(t *testing.T) {
+		page, err := pg.GetCerts("other_user_uuid", "", 0, mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetCert(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE uuid = \$1 AND user_uuid = \$2`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		cert, err := pg.GetCert(mockCert0.UUID, mockUser.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(mockCert0), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_team_viewer_owner_left", func(t *testing.T) {
+		teamCert := *mockCert0
+		teamCert.UserUUID = "mock_inactive_user_uuid"
+		teamCert.TeamUUID = mockTeam.UUID
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, teamCert.UUID, teamCert.UserUUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(&teamCert)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates`).
+			WithArgs(teamCert.UUID, teamCert.UserUUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		cert, err := pg.GetCert(teamCert.UUID, teamCert.UserUUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(&teamCert), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_not_team_member_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, "other_user_uuid", mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, "", "")
+		mock.ExpectRollback()
+
+		cert, err := pg.GetCert(mockCert0.UUID, "other_user_uuid", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_cert_not_found_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"team_uuid"}))
+		mock.ExpectRollback()
+
+		cert, err := pg.GetCert(mockCert0.UUID, mockUser.UUID, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_SetCertActiveStatus(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET active = \$2
+WHERE (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, mockCert0.Active, mockCert0.UserUUID).
+			WillReturnRows(sqlmock.NewRows(certColumns).AddRow(certValues(mockCert0)...))
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventActivated)
+
+		mock.ExpectCommit()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, mockCert0.Active, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(mockCert0), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"})
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, mockCert0.Active, mockPrincipal)
+		assert.Nil(t, cert)
+		assert.NotNil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_admin", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET active = \$2
+WHERE (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, mockCert0.Active, mockCert0.UserUUID).
+			WillReturnRows(sqlmock.NewRows(certColumns).AddRow(certValues(mockCert0)...))
+		expectCertEvent(mock, mockCert0.UUID, mockAdmin.UUID, db.CertEventActivated)
+		mock.ExpectCommit()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, mockCert0.Active, mockAdmin)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(mockCert0), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_team_operator", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, "mock_inactive_user_uuid", mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET active = \$2
+WHERE (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, false, "mock_inactive_user_uuid").
+			WillReturnRows(sqlmock.NewRows(certColumns).AddRow(certValues(mockCert0)...))
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventDeactivated)
+		mock.ExpectCommit()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, "mock_inactive_user_uuid", false, mockPrincipal)
+		assert.Nil(t, err)
+		assert.NotNil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_already_active_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET active = \$2
+WHERE (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, true, mockCert0.UserUUID).
+			WillReturnRows(sqlmock.NewRows(certColumns))
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, true, mockPrincipal)
+		assert.Nil(t, cert)
+		assert.NotNil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_owner_left_team_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, "", "")
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, false, mockPrincipal)
+		assert.Nil(t, cert)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_other_users_cert_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE (.+)`).
+			WithArgs(mockCert1.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"team_uuid"}))
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertActiveStatus(mockCert1.UUID, mockUser.UUID, true, mockPrincipal)
+		assert.Nil(t, cert)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_forbidden_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, "other_user_uuid", "")
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, "other_user_uuid", true, mockPrincipal)
+		assert.Nil(t, cert)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_no_principal_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertActiveStatus(mockCert0.UUID, mockCert0.UserUUID, true, nil)
+		assert.Nil(t, cert)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_ExportPrivateKey(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		export := &db.KeyExport{
+			CertUUID:   mockCert0.UUID,
+			UserUUID:   mockCert0.UserUUID,
+			RemoteAddr: "10.0.0.1",
+		}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE uuid = \$1 AND active`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+
+		rows = sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+) AND password = crypt\(\$2, password\)`).
+			WithArgs(mockUser.UUID, "tuna").
+			WillReturnRows(rows)
+
+		rows = sqlmock.NewRows([]string{"private_key", "data_key", "master_key_id"}).
+			AddRow([]byte(mockCert0.PrivateKey), mockDataKey, mockMasterKeyID)
+		mock.ExpectQuery(`
+^SELECT private_key, data_key, master_key_id FROM certificates
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, mockCert0.UserUUID).
+			WillReturnRows(rows)
+
+		createdAt := time.Now()
+		rows = sqlmock.NewRows([]string{"uuid", "actor", "created_at"}).
+			AddRow("mock_export_uuid", mockPrincipal.UUID, createdAt)
+		mock.ExpectQuery(`
+^INSERT INTO private_key_exports (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WithArgs(mockCert0.UUID, mockCert0.UserUUID, mockPrincipal.UUID, export.RemoteAddr).
+			WillReturnRows(rows)
+
+		mock.ExpectCommit()
+
+		privateKey, err := pg.ExportPrivateKey(export, "tuna", mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, mockCert0.PrivateKey, privateKey)
+		assert.Equal(t, "mock_export_uuid", export.UUID)
+		assert.Equal(t, mockPrincipal.UUID, export.Actor)
+		assert.Equal(t, createdAt, export.CreatedAt)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_team_operator", func(t *testing.T) {
+		export := &db.KeyExport{
+			CertUUID:   mockCert0.UUID,
+			UserUUID:   "mock_inactive_user_uuid",
+			RemoteAddr: "10.0.0.1",
+		}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, export.UserUUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+) AND password = crypt\(\$2, password\)`).
+			WithArgs(mockUser.UUID, "tuna").
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"private_key", "data_key", "master_key_id"}).
+			AddRow([]byte(mockCert0.PrivateKey), mockDataKey, mockMasterKeyID)
+		mock.ExpectQuery(`
+^SELECT private_key, data_key, master_key_id FROM certificates
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, export.UserUUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "actor", "created_at"}).
+			AddRow("mock_export_uuid", mockPrincipal.UUID, time.Now())
+		mock.ExpectQuery(`
+^INSERT INTO private_key_exports (.+)`).
+			WithArgs(mockCert0.UUID, export.UserUUID, mockPrincipal.UUID, export.RemoteAddr).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		privateKey, err := pg.ExportPrivateKey(export, "tuna", mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, mockCert0.PrivateKey, privateKey)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_team_viewer_with_tx_rollback", func(t *testing.T) {
+		export := &db.KeyExport{
+			CertUUID: mockCert0.UUID,
+			UserUUID: mockCert0.UserUUID,
+		}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		mock.ExpectRollback()
+
+		privateKey, err := pg.ExportPrivateKey(export, "tuna", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Empty(t, privateKey)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_wrong_password_with_tx_rollback", func(t *testing.T) {
+		export := &db.KeyExport{
+			CertUUID: mockCert0.UUID,
+			UserUUID: mockCert0.UserUUID,
+		}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE uuid = \$1 AND active`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID, "salmon").
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.ExpectRollback()
+
+		privateKey, err := pg.ExportPrivateKey(export, "salmon", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrUnauthorized)
+		assert.Empty(t, privateKey)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_cert_not_found_with_tx_rollback", func(t *testing.T) {
+		export := &db.KeyExport{
+			CertUUID: mockCert0.UUID,
+			UserUUID: mockCert0.UserUUID,
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE (.+)`).
+			WithArgs(mockCert0.UUID, mockCert0.UserUUID).
+			WillReturnRows(sqlmock.NewRows([]string{"team_uuid"}))
+		mock.ExpectRollback()
+
+		privateKey, err := pg.ExportPrivateKey(export, "tuna", mockPrincipal)
+		assert.NotNil(t, err)
+		assert.Empty(t, privateKey)
+		assert.Empty(t, export.UUID)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_CheckCertOwners(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.CheckCertOwners(mockUser.UUID, mockTeam.UUID, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_other_user", func(t *testing.T) {
+		assert.ErrorIs(t, pg.CheckCertOwners("other_user_uuid", "", mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_team_viewer_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, pg.CheckCertOwners(mockUser.UUID, mockTeam.UUID, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_inactive_user_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.ExpectRollback()
+
+		assert.NotNil(t, pg.CheckCertOwners(mockUser.UUID, "", mockPrincipal))
 		assert.Nil(t, mock.ExpectationsWereMet())
 	})
 }
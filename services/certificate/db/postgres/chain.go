@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// rowQuerier is implemented by *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// AddChainCert adds intermediate or root certificate `cert` to the database
+// unless it is there already, and fills `cert` with db-generated fields like
+// `UUID` and `CreatedAt` as well as the fields parsed from `cert.Body`. A
+// stored certificate becomes trusted if `cert.Trusted` is set, but is never
+// distrusted by adding it again. It errors out if `cert.Body` is not a CA
+// certificate with a subject key identifier.
+func (pg *Postgres) AddChainCert(cert *db.ChainCert) error {
+	if err := parseChainCert(cert, "body"); err != nil {
+		return err
+	}
+	return insertChainCert(pg.DB, cert)
+}
+
+// parseChainCert decodes `cert.Body` as an X.509 CA certificate, normalizes
+// it to PEM and fills the fields of `cert` that are derived from it. It
+// returns a *db.ValidationError for `field` if it is rejected.
+func parseChainCert(cert *db.ChainCert, field string) error {
+	x509Cert, err := pki.ParseCertificate(cert.Body)
+	if err != nil {
+		return &db.ValidationError{Field: field, Err: err}
+	}
+	if !x509Cert.IsCA {
+		return &db.ValidationError{Field: field, Err: errors.New("certificate is not a CA certificate")}
+	}
+	if len(x509Cert.SubjectKeyId) == 0 {
+		return &db.ValidationError{Field: field, Err: errors.New("certificate has no subject key identifier")}
+	}
+	cert.Body = pki.EncodeCertificate(x509Cert)
+	cert.Subject = x509Cert.Subject.String()
+	cert.SubjectKeyID = pki.KeyID(x509Cert.SubjectKeyId)
+	cert.AuthorityKeyID = pki.KeyID(x509Cert.AuthorityKeyId)
+	cert.NotAfter = x509Cert.NotAfter.UTC()
+	cert.Fingerprint = pki.Fingerprint(x509Cert)
+	return nil
+}
+
+// insertChainCert inserts `cert`, parsed by parseChainCert, unless it is
+// there already, and fills its db-generated fields.
+func insertChainCert(q rowQuerier, cert *db.ChainCert) error {
+	// the update makes RETURNING fill the fields of an existing row
+	query := `
+INSERT INTO chain_certificates (body, subject, subject_key_id,
+	authority_key_id, trusted, not_after, fingerprint)
+VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
+ON CONFLICT (fingerprint) DO UPDATE
+SET trusted = chain_certificates.trusted OR EXCLUDED.trusted
+RETURNING uuid, trusted, created_at`
+	if err := q.QueryRow(query, cert.Body, cert.Subject, cert.SubjectKeyID,
+		cert.AuthorityKeyID, cert.Trusted, cert.NotAfter, cert.Fingerprint).
+		Scan(&cert.UUID, &cert.Trusted, &cert.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert chain certificate: %w", err)
+	}
+	return nil
+}
+
+// GetIssuerCerts returns the PEM encoded chain certificates and authority
+// certificates with subject key identifier `subjectKeyID`, which are the
+// candidate issuers of certificates with that authority key identifier. Only
+// the `Body` and `Trusted` fields are filled, and root CAs are trusted.
+func (pg *Postgres) GetIssuerCerts(subjectKeyID string) ([]*db.ChainCert, error) {
+	query := `
+SELECT body, trusted FROM chain_certificates
+WHERE subject_key_id = $1
+UNION
+SELECT body, parent_uuid IS NULL FROM authorities
+WHERE subject_key_id = $1`
+	rows, err := pg.Query(query, subjectKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	var certs []*db.ChainCert
+	for rows.Next() {
+		cert := &db.ChainCert{}
+		if errScan := rows.Scan(&cert.Body, &cert.Trusted); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			certs = append(certs, cert)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	return certs, err
+}
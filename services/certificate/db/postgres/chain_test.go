@@ -0,0 +1,114 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPostgres_AddChainCert(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		cert := &db.ChainCert{Body: mockAuthority.Body, Trusted: true}
+		createdAt := time.Now()
+
+		rows := sqlmock.NewRows([]string{"uuid", "trusted", "created_at"}).
+			AddRow("mock_chain_cert_uuid", true, createdAt)
+		mock.ExpectQuery(`
+^INSERT INTO chain_certificates (.+)
+VALUES (.+)
+ON CONFLICT \(fingerprint\) DO UPDATE
+SET trusted = chain_certificates.trusted OR EXCLUDED.trusted
+RETURNING uuid, trusted, created_at`).
+			WithArgs(mockAuthority.Body, mockAuthority.Subject,
+				pki.KeyID(mockCA.Cert.SubjectKeyId), "", true,
+				mockAuthority.NotAfter, pki.Fingerprint(mockCA.Cert)).
+			WillReturnRows(rows)
+
+		assert.Nil(t, pg.AddChainCert(cert))
+		assert.Equal(t, &db.ChainCert{
+			UUID:         "mock_chain_cert_uuid",
+			Body:         mockAuthority.Body,
+			Subject:      mockAuthority.Subject,
+			SubjectKeyID: pki.KeyID(mockCA.Cert.SubjectKeyId),
+			Trusted:      true,
+			NotAfter:     mockAuthority.NotAfter,
+			Fingerprint:  pki.Fingerprint(mockCA.Cert),
+			CreatedAt:    createdAt,
+		}, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_self_signed_untrusted", func(t *testing.T) {
+		cert := &db.ChainCert{Body: mockAuthority.Body}
+
+		rows := sqlmock.NewRows([]string{"uuid", "trusted", "created_at"}).
+			AddRow("mock_chain_cert_uuid", false, time.Now())
+		mock.ExpectQuery(`
+^INSERT INTO chain_certificates (.+)`).
+			WithArgs(mockAuthority.Body, mockAuthority.Subject,
+				pki.KeyID(mockCA.Cert.SubjectKeyId), "", false,
+				mockAuthority.NotAfter, pki.Fingerprint(mockCA.Cert)).
+			WillReturnRows(rows)
+
+		assert.Nil(t, pg.AddChainCert(cert))
+		assert.False(t, cert.Trusted)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_not_ca", func(t *testing.T) {
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddChainCert(&db.ChainCert{Body: mockCert0.Body}), &validationErr)
+		assert.Equal(t, "body", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^INSERT INTO chain_certificates (.+)`).
+			WillReturnError(errors.New("mock_error"))
+
+		assert.NotNil(t, pg.AddChainCert(&db.ChainCert{Body: mockAuthority.Body}))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetIssuerCerts(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+	keyID := pki.KeyID(mockCA.Cert.SubjectKeyId)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"body", "trusted"}).
+			AddRow(mockAuthority.Body, true)
+		mock.ExpectQuery(`
+^SELECT body, trusted FROM chain_certificates
+WHERE subject_key_id = \$1
+UNION
+SELECT body, parent_uuid IS NULL FROM authorities
+WHERE subject_key_id = \$1`).
+			WithArgs(keyID).
+			WillReturnRows(rows)
+
+		certs, err := pg.GetIssuerCerts(keyID)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.ChainCert{{Body: mockAuthority.Body, Trusted: true}}, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT body, trusted FROM chain_certificates`).
+			WithArgs(keyID).
+			WillReturnError(errors.New("mock_error"))
+
+		certs, err := pg.GetIssuerCerts(keyID)
+		assert.NotNil(t, err)
+		assert.Nil(t, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"certificate/db"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// NextCRLNumber increments and returns the CRL number of authority
+// `authorityUUID`, so that every CRL it signs has a higher number than the
+// previous one.
+func (pg *Postgres) NextCRLNumber(authorityUUID string) (int64, error) {
+	var number int64
+	query := `
+UPDATE authorities
+SET crl_number = crl_number + 1
+WHERE uuid = $1
+RETURNING crl_number`
+	if err := pg.QueryRow(query, authorityUUID).Scan(&number); err != nil {
+		return 0, fmt.Errorf("failed to increment CRL number: %w", err)
+	}
+	return number, nil
+}
+
+// SaveCRL stores `crl` as the latest CRL of `crl.AuthorityUUID`, unless a CRL
+// with a higher number was stored concurrently.
+func (pg *Postgres) SaveCRL(crl *db.CRL) error {
+	query := `
+INSERT INTO crls (authority_uuid, number, der, this_update, next_update)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (authority_uuid) DO UPDATE
+SET number = $2, der = $3, this_update = $4, next_update = $5
+WHERE crls.number < $2`
+	if _, err := pg.Exec(query, crl.AuthorityUUID, crl.Number, crl.DER,
+		crl.ThisUpdate, crl.NextUpdate); err != nil {
+		return fmt.Errorf("failed to save CRL: %w", err)
+	}
+	return nil
+}
+
+// GetCRL returns the latest CRL of authority `authorityUUID`, or nil if it
+// has none yet.
+func (pg *Postgres) GetCRL(authorityUUID string) (*db.CRL, error) {
+	crl := &db.CRL{}
+	query := `
+SELECT authority_uuid, number, der, this_update, next_update
+FROM crls
+WHERE authority_uuid = $1`
+	if err := pg.QueryRow(query, authorityUUID).Scan(&crl.AuthorityUUID,
+		&crl.Number, &crl.DER, &crl.ThisUpdate, &crl.NextUpdate); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query for CRL: %w", err)
+	}
+	return crl, nil
+}
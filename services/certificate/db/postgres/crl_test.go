@@ -0,0 +1,126 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var mockCRL = &db.CRL{
+	AuthorityUUID: mockAuthority.UUID,
+	Number:        3,
+	DER:           []byte("mock_crl"),
+	ThisUpdate:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	NextUpdate:    time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+}
+
+func TestPostgres_NextCRLNumber(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectQuery(`
+^UPDATE authorities
+SET crl_number = crl_number \+ 1
+WHERE uuid = \$1
+RETURNING crl_number`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"crl_number"}).AddRow(4))
+
+		number, err := pg.NextCRLNumber(mockAuthority.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), number)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_no_rows_returned", func(t *testing.T) {
+		mock.ExpectQuery(`
+^UPDATE authorities`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"crl_number"}))
+
+		_, err := pg.NextCRLNumber(mockAuthority.UUID)
+		assert.NotNil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_SaveCRL(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectExec(`
+^INSERT INTO crls (.+)
+VALUES (.+)
+ON CONFLICT \(authority_uuid\) DO UPDATE
+SET (.+)
+WHERE crls.number < \$2`).
+			WithArgs(mockCRL.AuthorityUUID, mockCRL.Number, mockCRL.DER,
+				mockCRL.ThisUpdate, mockCRL.NextUpdate).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, pg.SaveCRL(mockCRL))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_exec", func(t *testing.T) {
+		mock.ExpectExec(`
+^INSERT INTO crls (.+)`).
+			WithArgs(mockCRL.AuthorityUUID, mockCRL.Number, mockCRL.DER,
+				mockCRL.ThisUpdate, mockCRL.NextUpdate).
+			WillReturnError(errors.New("mock_error"))
+
+		assert.NotNil(t, pg.SaveCRL(mockCRL))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetCRL(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"authority_uuid", "number", "der",
+			"this_update", "next_update"}).
+			AddRow(mockCRL.AuthorityUUID, mockCRL.Number, mockCRL.DER,
+				mockCRL.ThisUpdate, mockCRL.NextUpdate)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM crls
+WHERE authority_uuid = \$1`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(rows)
+
+		crl, err := pg.GetCRL(mockAuthority.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, mockCRL, crl)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_none", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM crls`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"authority_uuid"}))
+
+		crl, err := pg.GetCRL(mockAuthority.UUID)
+		assert.Nil(t, err)
+		assert.Nil(t, crl)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM crls`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnError(errors.New("mock_error"))
+
+		crl, err := pg.GetCRL(mockAuthority.UUID)
+		assert.NotNil(t, err)
+		assert.Nil(t, crl)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"certificate/db"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// addCertEvent appends an `event` of certificate `certUUID` made by `actor`
+// to the certificate history, with the changed fields before and after the
+// change. `oldValue` is nil for events without a previous state.
+func addCertEvent(tx *sql.Tx, certUUID, actor, event string, oldValue, newValue map[string]any) error {
+	var encodedOld any
+	if oldValue != nil {
+		encoded, err := json.Marshal(oldValue)
+		if err != nil {
+			return fmt.Errorf("failed to marshal old value: %w", err)
+		}
+		encodedOld = string(encoded)
+	}
+	encodedNew, err := json.Marshal(newValue)
+	if err != nil {
+		return fmt.Errorf("failed to marshal new value: %w", err)
+	}
+
+	query := `
+INSERT INTO certificate_events (cert_uuid, actor, event, old_value, new_value)
+VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(query, certUUID, actor, event, encodedOld, string(encodedNew)); err != nil {
+		return fmt.Errorf("failed to insert certificate event: %w", err)
+	}
+	return nil
+}
+
+// GetCertHistory returns the events of certificate `uuid` belonging to
+// `userUUID`, oldest first. The history stays readable after the user is
+// deactivated. It returns a *db.ValidationError if the certificate does not
+// exist, and db.ErrForbidden if `principal` may not read it, see
+// authorizeCertRead.
+func (pg *Postgres) GetCertHistory(uuid, userUUID string, principal *db.Principal) ([]*db.CertEvent, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := authorizeCertRead(tx, principal, uuid, userUUID); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+SELECT uuid, cert_uuid, actor, event, COALESCE(old_value, 'null'), new_value,
+	created_at
+FROM certificate_events
+WHERE cert_uuid = $1
+ORDER BY created_at`
+	rows, err := tx.Query(query, uuid)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to query: %w", err), tx.Rollback())
+	}
+	events := []*db.CertEvent{}
+	for rows.Next() {
+		event := &db.CertEvent{}
+		if errScan := rows.Scan(&event.UUID, &event.CertUUID, &event.Actor,
+			&event.Event, &event.OldValue, &event.NewValue,
+			&event.CreatedAt); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			events = append(events, event)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose), tx.Rollback())
+	}
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,112 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"encoding/json"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPostgres_GetCertHistory(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		expected := []*db.CertEvent{
+			{
+				UUID:      "3e1a7b4c-7f2d-4c1e-9a6b-0d8f5e2c1a90",
+				CertUUID:  mockCert0.UUID,
+				Actor:     mockUser.UUID,
+				Event:     db.CertEventAdded,
+				OldValue:  json.RawMessage(`null`),
+				NewValue:  json.RawMessage(`{"active":true,"status":"issued"}`),
+				CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				UUID:      "8b2f6d1e-4a3c-4e5f-b7a8-9c0d1e2f3a4b",
+				CertUUID:  mockCert0.UUID,
+				Actor:     "renewal",
+				Event:     db.CertEventDeactivated,
+				OldValue:  json.RawMessage(`{"active":true}`),
+				NewValue:  json.RawMessage(`{"active":false}`),
+				CreatedAt: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+			},
+		}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows([]string{"uuid", "cert_uuid", "actor", "event",
+			"old_value", "new_value", "created_at"})
+		for _, event := range expected {
+			rows.AddRow(event.UUID, event.CertUUID, event.Actor, event.Event,
+				[]byte(event.OldValue), []byte(event.NewValue), event.CreatedAt)
+		}
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificate_events
+WHERE cert_uuid = \$1
+ORDER BY created_at`).
+			WithArgs(mockCert0.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		events, err := pg.GetCertHistory(mockCert0.UUID, mockUser.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, events)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_admin_inactive_user", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, "mock_inactive_user_uuid", "")
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificate_events`).
+			WithArgs(mockCert0.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid", "cert_uuid", "actor",
+				"event", "old_value", "new_value", "created_at"}))
+		mock.ExpectCommit()
+
+		events, err := pg.GetCertHistory(mockCert0.UUID, "mock_inactive_user_uuid", mockAdmin)
+		assert.Nil(t, err)
+		assert.Empty(t, events)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_team_viewer", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, "other_user_uuid", mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificate_events`).
+			WithArgs(mockCert0.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid", "cert_uuid", "actor",
+				"event", "old_value", "new_value", "created_at"}))
+		mock.ExpectCommit()
+
+		events, err := pg.GetCertHistory(mockCert0.UUID, "other_user_uuid", mockPrincipal)
+		assert.Nil(t, err)
+		assert.Empty(t, events)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_cert_not_found_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"team_uuid"}))
+		mock.ExpectRollback()
+
+		events, err := pg.GetCertHistory(mockCert0.UUID, mockUser.UUID, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "uuid", validationErr.Field)
+		assert.Nil(t, events)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"certificate/db"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetExpiringCerts returns the active issued certificates that expire after
+// `now` but within `thresholdDays` days of it, and that were not notified
+// about at `thresholdDays` or a lower threshold yet.
+func (pg *Postgres) GetExpiringCerts(now time.Time, thresholdDays int) ([]*db.Cert, error) {
+	query := `
+SELECT ` + certColumns + `
+FROM certificates c
+WHERE active AND status = 'issued' AND not_after > $1 AND not_after <= $2
+	AND NOT EXISTS (
+		SELECT 1 FROM expiry_notifications n
+		WHERE n.cert_uuid = c.uuid AND n.threshold_days <= $3)
+ORDER BY not_after`
+	rows, err := pg.Query(query, now, now.AddDate(0, 0, thresholdDays), thresholdDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	var certs []*db.Cert
+	for rows.Next() {
+		if cert, errScan := scanCert(rows); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			certs = append(certs, cert)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	return certs, err
+}
+
+// AddExpiryNotification records that certificate `certUUID` was notified
+// about at `thresholdDays`, so that GetExpiringCerts skips it from then on.
+func (pg *Postgres) AddExpiryNotification(certUUID string, thresholdDays int) error {
+	query := `
+INSERT INTO expiry_notifications (cert_uuid, threshold_days)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING`
+	if _, err := pg.Exec(query, certUUID, thresholdDays); err != nil {
+		return fmt.Errorf("failed to insert expiry notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPostgres_GetExpiringCerts(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates c
+WHERE active AND status = 'issued' AND (.+)
+	AND NOT EXISTS \(
+		SELECT 1 FROM expiry_notifications n
+		WHERE (.+)\)
+ORDER BY not_after`).
+			WithArgs(now, now.AddDate(0, 0, 7), 7).
+			WillReturnRows(rows)
+
+		certs, err := pg.GetExpiringCerts(now, 7)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.Cert{publicCert(mockCert0)}, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates c`).
+			WithArgs(now, now.AddDate(0, 0, 7), 7).
+			WillReturnError(errors.New("mock_error"))
+
+		certs, err := pg.GetExpiringCerts(now, 7)
+		assert.NotNil(t, err)
+		assert.Nil(t, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_AddExpiryNotification(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectExec(`
+^INSERT INTO expiry_notifications (.+)
+VALUES (.+)
+ON CONFLICT DO NOTHING`).
+			WithArgs(mockCert0.UUID, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.Nil(t, pg.AddExpiryNotification(mockCert0.UUID, 7))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_exec", func(t *testing.T) {
+		mock.ExpectExec(`
+^INSERT INTO expiry_notifications (.+)`).
+			WithArgs(mockCert0.UUID, 7).
+			WillReturnError(errors.New("mock_error"))
+
+		assert.NotNil(t, pg.AddExpiryNotification(mockCert0.UUID, 7))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"certificate/encrypter"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// rotateBatchSize is the number of rows re-wrapped per transaction by
+// RotateMasterKey.
+const rotateBatchSize = 100
+
+// encryptedTables are the tables storing private keys encrypted with
+// pg.KeyEncrypter.
+var encryptedTables = []string{"certificates", "authorities"}
+
+// encryptPrivateKey seals `privateKey` with pg.KeyEncrypter.
+func (pg *Postgres) encryptPrivateKey(privateKey string) (*encrypter.EncryptedKey, error) {
+	encrypted, err := pg.KeyEncrypter.Encrypt([]byte(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+	return encrypted, nil
+}
+
+// decryptPrivateKey opens `encrypted` with pg.KeyEncrypter.
+func (pg *Postgres) decryptPrivateKey(encrypted *encrypter.EncryptedKey) (string, error) {
+	privateKey, err := pg.KeyEncrypter.Decrypt(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return string(privateKey), nil
+}
+
+// RotateMasterKey re-wraps the data keys of all private keys that are not
+// wrapped with the current master key of pg.KeyEncrypter, and returns the
+// number of rows updated.
+func (pg *Postgres) RotateMasterKey() (int, error) {
+	total := 0
+	for _, table := range encryptedTables {
+		for {
+			count, err := pg.rotateMasterKeyBatch(table)
+			total += count
+			if err != nil {
+				return total, err
+			}
+			if count < rotateBatchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// rotateMasterKeyBatch re-wraps up to `rotateBatchSize` data keys of `table`
+// in a single transaction and returns the number of rows updated.
+func (pg *Postgres) rotateMasterKeyBatch(table string) (int, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	// lock a batch of rows wrapped with other master keys
+	query := `
+SELECT uuid, data_key, master_key_id FROM ` + table + `
+WHERE master_key_id != $1
+LIMIT $2
+FOR UPDATE`
+	rows, err := tx.Query(query, pg.KeyEncrypter.MasterKeyID(), rotateBatchSize)
+	if err != nil {
+		return 0, errors.Join(fmt.Errorf("failed to query: %w", err), tx.Rollback())
+	}
+	var uuids []string
+	var keys []*encrypter.EncryptedKey
+	for rows.Next() {
+		var uuid string
+		key := &encrypter.EncryptedKey{}
+		if errScan := rows.Scan(&uuid, &key.WrappedDataKey, &key.MasterKeyID); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			uuids = append(uuids, uuid)
+			keys = append(keys, key)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		err = errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	if err != nil {
+		return 0, errors.Join(err, tx.Rollback())
+	}
+
+	// re-wrap each data key with the current master key
+	query = `
+UPDATE ` + table + `
+SET data_key = $2, master_key_id = $3
+WHERE uuid = $1`
+	for i, key := range keys {
+		rewrapped, err := pg.KeyEncrypter.Rewrap(key)
+		if err != nil {
+			return 0, errors.Join(fmt.Errorf("failed to rewrap data key of %s: %w", uuids[i], err), tx.Rollback())
+		}
+		if _, err := tx.Exec(query, uuids[i], rewrapped.WrappedDataKey, rewrapped.MasterKeyID); err != nil {
+			return 0, errors.Join(fmt.Errorf("failed to update data key of %s: %w", uuids[i], err), tx.Rollback())
+		}
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return len(keys), nil
+}
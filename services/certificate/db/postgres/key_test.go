@@ -0,0 +1,84 @@
+package postgres_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgres_RotateMasterKey(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+
+		rows := sqlmock.NewRows([]string{"uuid", "data_key", "master_key_id"}).
+			AddRow(mockCert0.UUID, []byte("old_data_key_0"), "old_master_key_id").
+			AddRow(mockCert1.UUID, []byte("old_data_key_1"), "old_master_key_id")
+		mock.ExpectQuery(`
+^SELECT (.+) FROM certificates
+WHERE master_key_id != (.+)
+LIMIT (.+)
+FOR UPDATE`).
+			WithArgs(mockMasterKeyID, 100).
+			WillReturnRows(rows)
+
+		for _, uuid := range []string{mockCert0.UUID, mockCert1.UUID} {
+			mock.ExpectExec(`
+^UPDATE certificates
+SET data_key = (.+), master_key_id = (.+)
+WHERE uuid = (.+)`).
+				WithArgs(uuid, mockDataKey, mockMasterKeyID).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+
+		mock.ExpectCommit()
+
+		mock.ExpectBegin()
+		rows = sqlmock.NewRows([]string{"uuid", "data_key", "master_key_id"}).
+			AddRow(mockAuthority.UUID, []byte("old_data_key_2"), "old_master_key_id")
+		mock.ExpectQuery(`
+^SELECT (.+) FROM authorities
+WHERE master_key_id != (.+)
+LIMIT (.+)
+FOR UPDATE`).
+			WithArgs(mockMasterKeyID, 100).
+			WillReturnRows(rows)
+		mock.ExpectExec(`
+^UPDATE authorities
+SET data_key = (.+), master_key_id = (.+)
+WHERE uuid = (.+)`).
+			WithArgs(mockAuthority.UUID, mockDataKey, mockMasterKeyID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		count, err := pg.RotateMasterKey()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_update_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+
+		rows := sqlmock.NewRows([]string{"uuid", "data_key", "master_key_id"}).
+			AddRow(mockCert0.UUID, []byte("old_data_key_0"), "old_master_key_id")
+		mock.ExpectQuery(`
+^SELECT (.+) FROM certificates
+WHERE (.+)*`).
+			WithArgs(mockMasterKeyID, 100).
+			WillReturnRows(rows)
+		mock.ExpectExec(`
+^UPDATE certificates
+SET (.+)*
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, mockDataKey, mockMasterKeyID).
+			WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		count, err := pg.RotateMasterKey()
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, count)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"certificate/db"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"regexp"
+	"sort"
+)
+
+const (
+	// maxLabels is the maximum number of labels of a certificate.
+	maxLabels = 64
+	// maxLabelValueLength is the maximum length of a label value.
+	maxLabelValueLength = 255
+)
+
+// labelKeyRegexp matches valid label keys, like "env" or "example.com/team".
+var labelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,62}$`)
+
+// checkLabels returns a *db.ValidationError if `labels` has too many labels,
+// an invalid key, or an empty or too long value.
+func checkLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return &db.ValidationError{Field: "labels", Err: fmt.Errorf("more than %d labels", maxLabels)}
+	}
+	for key, value := range labels {
+		if !labelKeyRegexp.MatchString(key) {
+			return &db.ValidationError{Field: "labels", Err: fmt.Errorf("invalid key %q", key)}
+		}
+		if value == "" || len(value) > maxLabelValueLength {
+			return &db.ValidationError{Field: "labels", Err: fmt.Errorf("value of %q must be 1 to %d characters", key, maxLabelValueLength)}
+		}
+	}
+	return nil
+}
+
+// marshalLabels encodes `labels` as a JSON object for a JSONB column.
+func marshalLabels(labels map[string]string) (string, error) {
+	if labels == nil {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(labels)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// SetCertLabels sets the labels of certificate `uuid` belonging to `userUUID`
+// to the non-empty values of `labels`, removes the labels with empty values,
+// and keeps the other labels. It returns the updated certificate, or
+// db.ErrForbidden if `principal` may not manage the certificate, see
+// authorizeCert. The change is recorded in the certificate history as done by
+// `principal`.
+func (pg *Postgres) SetCertLabels(uuid, userUUID string, labels map[string]string, principal *db.Principal) (*db.Cert, error) {
+	set, remove := map[string]string{}, []string{}
+	for key, value := range labels {
+		if value == "" {
+			remove = append(remove, key)
+		} else {
+			set[key] = value
+		}
+	}
+	sort.Strings(remove)
+	if err := checkLabels(set); err != nil {
+		return nil, err
+	}
+	encoded, err := marshalLabels(set)
+	if err != nil {
+		return nil, err
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := authorizeCert(tx, principal, uuid, userUUID); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// merge the labels, the result must still be within maxLabels, the
+	// subquery still sees the labels from before the update
+	query := `
+UPDATE certificates
+SET labels = (labels || $3::jsonb) - $4::text[]
+WHERE uuid = $1 AND user_uuid = $2
+RETURNING ` + certColumns + `,
+	(SELECT old.labels FROM certificates old WHERE old.uuid = $1)`
+	var oldLabels []byte
+	cert, err := scanCert(tx.QueryRow(query, uuid, userUUID, encoded, pq.Array(remove)), &oldLabels)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = &db.ValidationError{Field: "uuid", Err: errors.New("certificate does not exist")}
+		} else {
+			err = fmt.Errorf("failed to update labels: %w", err)
+		}
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	if err := checkLabels(cert.Labels); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	if err := addCertEvent(tx, uuid, principal.UUID, db.CertEventLabeled,
+		map[string]any{"labels": json.RawMessage(oldLabels)},
+		map[string]any{"labels": cert.Labels}); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return cert, nil
+}
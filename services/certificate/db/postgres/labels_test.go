@@ -0,0 +1,119 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPostgres_SetCertLabels(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		labeled := *mockCert0
+		labeled.Labels = map[string]string{"env": "prod", "service": "checkout"}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(append(certColumns, "old_labels")).
+			AddRow(append(certValues(&labeled), []byte(`{"team":"payments"}`))...)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET labels = \(labels \|\| \$3::jsonb\) - \$4::text\[\]
+WHERE (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID, `{"env":"prod","service":"checkout"}`,
+				pq.Array([]string{"team"})).
+			WillReturnRows(rows)
+		mock.ExpectExec(`
+^INSERT INTO certificate_events (.+)
+VALUES (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID, db.CertEventLabeled,
+				`{"labels":{"team":"payments"}}`, `{"labels":{"env":"prod","service":"checkout"}}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		cert, err := pg.SetCertLabels(mockCert0.UUID, mockUser.UUID,
+			map[string]string{"env": "prod", "service": "checkout", "team": ""}, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(&labeled), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_team_operator", func(t *testing.T) {
+		labeled := *mockCert0
+		labeled.UserUUID = "mock_inactive_user_uuid"
+		labeled.TeamUUID = mockTeam.UUID
+		labeled.Labels = map[string]string{"env": "prod"}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, labeled.UserUUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		rows := sqlmock.NewRows(append(certColumns, "old_labels")).
+			AddRow(append(certValues(&labeled), []byte(`{}`))...)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET labels = (.+)`).
+			WithArgs(mockCert0.UUID, labeled.UserUUID, `{"env":"prod"}`, pq.Array([]string{})).
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventLabeled)
+		mock.ExpectCommit()
+
+		cert, err := pg.SetCertLabels(mockCert0.UUID, labeled.UserUUID, map[string]string{"env": "prod"}, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(&labeled), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_team_viewer_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertLabels(mockCert0.UUID, mockUser.UUID, map[string]string{"env": "prod"}, mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_cert_not_found_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"team_uuid"}))
+		mock.ExpectRollback()
+
+		cert, err := pg.SetCertLabels(mockCert0.UUID, mockUser.UUID, map[string]string{"env": "prod"}, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "uuid", validationErr.Field)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_invalid_key", func(t *testing.T) {
+		cert, err := pg.SetCertLabels(mockCert0.UUID, mockUser.UUID, map[string]string{"-env": "prod"}, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "labels", validationErr.Field)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_value_too_long", func(t *testing.T) {
+		cert, err := pg.SetCertLabels(mockCert0.UUID, mockUser.UUID, map[string]string{"env": strings.Repeat("a", 256)}, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "labels", validationErr.Field)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
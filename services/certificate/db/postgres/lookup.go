@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"errors"
+	"fmt"
+)
+
+// GetCertsByFingerprint returns the public metadata of the certificates with
+// SHA-256 fingerprint `fingerprint`, hex encoded and optionally separated by
+// colons. Admins get the certificates of all users, other principals only
+// their own and those of their teams.
+func (pg *Postgres) GetCertsByFingerprint(fingerprint string, principal *db.Principal) ([]*db.Cert, error) {
+	normalized, err := pki.NormalizeFingerprint(fingerprint)
+	if err != nil {
+		return nil, &db.ValidationError{Field: "fingerprint", Err: err}
+	}
+	query := `
+SELECT ` + certColumns + `
+FROM certificates
+WHERE fingerprint = $1`
+	return pg.queryCerts(principal, query, normalized)
+}
+
+// GetCertsByIssuerSerial returns the public metadata of the certificates
+// issued by `issuer`, a distinguished name as in db.Cert, with hex encoded
+// serial number `serialNumber`. Admins get the certificates of all users,
+// other principals only their own and those of their teams.
+func (pg *Postgres) GetCertsByIssuerSerial(issuer, serialNumber string, principal *db.Principal) ([]*db.Cert, error) {
+	normalized, err := pki.NormalizeSerialNumber(serialNumber)
+	if err != nil {
+		return nil, &db.ValidationError{Field: "serial_number", Err: err}
+	}
+	query := `
+SELECT ` + certColumns + `
+FROM certificates
+WHERE issuer = $1 AND serial_number = $2`
+	return pg.queryCerts(principal, query, issuer, normalized)
+}
+
+// queryCerts returns the certificates of the rows of `query`, which selects
+// `certColumns` without ordering them, that `principal` may read, ordered by
+// creation. Principals read the certificates of the teams they are members
+// of, directly or as an admin or owner of the organization.
+func (pg *Postgres) queryCerts(principal *db.Principal, query string, args ...any) ([]*db.Cert, error) {
+	if principal == nil {
+		return nil, fmt.Errorf("no principal: %w", db.ErrForbidden)
+	}
+	if !principal.Admin {
+		args = append(args, principal.UUID, db.RoleAdmin, db.RoleOwner)
+		query += fmt.Sprintf(`
+	AND (user_uuid = $%[1]d OR team_uuid IN (
+		SELECT team_uuid FROM team_members WHERE user_uuid = $%[1]d
+		UNION
+		SELECT t.uuid FROM teams t
+		JOIN org_members om ON om.org_uuid = t.org_uuid
+		WHERE om.user_uuid = $%[1]d AND om.role IN ($%[2]d, $%[3]d)))`,
+			len(args)-2, len(args)-1, len(args))
+	}
+	query += "\nORDER BY created_at, uuid"
+	rows, err := pg.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	certs := []*db.Cert{}
+	for rows.Next() {
+		if cert, errScan := scanCert(rows); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			certs = append(certs, cert)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	return certs, err
+}
@@ -0,0 +1,113 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPostgres_GetCertsByFingerprint(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE fingerprint = (.+)`).
+			WithArgs(mockCert0.Fingerprint).
+			WillReturnRows(rows)
+
+		certs, err := pg.GetCertsByFingerprint(strings.ToUpper(mockCert0.Fingerprint), mockAdmin)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.Cert{publicCert(mockCert0)}, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_own_and_team_certs", func(t *testing.T) {
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE fingerprint = \$1
+	AND \(user_uuid = \$2 OR team_uuid IN \(
+		SELECT team_uuid FROM team_members WHERE user_uuid = \$2
+		UNION
+		SELECT (.+)
+		WHERE om.user_uuid = \$2 AND om.role IN \(\$3, \$4\)\)\)
+ORDER BY created_at, uuid`).
+			WithArgs(mockCert0.Fingerprint, mockUser.UUID, db.RoleAdmin, db.RoleOwner).
+			WillReturnRows(rows)
+
+		certs, err := pg.GetCertsByFingerprint(mockCert0.Fingerprint, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.Cert{publicCert(mockCert0)}, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_not_found", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE fingerprint = (.+)`).
+			WithArgs(mockCert0.Fingerprint).
+			WillReturnRows(sqlmock.NewRows(certColumns))
+
+		certs, err := pg.GetCertsByFingerprint(mockCert0.Fingerprint, mockAdmin)
+		assert.Nil(t, err)
+		assert.Empty(t, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_invalid_fingerprint", func(t *testing.T) {
+		certs, err := pg.GetCertsByFingerprint("fingerprint", mockAdmin)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "fingerprint", validationErr.Field)
+		assert.Nil(t, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE fingerprint = (.+)`).
+			WithArgs(mockCert0.Fingerprint).
+			WillReturnError(errors.New("mock_error"))
+
+		certs, err := pg.GetCertsByFingerprint(mockCert0.Fingerprint, mockAdmin)
+		assert.NotNil(t, err)
+		assert.Nil(t, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetCertsByIssuerSerial(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE issuer = (.+) AND serial_number = (.+)`).
+			WithArgs(mockCert0.Issuer, mockCert0.SerialNumber).
+			WillReturnRows(rows)
+
+		certs, err := pg.GetCertsByIssuerSerial(mockCert0.Issuer, "00:"+strings.ToUpper(mockCert0.SerialNumber), mockAdmin)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.Cert{publicCert(mockCert0)}, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_invalid_serial_number", func(t *testing.T) {
+		certs, err := pg.GetCertsByIssuerSerial(mockCert0.Issuer, "serial", mockAdmin)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "serial_number", validationErr.Field)
+		assert.Nil(t, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,575 @@
+package postgres
+
+import (
+	"certificate/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// orgNameIndex is the unique index that keeps organizations from sharing a
+// name.
+const orgNameIndex = "org_name_idx"
+
+// teamNameIndex is the unique index that keeps the teams of an organization
+// from sharing a name.
+const teamNameIndex = "team_name_idx"
+
+// orgRole returns the role of `userUUID` in organization `orgUUID`, or an
+// empty role if the user is not a member. It returns a *db.ValidationError if
+// the organization does not exist.
+func orgRole(tx *sql.Tx, orgUUID, userUUID string) (string, error) {
+	query := `
+SELECT COALESCE(m.role, '')
+FROM organizations o
+LEFT JOIN org_members m ON m.org_uuid = o.uuid AND m.user_uuid = $2
+WHERE o.uuid = $1`
+	var role string
+	if err := tx.QueryRow(query, orgUUID, userUUID).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", &db.ValidationError{Field: "org_uuid", Err: errors.New("organization does not exist")}
+		}
+		return "", fmt.Errorf("failed to query for organization role: %w", err)
+	}
+	return role, nil
+}
+
+// teamRole returns the organization of team `teamUUID` and the role of
+// `userUUID` in the team, which is its role in the organization if the user
+// is an admin or owner of it, or an empty role if the user is not a member.
+// It returns a *db.ValidationError if the team does not exist.
+func teamRole(tx *sql.Tx, teamUUID, userUUID string) (string, string, error) {
+	query := `
+SELECT t.org_uuid, COALESCE(tm.role, ''), COALESCE(om.role, '')
+FROM teams t
+LEFT JOIN team_members tm ON tm.team_uuid = t.uuid AND tm.user_uuid = $2
+LEFT JOIN org_members om ON om.org_uuid = t.org_uuid AND om.user_uuid = $2
+WHERE t.uuid = $1`
+	var orgUUID, role, orgRole string
+	if err := tx.QueryRow(query, teamUUID, userUUID).Scan(&orgUUID, &role, &orgRole); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", &db.ValidationError{Field: "team_uuid", Err: errors.New("team does not exist")}
+		}
+		return "", "", fmt.Errorf("failed to query for team role: %w", err)
+	}
+	if db.RoleAtLeast(orgRole, db.RoleAdmin) && !db.RoleAtLeast(role, orgRole) {
+		role = orgRole
+	}
+	return orgUUID, role, nil
+}
+
+// authorizeOrg returns the role of `principal` in organization `orgUUID`,
+// admins are owners of every organization. It returns db.ErrForbidden if the
+// role does not have the privileges of `minRole`.
+func authorizeOrg(tx *sql.Tx, principal *db.Principal, orgUUID, minRole string) (string, error) {
+	if principal == nil {
+		return "", fmt.Errorf("not allowed to access organization %s: %w", orgUUID, db.ErrForbidden)
+	}
+	role, err := orgRole(tx, orgUUID, principal.UUID)
+	if err != nil {
+		return "", err
+	}
+	if principal.Admin {
+		role = db.RoleOwner
+	}
+	if !db.RoleAtLeast(role, minRole) {
+		return "", fmt.Errorf("not allowed to access organization %s: %w", orgUUID, db.ErrForbidden)
+	}
+	return role, nil
+}
+
+// authorizeTeam returns the organization of team `teamUUID` and the role of
+// `principal` in the team, admins are owners of every team. It returns
+// db.ErrForbidden if the role does not have the privileges of `minRole`.
+func authorizeTeam(tx *sql.Tx, principal *db.Principal, teamUUID, minRole string) (string, string, error) {
+	if principal == nil {
+		return "", "", fmt.Errorf("not allowed to access team %s: %w", teamUUID, db.ErrForbidden)
+	}
+	orgUUID, role, err := teamRole(tx, teamUUID, principal.UUID)
+	if err != nil {
+		return "", "", err
+	}
+	if principal.Admin {
+		role = db.RoleOwner
+	}
+	if !db.RoleAtLeast(role, minRole) {
+		return "", "", fmt.Errorf("not allowed to access team %s: %w", teamUUID, db.ErrForbidden)
+	}
+	return orgUUID, role, nil
+}
+
+// checkRole checks that `role` is a known role that a member with role
+// `granterRole` may grant, it returns a *db.ValidationError if the role is
+// unknown and db.ErrForbidden if it is above `granterRole`.
+func checkRole(role, granterRole string) error {
+	if !db.RoleAtLeast(role, db.RoleViewer) {
+		return &db.ValidationError{Field: "role", Err: fmt.Errorf("unknown role %q", role)}
+	}
+	if !db.RoleAtLeast(granterRole, role) {
+		return fmt.Errorf("not allowed to grant role %s: %w", role, db.ErrForbidden)
+	}
+	return nil
+}
+
+// checkOtherOwner checks that organization `orgUUID` has an owner other than
+// `userUUID`, it returns a *db.ValidationError of `field` otherwise. The
+// other owner is locked, so that two owners cannot leave at the same time.
+func checkOtherOwner(tx *sql.Tx, field, orgUUID, userUUID string) error {
+	query := `
+SELECT user_uuid FROM org_members
+WHERE org_uuid = $1 AND role = $2 AND user_uuid <> $3
+LIMIT 1
+FOR UPDATE`
+	if err := tx.QueryRow(query, orgUUID, db.RoleOwner, userUUID).Scan(&userUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &db.ValidationError{Field: field, Err: errors.New("organization must keep an owner")}
+		}
+		return fmt.Errorf("failed to query for owners: %w", err)
+	}
+	return nil
+}
+
+// queryMembers returns the members of `query`, which selects the user_uuid,
+// role and created_at of members.
+func queryMembers(tx *sql.Tx, query string, args ...any) ([]*db.Member, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	members := []*db.Member{}
+	for rows.Next() {
+		member := &db.Member{}
+		if errScan := rows.Scan(&member.UserUUID, &member.Role, &member.CreatedAt); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			members = append(members, member)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddOrg adds organization `org`, owned by the user of `principal`, and fills
+// the db-generated fields like `UUID` and `CreatedAt` of `org`. It errors out
+// if the user does not exist or is not active, and returns db.ErrConflict if
+// another organization has the same name.
+func (pg *Postgres) AddOrg(org *db.Org, principal *db.Principal) error {
+	if principal == nil {
+		return fmt.Errorf("not allowed to add organizations: %w", db.ErrForbidden)
+	}
+	if org.Name == "" {
+		return &db.ValidationError{Field: "name", Err: errors.New("must not be empty")}
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := checkUser(tx, principal.UUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+INSERT INTO organizations (name)
+VALUES ($1)
+RETURNING uuid, created_at`
+	if err := tx.QueryRow(query, org.Name).Scan(&org.UUID, &org.CreatedAt); err != nil {
+		if isUniqueViolation(err, orgNameIndex) {
+			err = fmt.Errorf("organization %s already exists: %w", org.Name, db.ErrConflict)
+		} else {
+			err = fmt.Errorf("failed to insert organization: %w", err)
+		}
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// the creator owns the organization
+	query = `
+INSERT INTO org_members (org_uuid, user_uuid, role)
+VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(query, org.UUID, principal.UUID, db.RoleOwner); err != nil {
+		return errors.Join(fmt.Errorf("failed to insert organization owner: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetOrg returns organization `uuid`, it returns db.ErrForbidden if
+// `principal` is not a member of it.
+func (pg *Postgres) GetOrg(uuid string, principal *db.Principal) (*db.Org, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if _, err := authorizeOrg(tx, principal, uuid, db.RoleViewer); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	org := &db.Org{}
+	query := `
+SELECT uuid, name, created_at FROM organizations
+WHERE uuid = $1`
+	if err := tx.QueryRow(query, uuid).Scan(&org.UUID, &org.Name, &org.CreatedAt); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to query for organization: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return org, nil
+}
+
+// SetOrgMember adds `member` to organization `orgUUID`, or changes its role
+// if it is already a member, and fills the db-generated `CreatedAt` of
+// `member`. It errors out if the user does not exist or is not active, and
+// returns a *db.ValidationError if the role is unknown or the last owner
+// would be demoted. It returns db.ErrForbidden unless `principal` is an
+// admin of the organization with at least the old and new role of `member`.
+func (pg *Postgres) SetOrgMember(orgUUID string, member *db.Member, principal *db.Principal) error {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	role, err := authorizeOrg(tx, principal, orgUUID, db.RoleAdmin)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if err := checkRole(member.Role, role); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if err := checkUser(tx, member.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	current, err := orgRole(tx, orgUUID, member.UserUUID)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if current != "" && !db.RoleAtLeast(role, current) {
+		return errors.Join(fmt.Errorf("not allowed to change the role of %s: %w", current, db.ErrForbidden), tx.Rollback())
+	}
+	if current == db.RoleOwner && member.Role != db.RoleOwner {
+		if err := checkOtherOwner(tx, "role", orgUUID, member.UserUUID); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+	}
+
+	query := `
+INSERT INTO org_members (org_uuid, user_uuid, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (org_uuid, user_uuid) DO UPDATE
+SET role = EXCLUDED.role
+RETURNING created_at`
+	if err := tx.QueryRow(query, orgUUID, member.UserUUID, member.Role).Scan(&member.CreatedAt); err != nil {
+		return errors.Join(fmt.Errorf("failed to set organization member: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// RemoveOrgMember removes `userUUID` from organization `orgUUID` and from all
+// of its teams. It returns a *db.ValidationError if the user is not a member
+// or is the last owner, and db.ErrForbidden unless `principal` is an admin of
+// the organization with at least the role of the user.
+func (pg *Postgres) RemoveOrgMember(orgUUID, userUUID string, principal *db.Principal) error {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	role, err := authorizeOrg(tx, principal, orgUUID, db.RoleAdmin)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	current, err := orgRole(tx, orgUUID, userUUID)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if current == "" {
+		return errors.Join(&db.ValidationError{Field: "user_uuid", Err: errors.New("user is not a member")}, tx.Rollback())
+	}
+	if !db.RoleAtLeast(role, current) {
+		return errors.Join(fmt.Errorf("not allowed to remove a member with role %s: %w", current, db.ErrForbidden), tx.Rollback())
+	}
+	if current == db.RoleOwner {
+		if err := checkOtherOwner(tx, "user_uuid", orgUUID, userUUID); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+	}
+
+	query := `
+DELETE FROM team_members
+WHERE user_uuid = $2 AND team_uuid IN (SELECT uuid FROM teams WHERE org_uuid = $1)`
+	if _, err := tx.Exec(query, orgUUID, userUUID); err != nil {
+		return errors.Join(fmt.Errorf("failed to delete team members: %w", err), tx.Rollback())
+	}
+	query = `
+DELETE FROM org_members
+WHERE org_uuid = $1 AND user_uuid = $2`
+	if _, err := tx.Exec(query, orgUUID, userUUID); err != nil {
+		return errors.Join(fmt.Errorf("failed to delete organization member: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetOrgMembers returns the members of organization `orgUUID`, oldest first.
+// It returns db.ErrForbidden if `principal` is not a member of it.
+func (pg *Postgres) GetOrgMembers(orgUUID string, principal *db.Principal) ([]*db.Member, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if _, err := authorizeOrg(tx, principal, orgUUID, db.RoleViewer); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+SELECT user_uuid, role, created_at
+FROM org_members
+WHERE org_uuid = $1
+ORDER BY created_at, user_uuid`
+	members, err := queryMembers(tx, query, orgUUID)
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return members, nil
+}
+
+// AddTeam adds `team` to organization `team.OrgUUID`, and fills the
+// db-generated fields like `UUID` and `CreatedAt` of `team`. It returns
+// db.ErrConflict if the organization has another team with the same name,
+// and db.ErrForbidden unless `principal` is an admin of the organization.
+func (pg *Postgres) AddTeam(team *db.Team, principal *db.Principal) error {
+	if team.Name == "" {
+		return &db.ValidationError{Field: "name", Err: errors.New("must not be empty")}
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if _, err := authorizeOrg(tx, principal, team.OrgUUID, db.RoleAdmin); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+INSERT INTO teams (org_uuid, name)
+VALUES ($1, $2)
+RETURNING uuid, created_at`
+	if err := tx.QueryRow(query, team.OrgUUID, team.Name).Scan(&team.UUID, &team.CreatedAt); err != nil {
+		if isUniqueViolation(err, teamNameIndex) {
+			err = fmt.Errorf("team %s already exists: %w", team.Name, db.ErrConflict)
+		} else {
+			err = fmt.Errorf("failed to insert team: %w", err)
+		}
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetTeams returns the teams of organization `orgUUID` ordered by name, it
+// returns db.ErrForbidden if `principal` is not a member of it.
+func (pg *Postgres) GetTeams(orgUUID string, principal *db.Principal) ([]*db.Team, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if _, err := authorizeOrg(tx, principal, orgUUID, db.RoleViewer); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+SELECT uuid, org_uuid, name, created_at
+FROM teams
+WHERE org_uuid = $1
+ORDER BY name`
+	rows, err := tx.Query(query, orgUUID)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to query: %w", err), tx.Rollback())
+	}
+	teams := []*db.Team{}
+	for rows.Next() {
+		team := &db.Team{}
+		if errScan := rows.Scan(&team.UUID, &team.OrgUUID, &team.Name, &team.CreatedAt); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			teams = append(teams, team)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose), tx.Rollback())
+	}
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return teams, nil
+}
+
+// SetTeamMember adds `member` to team `teamUUID`, or changes its role if it
+// is already a member, and fills the db-generated `CreatedAt` of `member`.
+// It returns a *db.ValidationError if the role is unknown or the user is not
+// a member of the organization of the team, and db.ErrForbidden unless
+// `principal` is an admin of the team with at least the old and new role of
+// `member`.
+func (pg *Postgres) SetTeamMember(teamUUID string, member *db.Member, principal *db.Principal) error {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	orgUUID, role, err := authorizeTeam(tx, principal, teamUUID, db.RoleAdmin)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if err := checkRole(member.Role, role); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if memberOrgRole, err := orgRole(tx, orgUUID, member.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	} else if memberOrgRole == "" {
+		return errors.Join(&db.ValidationError{Field: "user_uuid", Err: errors.New("user is not a member of the organization")}, tx.Rollback())
+	}
+	if _, current, err := teamRole(tx, teamUUID, member.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	} else if current != "" && !db.RoleAtLeast(role, current) {
+		return errors.Join(fmt.Errorf("not allowed to change the role of %s: %w", current, db.ErrForbidden), tx.Rollback())
+	}
+
+	query := `
+INSERT INTO team_members (team_uuid, user_uuid, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (team_uuid, user_uuid) DO UPDATE
+SET role = EXCLUDED.role
+RETURNING created_at`
+	if err := tx.QueryRow(query, teamUUID, member.UserUUID, member.Role).Scan(&member.CreatedAt); err != nil {
+		return errors.Join(fmt.Errorf("failed to set team member: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// RemoveTeamMember removes `userUUID` from team `teamUUID`. It returns a
+// *db.ValidationError if the user is not a member, and db.ErrForbidden
+// unless `principal` is an admin of the team with at least the role of the
+// user.
+func (pg *Postgres) RemoveTeamMember(teamUUID, userUUID string, principal *db.Principal) error {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	_, role, err := authorizeTeam(tx, principal, teamUUID, db.RoleAdmin)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if _, current, err := teamRole(tx, teamUUID, userUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	} else if !db.RoleAtLeast(role, current) {
+		return errors.Join(fmt.Errorf("not allowed to remove a member with role %s: %w", current, db.ErrForbidden), tx.Rollback())
+	}
+
+	query := `
+DELETE FROM team_members
+WHERE team_uuid = $1 AND user_uuid = $2`
+	res, err := tx.Exec(query, teamUUID, userUUID)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to delete team member: %w", err), tx.Rollback())
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to get rows affected: %w", err), tx.Rollback())
+	}
+	if count != 1 {
+		return errors.Join(&db.ValidationError{Field: "user_uuid", Err: errors.New("user is not a member")}, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetTeamMembers returns the members of team `teamUUID`, oldest first,
+// without the admins and owners of its organization, who are members of all
+// of its teams. It returns db.ErrForbidden if `principal` is not a member of
+// the team.
+func (pg *Postgres) GetTeamMembers(teamUUID string, principal *db.Principal) ([]*db.Member, error) {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if _, _, err := authorizeTeam(tx, principal, teamUUID, db.RoleViewer); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+SELECT user_uuid, role, created_at
+FROM team_members
+WHERE team_uuid = $1
+ORDER BY created_at, user_uuid`
+	members, err := queryMembers(tx, query, teamUUID)
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return members, nil
+}
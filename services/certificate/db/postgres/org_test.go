@@ -0,0 +1,574 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var mockOrg = &db.Org{
+	UUID:      "mock_org_uuid",
+	Name:      "mock_org",
+	CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+var mockTeam = &db.Team{
+	UUID:      "mock_team_uuid",
+	OrgUUID:   mockOrg.UUID,
+	Name:      "payments",
+	CreatedAt: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+}
+
+// mockMemberUUID is the UUID of the user whose membership is managed by
+// mockUser.
+const mockMemberUUID = "mock_member_uuid"
+
+// expectOrgRole expects the role of `userUUID` in organization `orgUUID` to
+// be queried and returns `role`.
+func expectOrgRole(mock sqlmock.Sqlmock, orgUUID, userUUID, role string) {
+	rows := sqlmock.NewRows([]string{"role"}).
+		AddRow(role)
+	mock.ExpectQuery(`
+^SELECT COALESCE\(m.role, ''\)
+FROM organizations o
+LEFT JOIN org_members m (.+)
+WHERE o.uuid = \$1`).
+		WithArgs(orgUUID, userUUID).
+		WillReturnRows(rows)
+}
+
+// expectTeamRole expects the role of `userUUID` in team `teamUUID` to be
+// queried and returns `role` and `orgRole`, its role in the organization.
+func expectTeamRole(mock sqlmock.Sqlmock, teamUUID, userUUID, role, orgRole string) {
+	rows := sqlmock.NewRows([]string{"org_uuid", "team_role", "org_role"}).
+		AddRow(mockOrg.UUID, role, orgRole)
+	mock.ExpectQuery(`
+^SELECT t.org_uuid, (.+)
+FROM teams t
+LEFT JOIN team_members tm (.+)
+LEFT JOIN org_members om (.+)
+WHERE t.uuid = \$1`).
+		WithArgs(teamUUID, userUUID).
+		WillReturnRows(rows)
+}
+
+func TestPostgres_AddOrg(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		org := &db.Org{Name: mockOrg.Name}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "created_at"}).
+			AddRow(mockOrg.UUID, mockOrg.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO organizations \(name\)
+VALUES \(\$1\)
+RETURNING uuid, created_at`).
+			WithArgs(mockOrg.Name).
+			WillReturnRows(rows)
+		mock.ExpectExec(`
+^INSERT INTO org_members \(org_uuid, user_uuid, role\)
+VALUES (.+)`).
+			WithArgs(mockOrg.UUID, mockUser.UUID, db.RoleOwner).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddOrg(org, mockPrincipal))
+		assert.Equal(t, mockOrg, org)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_duplicate_name_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^INSERT INTO organizations (.+)`).
+			WithArgs(mockOrg.Name).
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "org_name_idx"})
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, pg.AddOrg(&db.Org{Name: mockOrg.Name}, mockPrincipal), db.ErrConflict)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_empty_name", func(t *testing.T) {
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.AddOrg(&db.Org{}, mockPrincipal), &validationErr)
+		assert.Equal(t, "name", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetOrg(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"uuid", "name", "created_at"}).
+			AddRow(mockOrg.UUID, mockOrg.Name, mockOrg.CreatedAt)
+		mock.ExpectQuery(`
+^SELECT uuid, name, created_at FROM organizations
+WHERE uuid = \$1`).
+			WithArgs(mockOrg.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		org, err := pg.GetOrg(mockOrg.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, mockOrg, org)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_forbidden_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, "")
+		mock.ExpectRollback()
+
+		org, err := pg.GetOrg(mockOrg.UUID, mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, org)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_org_not_found_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT COALESCE\(m.role, ''\)
+FROM organizations o (.+)`).
+			WithArgs(mockOrg.UUID, mockAdmin.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"role"}))
+		mock.ExpectRollback()
+
+		org, err := pg.GetOrg(mockOrg.UUID, mockAdmin)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "org_uuid", validationErr.Field)
+		assert.Nil(t, org)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_SetOrgMember(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+	createdAt := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	t.Run("happy_path", func(t *testing.T) {
+		member := &db.Member{UserUUID: mockMemberUUID, Role: db.RoleOperator}
+
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleAdmin)
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockMemberUUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockMemberUUID).
+			WillReturnRows(rows)
+		expectOrgRole(mock, mockOrg.UUID, mockMemberUUID, db.RoleViewer)
+		rows = sqlmock.NewRows([]string{"created_at"}).
+			AddRow(createdAt)
+		mock.ExpectQuery(`
+^INSERT INTO org_members \(org_uuid, user_uuid, role\)
+VALUES \(\$1, \$2, \$3\)
+ON CONFLICT \(org_uuid, user_uuid\) DO UPDATE
+SET role = EXCLUDED.role
+RETURNING created_at`).
+			WithArgs(mockOrg.UUID, mockMemberUUID, db.RoleOperator).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.SetOrgMember(mockOrg.UUID, member, mockPrincipal))
+		assert.Equal(t, &db.Member{UserUUID: mockMemberUUID, Role: db.RoleOperator, CreatedAt: createdAt}, member)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_grant_above_own_role_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleAdmin)
+		mock.ExpectRollback()
+
+		member := &db.Member{UserUUID: mockMemberUUID, Role: db.RoleOwner}
+		assert.ErrorIs(t, pg.SetOrgMember(mockOrg.UUID, member, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_change_owner_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleAdmin)
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockMemberUUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockMemberUUID).
+			WillReturnRows(rows)
+		expectOrgRole(mock, mockOrg.UUID, mockMemberUUID, db.RoleOwner)
+		mock.ExpectRollback()
+
+		member := &db.Member{UserUUID: mockMemberUUID, Role: db.RoleViewer}
+		assert.ErrorIs(t, pg.SetOrgMember(mockOrg.UUID, member, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_demote_last_owner_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOwner)
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOwner)
+		mock.ExpectQuery(`
+^SELECT user_uuid FROM org_members
+WHERE org_uuid = \$1 AND role = \$2 AND user_uuid <> \$3
+LIMIT 1
+FOR UPDATE`).
+			WithArgs(mockOrg.UUID, db.RoleOwner, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"user_uuid"}))
+		mock.ExpectRollback()
+
+		member := &db.Member{UserUUID: mockUser.UUID, Role: db.RoleAdmin}
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetOrgMember(mockOrg.UUID, member, mockPrincipal), &validationErr)
+		assert.Equal(t, "role", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_unknown_role_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOwner)
+		mock.ExpectRollback()
+
+		member := &db.Member{UserUUID: mockMemberUUID, Role: "superuser"}
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetOrgMember(mockOrg.UUID, member, mockPrincipal), &validationErr)
+		assert.Equal(t, "role", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_operator_forbidden_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOperator)
+		mock.ExpectRollback()
+
+		member := &db.Member{UserUUID: mockMemberUUID, Role: db.RoleViewer}
+		assert.ErrorIs(t, pg.SetOrgMember(mockOrg.UUID, member, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_RemoveOrgMember(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOwner)
+		expectOrgRole(mock, mockOrg.UUID, mockMemberUUID, db.RoleOwner)
+		rows := sqlmock.NewRows([]string{"user_uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT user_uuid FROM org_members
+WHERE (.+)`).
+			WithArgs(mockOrg.UUID, db.RoleOwner, mockMemberUUID).
+			WillReturnRows(rows)
+		mock.ExpectExec(`
+^DELETE FROM team_members
+WHERE user_uuid = \$2 AND team_uuid IN \(SELECT uuid FROM teams WHERE org_uuid = \$1\)`).
+			WithArgs(mockOrg.UUID, mockMemberUUID).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`
+^DELETE FROM org_members
+WHERE org_uuid = \$1 AND user_uuid = \$2`).
+			WithArgs(mockOrg.UUID, mockMemberUUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.RemoveOrgMember(mockOrg.UUID, mockMemberUUID, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_last_owner_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockAdmin.UUID, "")
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOwner)
+		mock.ExpectQuery(`
+^SELECT user_uuid FROM org_members
+WHERE (.+)`).
+			WithArgs(mockOrg.UUID, db.RoleOwner, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"user_uuid"}))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.RemoveOrgMember(mockOrg.UUID, mockUser.UUID, mockAdmin), &validationErr)
+		assert.Equal(t, "user_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_not_member_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleAdmin)
+		expectOrgRole(mock, mockOrg.UUID, mockMemberUUID, "")
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.RemoveOrgMember(mockOrg.UUID, mockMemberUUID, mockPrincipal), &validationErr)
+		assert.Equal(t, "user_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetOrgMembers(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		members := []*db.Member{
+			{UserUUID: mockUser.UUID, Role: db.RoleOwner, CreatedAt: mockOrg.CreatedAt},
+			{UserUUID: mockMemberUUID, Role: db.RoleViewer, CreatedAt: mockTeam.CreatedAt},
+		}
+
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOwner)
+		rows := sqlmock.NewRows([]string{"user_uuid", "role", "created_at"})
+		for _, member := range members {
+			rows.AddRow(member.UserUUID, member.Role, member.CreatedAt)
+		}
+		mock.ExpectQuery(`
+^SELECT user_uuid, role, created_at
+FROM org_members
+WHERE org_uuid = \$1
+ORDER BY created_at, user_uuid`).
+			WithArgs(mockOrg.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		got, err := pg.GetOrgMembers(mockOrg.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, members, got)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_AddTeam(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		team := &db.Team{OrgUUID: mockOrg.UUID, Name: mockTeam.Name}
+
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleAdmin)
+		rows := sqlmock.NewRows([]string{"uuid", "created_at"}).
+			AddRow(mockTeam.UUID, mockTeam.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO teams \(org_uuid, name\)
+VALUES \(\$1, \$2\)
+RETURNING uuid, created_at`).
+			WithArgs(mockOrg.UUID, mockTeam.Name).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddTeam(team, mockPrincipal))
+		assert.Equal(t, mockTeam, team)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_duplicate_name_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOwner)
+		mock.ExpectQuery(`
+^INSERT INTO teams (.+)`).
+			WithArgs(mockOrg.UUID, mockTeam.Name).
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "team_name_idx"})
+		mock.ExpectRollback()
+
+		team := &db.Team{OrgUUID: mockOrg.UUID, Name: mockTeam.Name}
+		assert.ErrorIs(t, pg.AddTeam(team, mockPrincipal), db.ErrConflict)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_forbidden_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleOperator)
+		mock.ExpectRollback()
+
+		team := &db.Team{OrgUUID: mockOrg.UUID, Name: mockTeam.Name}
+		assert.ErrorIs(t, pg.AddTeam(team, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetTeams(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectOrgRole(mock, mockOrg.UUID, mockUser.UUID, db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"uuid", "org_uuid", "name", "created_at"}).
+			AddRow(mockTeam.UUID, mockTeam.OrgUUID, mockTeam.Name, mockTeam.CreatedAt)
+		mock.ExpectQuery(`
+^SELECT uuid, org_uuid, name, created_at
+FROM teams
+WHERE org_uuid = \$1
+ORDER BY name`).
+			WithArgs(mockOrg.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		teams, err := pg.GetTeams(mockOrg.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.Team{mockTeam}, teams)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_SetTeamMember(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+	createdAt := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	t.Run("happy_path_org_admin", func(t *testing.T) {
+		member := &db.Member{UserUUID: mockMemberUUID, Role: db.RoleOperator}
+
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, "", db.RoleAdmin)
+		expectOrgRole(mock, mockOrg.UUID, mockMemberUUID, db.RoleViewer)
+		expectTeamRole(mock, mockTeam.UUID, mockMemberUUID, "", db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"created_at"}).
+			AddRow(createdAt)
+		mock.ExpectQuery(`
+^INSERT INTO team_members \(team_uuid, user_uuid, role\)
+VALUES \(\$1, \$2, \$3\)
+ON CONFLICT \(team_uuid, user_uuid\) DO UPDATE
+SET role = EXCLUDED.role
+RETURNING created_at`).
+			WithArgs(mockTeam.UUID, mockMemberUUID, db.RoleOperator).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.SetTeamMember(mockTeam.UUID, member, mockPrincipal))
+		assert.Equal(t, &db.Member{UserUUID: mockMemberUUID, Role: db.RoleOperator, CreatedAt: createdAt}, member)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_not_org_member_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleAdmin, db.RoleViewer)
+		expectOrgRole(mock, mockOrg.UUID, mockMemberUUID, "")
+		mock.ExpectRollback()
+
+		member := &db.Member{UserUUID: mockMemberUUID, Role: db.RoleViewer}
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetTeamMember(mockTeam.UUID, member, mockPrincipal), &validationErr)
+		assert.Equal(t, "user_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_team_operator_forbidden_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		mock.ExpectRollback()
+
+		member := &db.Member{UserUUID: mockMemberUUID, Role: db.RoleViewer}
+		assert.ErrorIs(t, pg.SetTeamMember(mockTeam.UUID, member, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_RemoveTeamMember(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleAdmin, db.RoleViewer)
+		expectTeamRole(mock, mockTeam.UUID, mockMemberUUID, db.RoleOperator, db.RoleViewer)
+		mock.ExpectExec(`
+^DELETE FROM team_members
+WHERE team_uuid = \$1 AND user_uuid = \$2`).
+			WithArgs(mockTeam.UUID, mockMemberUUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.RemoveTeamMember(mockTeam.UUID, mockMemberUUID, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_org_owner_forbidden_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleAdmin, db.RoleViewer)
+		expectTeamRole(mock, mockTeam.UUID, mockMemberUUID, db.RoleViewer, db.RoleOwner)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, pg.RemoveTeamMember(mockTeam.UUID, mockMemberUUID, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_not_member_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleAdmin, db.RoleViewer)
+		expectTeamRole(mock, mockTeam.UUID, mockMemberUUID, "", "")
+		mock.ExpectExec(`
+^DELETE FROM team_members
+WHERE (.+)`).
+			WithArgs(mockTeam.UUID, mockMemberUUID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.RemoveTeamMember(mockTeam.UUID, mockMemberUUID, mockPrincipal), &validationErr)
+		assert.Equal(t, "user_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetTeamMembers(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		members := []*db.Member{{UserUUID: mockMemberUUID, Role: db.RoleOperator, CreatedAt: mockTeam.CreatedAt}}
+
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"user_uuid", "role", "created_at"}).
+			AddRow(mockMemberUUID, db.RoleOperator, mockTeam.CreatedAt)
+		mock.ExpectQuery(`
+^SELECT user_uuid, role, created_at
+FROM team_members
+WHERE team_uuid = \$1
+ORDER BY created_at, user_uuid`).
+			WithArgs(mockTeam.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		got, err := pg.GetTeamMembers(mockTeam.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, members, got)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_team_not_found_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT t.org_uuid, (.+)`).
+			WithArgs(mockTeam.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"org_uuid", "team_role", "org_role"}))
+		mock.ExpectRollback()
+
+		members, err := pg.GetTeamMembers(mockTeam.UUID, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "team_uuid", validationErr.Field)
+		assert.Nil(t, members)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_not_member_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, "", db.RoleOperator)
+		mock.ExpectRollback()
+
+		members, err := pg.GetTeamMembers(mockTeam.UUID, mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, members)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"certificate/db"
+	"certificate/encrypter"
+	"certificate/pki"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+// AddPendingCert adds `cert` in pending state to the database if
+// `cert.UserUUID` exists and is active, and fills `cert` with db-generated
+// fields like `UUID` and `CreatedAt`. `cert` carries the generated private key
+// and CSR along with the subject, SANs and key algorithm they were generated
+// for, the SANs are normalized with pki.NormalizeSAN. The private key is
+// encrypted with pg.KeyEncrypter before it is stored. It returns
+// db.ErrForbidden if `principal` may not access `cert.UserUUID`. The pending
+// certificate is recorded in the certificate history as added by `principal`.
+func (pg *Postgres) AddPendingCert(cert *db.Cert, principal *db.Principal) error {
+	if err := authorize(principal, cert.UserUUID); err != nil {
+		return err
+	}
+	encrypted, err := pg.encryptPrivateKey(cert.PrivateKey)
+	if err != nil {
+		return err
+	}
+	for i, san := range cert.SANs {
+		cert.SANs[i] = pki.NormalizeSAN(san)
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := checkUser(tx, cert.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// insert pending cert, which stays inactive until it is completed
+	query := `
+INSERT INTO certificates (user_uuid, status, active, private_key, data_key,
+	master_key_id, csr, subject, sans, key_algorithm)
+VALUES ($1, 'pending', FALSE, $2, $3, $4, $5, $6, $7, $8)
+RETURNING uuid, status, active, created_at`
+	if err := tx.QueryRow(query, cert.UserUUID, encrypted.Ciphertext,
+		encrypted.WrappedDataKey, encrypted.MasterKeyID, cert.CSR, cert.Subject,
+		pq.Array(cert.SANs), cert.KeyAlgorithm).
+		Scan(&cert.UUID, &cert.Status, &cert.Active, &cert.CreatedAt); err != nil {
+		return errors.Join(fmt.Errorf("failed to insert pending certificate: %w", err), tx.Rollback())
+	}
+	if err := addCertEvent(tx, cert.UUID, principal.UUID, db.CertEventAdded, nil,
+		map[string]any{"status": cert.Status, "active": cert.Active}); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// CompletePendingCert sets `cert.Body` as the signed certificate of pending
+// certificate `cert.UUID` belonging to `cert.UserUUID`, which activates it, and
+// fills `cert` with the resulting record. It errors out if the user does not
+// exist or is not active, the certificate is not pending, or `cert.Body` is
+// not a certificate for the pending private key, and returns db.ErrForbidden
+// if `principal` may not access `cert.UserUUID`. The completion is recorded in
+// the certificate history as done by `principal`.
+func (pg *Postgres) CompletePendingCert(cert *db.Cert, principal *db.Principal) error {
+	if err := authorize(principal, cert.UserUUID); err != nil {
+		return err
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := checkUser(tx, cert.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// lock the pending cert and get its private key
+	query := `
+SELECT private_key, data_key, master_key_id FROM certificates
+WHERE uuid = $1 AND user_uuid = $2 AND status = 'pending'
+FOR UPDATE`
+	encrypted := &encrypter.EncryptedKey{}
+	if err := tx.QueryRow(query, cert.UUID, cert.UserUUID).
+		Scan(&encrypted.Ciphertext, &encrypted.WrappedDataKey, &encrypted.MasterKeyID); err != nil {
+		return errors.Join(fmt.Errorf("failed to query for pending certificate: %w", err), tx.Rollback())
+	}
+	if cert.PrivateKey, err = pg.decryptPrivateKey(encrypted); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// check that the body belongs to the pending private key
+	_, err = parseCert(cert)
+	cert.PrivateKey = ""
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// store the body and its parsed fields, and activate the cert
+	query = `
+UPDATE certificates
+SET status = 'issued', active = TRUE, body = $2, subject = $3, issuer = $4,
+	serial_number = $5, sans = $6, key_algorithm = $7, not_before = $8,
+	not_after = $9, fingerprint = $10
+WHERE uuid = $1
+RETURNING ` + certColumns
+	completed, err := scanCert(tx.QueryRow(query, cert.UUID, cert.Body,
+		cert.Subject, cert.Issuer, cert.SerialNumber, pq.Array(cert.SANs),
+		cert.KeyAlgorithm, cert.NotBefore, cert.NotAfter, cert.Fingerprint))
+	if isUniqueViolation(err, userFingerprintIndex, userIssuerSerialIndex) {
+		return errors.Join(fmt.Errorf("certificate %s is already added: %w", cert.Fingerprint, db.ErrConflict), tx.Rollback())
+	}
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to update pending certificate: %w", err), tx.Rollback())
+	}
+	if err := addCertEvent(tx, completed.UUID, principal.UUID, db.CertEventCompleted,
+		map[string]any{"status": db.CertStatusPending, "active": false},
+		map[string]any{"status": completed.Status, "active": completed.Active,
+			"fingerprint": completed.Fingerprint}); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	*cert = *completed
+	return nil
+}
@@ -0,0 +1,180 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"certificate/pki/pkitest"
+	"crypto/x509"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var mockPendingCert = &db.Cert{
+	UUID:         mockCert0.UUID,
+	UserUUID:     mockUser.UUID,
+	Status:       db.CertStatusPending,
+	PrivateKey:   mockPrivateKey,
+	CSR:          "csr",
+	Subject:      "CN=mock.example.com",
+	SANs:         []string{"mock.example.com"},
+	KeyAlgorithm: "ECDSA-P256",
+	CreatedAt:    time.Now(),
+}
+
+func TestPostgres_AddPendingCert(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:     mockPendingCert.UserUUID,
+			PrivateKey:   mockPendingCert.PrivateKey,
+			CSR:          mockPendingCert.CSR,
+			Subject:      mockPendingCert.Subject,
+			SANs:         mockPendingCert.SANs,
+			KeyAlgorithm: mockPendingCert.KeyAlgorithm,
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockPendingCert.UUID, mockPendingCert.Status, false, mockPendingCert.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+'pending', FALSE.+)
+RETURNING (.+)*`).
+			WithArgs(mockUser.UUID, []byte(mockPendingCert.PrivateKey),
+				mockDataKey, mockMasterKeyID, mockPendingCert.CSR,
+				mockPendingCert.Subject, pq.Array(mockPendingCert.SANs),
+				mockPendingCert.KeyAlgorithm).
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockPendingCert.UUID, mockUser.UUID, db.CertEventAdded)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.AddPendingCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, mockPendingCert, cert)
+	})
+
+	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:   mockPendingCert.UserUUID,
+			PrivateKey: mockPendingCert.PrivateKey,
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.ExpectRollback()
+
+		assert.NotNil(t, pg.AddPendingCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Empty(t, cert.UUID)
+	})
+}
+
+func TestPostgres_CompletePendingCert(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	// expectPendingCert expects the queries locking the pending cert
+	expectPendingCert := func() {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"private_key", "data_key", "master_key_id"}).
+			AddRow([]byte(mockPendingCert.PrivateKey), mockDataKey, mockMasterKeyID)
+		mock.ExpectQuery(`
+^SELECT private_key, data_key, master_key_id FROM certificates
+WHERE (.+) AND status = 'pending'
+FOR UPDATE`).
+			WithArgs(mockPendingCert.UUID, mockUser.UUID).
+			WillReturnRows(rows)
+	}
+
+	t.Run("happy_path", func(t *testing.T) {
+		cert := &db.Cert{
+			UUID:     mockPendingCert.UUID,
+			UserUUID: mockPendingCert.UserUUID,
+			Body:     mockCert0.Body,
+		}
+		completed := publicCert(mockCert0)
+		completed.CSR = mockPendingCert.CSR
+
+		expectPendingCert()
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET status = 'issued', active = TRUE, (.+)
+WHERE uuid = (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, mockCert0.Body, mockCert0.Subject,
+				mockCert0.Issuer, mockCert0.SerialNumber, pq.Array(mockCert0.SANs),
+				mockCert0.KeyAlgorithm, mockCert0.NotBefore, mockCert0.NotAfter,
+				mockCert0.Fingerprint).
+			WillReturnRows(sqlmock.NewRows(certColumns).AddRow(certValues(completed)...))
+		expectCertEvent(mock, completed.UUID, mockUser.UUID, db.CertEventCompleted)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.CompletePendingCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, completed, cert)
+	})
+
+	t.Run("error_mismatched_body_with_tx_rollback", func(t *testing.T) {
+		_, otherBody, _ := pkitest.SelfSigned(&x509.Certificate{Subject: mockX509Cert.Subject})
+		cert := &db.Cert{
+			UUID:     mockPendingCert.UUID,
+			UserUUID: mockPendingCert.UserUUID,
+			Body:     otherBody,
+		}
+
+		expectPendingCert()
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.CompletePendingCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "private_key", validationErr.Field)
+		assert.Empty(t, cert.PrivateKey)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_not_pending_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UUID:     mockPendingCert.UUID,
+			UserUUID: mockPendingCert.UserUUID,
+			Body:     mockCert0.Body,
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^SELECT private_key, data_key, master_key_id FROM certificates
+WHERE (.+)*`).
+			WithArgs(mockPendingCert.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"private_key", "data_key", "master_key_id"}))
+		mock.ExpectRollback()
+
+		assert.NotNil(t, pg.CompletePendingCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,7 +1,7 @@
 package postgres
 
 import (
-	"certificate/db"
+	"certificate/encrypter"
 	"database/sql"
 	"fmt"
 	_ "github.com/lib/pq"
@@ -19,14 +19,16 @@ const (
 	dbname   = "certificate_dev"
 )
 
-// Postgres composites sql.DB and represents a postgres connection.
+// Postgres composites sql.DB and represents a postgres connection. Private
+// keys are encrypted with KeyEncrypter before they are stored.
 type Postgres struct {
 	*sql.DB
+	KeyEncrypter encrypter.KeyEncrypter
 }
 
 // Connect returns a Postgres with an active connection, and sets up graceful
 // shutdown for the database connection.
-func Connect() (db.Database, error) {
+func Connect() (*Postgres, error) {
 	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, username, password, dbname)
 	// create connection to postgres
 	sqlDB, err := sql.Open("postgres", psqlInfo)
@@ -49,5 +51,11 @@ func Connect() (db.Database, error) {
 			log.Println("postgres db connection writer closed")
 		}
 	}()
-	return &Postgres{sqlDB}, nil
+	return &Postgres{DB: sqlDB}, nil
+}
+
+// WithKeyEncrypter sets pg.KeyEncrypter.
+func (pg *Postgres) WithKeyEncrypter(keyEncrypter encrypter.KeyEncrypter) *Postgres {
+	pg.KeyEncrypter = keyEncrypter
+	return pg
 }
@@ -1,17 +1,52 @@
 package postgres_test
 
 import (
-	"certificate/db"
 	"certificate/db/postgres"
+	"certificate/encrypter"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
-func MockConnect(t *testing.T) (db.Database, sqlmock.Sqlmock, error) {
+const mockMasterKeyID = "mock_master_key_id"
+
+var mockDataKey = []byte("mock_data_key")
+
+// MockEncrypter is a KeyEncrypter that stores plaintext as ciphertext.
+type MockEncrypter struct{}
+
+func (me *MockEncrypter) MasterKeyID() string {
+	return mockMasterKeyID
+}
+
+func (me *MockEncrypter) Encrypt(plaintext []byte) (*encrypter.EncryptedKey, error) {
+	return &encrypter.EncryptedKey{
+		Ciphertext:     plaintext,
+		WrappedDataKey: mockDataKey,
+		MasterKeyID:    mockMasterKeyID,
+	}, nil
+}
+
+func (me *MockEncrypter) Decrypt(key *encrypter.EncryptedKey) ([]byte, error) {
+	if key.MasterKeyID != mockMasterKeyID {
+		return nil, errors.New("unknown master key")
+	}
+	return key.Ciphertext, nil
+}
+
+func (me *MockEncrypter) Rewrap(key *encrypter.EncryptedKey) (*encrypter.EncryptedKey, error) {
+	return &encrypter.EncryptedKey{
+		Ciphertext:     key.Ciphertext,
+		WrappedDataKey: mockDataKey,
+		MasterKeyID:    mockMasterKeyID,
+	}, nil
+}
+
+func MockConnect(t *testing.T) (*postgres.Postgres, sqlmock.Sqlmock, error) {
 	mockDB, mock, err := sqlmock.New()
 	assert.Nil(t, err)
 	assert.NotNil(t, mockDB)
 	assert.NotNil(t, mock)
-	return &postgres.Postgres{DB: mockDB}, mock, err
+	return &postgres.Postgres{DB: mockDB, KeyEncrypter: &MockEncrypter{}}, mock, err
 }
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// QueryCerts returns a page of the public metadata of the certificates
+// matching `query`, ordered by creation. Pages are keyset paginated on
+// (created_at, uuid), so certificates added while paging do not shift the
+// following pages. It errors out if `query.UserUUID` does not exist or is not
+// active, `query.TeamUUID` is set but does not exist, or `query.Cursor` is
+// invalid. It returns db.ErrForbidden if `principal` may not access
+// `query.UserUUID`, or is not a member of `query.TeamUUID` if it is set.
+func (pg *Postgres) QueryCerts(query *db.CertQuery, principal *db.Principal) (*db.CertPage, error) {
+	owner, ownerUUID := "user_uuid", query.UserUUID
+	if query.TeamUUID != "" {
+		owner, ownerUUID = "team_uuid", query.TeamUUID
+	} else if err := authorize(principal, query.UserUUID); err != nil {
+		return nil, err
+	}
+
+	// build the conditions, each one with its arguments
+	conditions := []string{owner + " = $1"}
+	args := []any{ownerUUID}
+	where := func(condition string, conditionArgs ...any) {
+		placeholders := make([]any, len(conditionArgs))
+		for i, arg := range conditionArgs {
+			args = append(args, arg)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+	if query.SAN != "" {
+		where("sans && $%d", pq.Array(pki.CoveringSANs(query.SAN)))
+	}
+	if query.Subject != "" {
+		where("subject ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(query.Subject))
+	}
+	if query.Issuer != "" {
+		where("issuer ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(query.Issuer))
+	}
+	if !query.ExpiresBefore.IsZero() {
+		where("not_after < $%d", query.ExpiresBefore.UTC())
+	}
+	if !query.ExpiresAfter.IsZero() {
+		where("not_after >= $%d", query.ExpiresAfter.UTC())
+	}
+	if query.Active != nil {
+		where("active = $%d", *query.Active)
+	}
+	if len(query.Labels) > 0 {
+		labels, err := marshalLabels(query.Labels)
+		if err != nil {
+			return nil, err
+		}
+		where("labels @> $%d", labels)
+	}
+	if query.Cursor != "" {
+		createdAt, uuid, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, &db.ValidationError{Field: "cursor", Err: err}
+		}
+		where("(created_at, uuid) > ($%d, $%d)", createdAt, uuid)
+	}
+	limit := ""
+	if query.Limit > 0 {
+		// fetch one more row to know if there is a next page
+		limit = fmt.Sprintf("\nLIMIT %d", query.Limit+1)
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if query.TeamUUID != "" {
+		if _, _, err := authorizeTeam(tx, principal, query.TeamUUID, db.RoleViewer); err != nil {
+			return nil, errors.Join(err, tx.Rollback())
+		}
+	} else if err := checkUser(tx, query.UserUUID); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// query for matching certificates
+	rows, err := tx.Query(`
+SELECT `+certColumns+`
+FROM certificates
+WHERE `+strings.Join(conditions, " AND ")+`
+ORDER BY created_at, uuid`+limit, args...)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to query: %w", err), tx.Rollback())
+	}
+	page := &db.CertPage{Certs: []*db.Cert{}}
+	for rows.Next() {
+		if cert, errScan := scanCert(rows); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			page.Certs = append(page.Certs, cert)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose), tx.Rollback())
+	}
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+
+	// the extra row only tells that there is a next page
+	if query.Limit > 0 && len(page.Certs) > query.Limit {
+		page.Certs = page.Certs[:query.Limit]
+		last := page.Certs[query.Limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.UUID)
+	}
+	return page, nil
+}
+
+// encodeCursor returns an opaque cursor pointing after the certificate
+// created at `createdAt` with UUID `uuid`.
+func encodeCursor(createdAt time.Time, uuid string) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(createdAt.UTC().Format(time.RFC3339Nano) + " " + uuid))
+}
+
+// decodeCursor returns the creation time and the UUID of the certificate
+// `cursor` points after.
+func decodeCursor(cursor string) (time.Time, string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("failed to decode cursor: %w", err)
+	}
+	createdAt, uuid, ok := strings.Cut(string(decoded), " ")
+	if !ok {
+		return time.Time{}, "", errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("malformed cursor: %w", err)
+	}
+	return t, uuid, nil
+}
@@ -0,0 +1,125 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPostgres_QueryCerts(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		active := true
+		expiresBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		query := &db.CertQuery{
+			UserUUID:      mockUser.UUID,
+			SAN:           "dog.mock.example.com",
+			Issuer:        "100%_mock",
+			ExpiresBefore: expiresBefore,
+			Active:        &active,
+			Labels:        map[string]string{"env": "prod"},
+		}
+
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE user_uuid = \$1 AND sans && \$2 AND issuer ILIKE '%' \|\| \$3 \|\| '%' AND not_after < \$4 AND active = \$5 AND labels @> \$6
+ORDER BY created_at, uuid$`).
+			WithArgs(mockUser.UUID,
+				pq.Array([]string{"dog.mock.example.com", "*.mock.example.com"}),
+				`100\%\_mock`, expiresBefore, true, `{"env":"prod"}`).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		page, err := pg.QueryCerts(query, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, &db.CertPage{Certs: []*db.Cert{publicCert(mockCert0)}}, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_all_certs", func(t *testing.T) {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockCert0)...).
+			AddRow(certValues(mockCert1)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE user_uuid = \$1
+ORDER BY created_at, uuid$`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		page, err := pg.QueryCerts(&db.CertQuery{UserUUID: mockUser.UUID}, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, &db.CertPage{Certs: []*db.Cert{publicCert(mockCert0), publicCert(mockCert1)}}, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_team_certs", func(t *testing.T) {
+		teamCert := *mockCert0
+		teamCert.TeamUUID = mockTeam.UUID
+
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleViewer, db.RoleViewer)
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(&teamCert)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE team_uuid = \$1
+ORDER BY created_at, uuid$`).
+			WithArgs(mockTeam.UUID).
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		page, err := pg.QueryCerts(&db.CertQuery{TeamUUID: mockTeam.UUID}, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, &db.CertPage{Certs: []*db.Cert{publicCert(&teamCert)}}, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_not_team_member_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, "", db.RoleOperator)
+		mock.ExpectRollback()
+
+		page, err := pg.QueryCerts(&db.CertQuery{TeamUUID: mockTeam.UUID}, mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.ExpectRollback()
+
+		page, err := pg.QueryCerts(&db.CertQuery{UserUUID: mockUser.UUID}, mockPrincipal)
+		assert.NotNil(t, err)
+		assert.Nil(t, page)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SetRenewalPolicy adds or replaces the renewal policy of certificate
+// `policy.CertUUID`, which has to be an issued certificate belonging to
+// `policy.UserUUID` with a key type that pki.GenerateKey supports, and fills
+// `policy.UpdatedAt`. It returns a *db.ValidationError if the policy is
+// rejected, or db.ErrForbidden if `principal` may not manage the
+// certificate.
+func (pg *Postgres) SetRenewalPolicy(policy *db.RenewalPolicy, principal *db.Principal) error {
+	switch {
+	case policy.Method != db.RenewalMethodCA && policy.Method != db.RenewalMethodACME:
+		return &db.ValidationError{Field: "method", Err: fmt.Errorf("unknown renewal method %q", policy.Method)}
+	case policy.DaysBeforeExpiry <= 0:
+		return &db.ValidationError{Field: "days_before_expiry", Err: errors.New("must be positive")}
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := authorizeCert(tx, principal, policy.CertUUID, policy.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// only certificates issued by an internal CA can be renewed through it,
+	// and only certificates with keys that can be generated again at all
+	var authorityUUID, keyAlgorithm string
+	query := `
+SELECT COALESCE(authority_uuid::text, ''), key_algorithm FROM certificates
+WHERE uuid = $1 AND user_uuid = $2 AND status = 'issued'`
+	if err := tx.QueryRow(query, policy.CertUUID, policy.UserUUID).Scan(&authorityUUID, &keyAlgorithm); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = &db.ValidationError{Field: "cert_uuid", Err: errors.New("certificate does not exist or is not issued")}
+		} else {
+			err = fmt.Errorf("failed to query for certificate: %w", err)
+		}
+		return errors.Join(err, tx.Rollback())
+	}
+	if policy.Method == db.RenewalMethodCA && authorityUUID == "" {
+		return errors.Join(&db.ValidationError{Field: "method",
+			Err: errors.New("certificate was not issued by an internal CA")}, tx.Rollback())
+	}
+	if err := pki.CheckKeyType(keyAlgorithm); err != nil {
+		return errors.Join(&db.ValidationError{Field: "cert_uuid",
+			Err: fmt.Errorf("certificate cannot be renewed: %w", err)}, tx.Rollback())
+	}
+
+	query = `
+INSERT INTO renewal_policies (cert_uuid, method, days_before_expiry, profile)
+VALUES ($1, $2, $3, NULLIF($4, ''))
+ON CONFLICT (cert_uuid) DO UPDATE
+SET method = $2, days_before_expiry = $3, profile = NULLIF($4, ''),
+	updated_at = CURRENT_TIMESTAMP
+RETURNING updated_at`
+	if err := tx.QueryRow(query, policy.CertUUID, policy.Method,
+		policy.DaysBeforeExpiry, policy.Profile).Scan(&policy.UpdatedAt); err != nil {
+		return errors.Join(fmt.Errorf("failed to upsert renewal policy: %w", err), tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// DeleteRenewalPolicy deletes the renewal policy of certificate `certUUID`
+// belonging to `userUUID`. It returns a *db.ValidationError if there is none,
+// and db.ErrForbidden if `principal` may not manage the certificate.
+func (pg *Postgres) DeleteRenewalPolicy(certUUID, userUUID string, principal *db.Principal) error {
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := authorizeCert(tx, principal, certUUID, userUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+DELETE FROM renewal_policies
+WHERE cert_uuid = $1`
+	res, err := tx.Exec(query, certUUID)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to delete renewal policy: %w", err), tx.Rollback())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to get affected rows: %w", err), tx.Rollback())
+	}
+	if rows == 0 {
+		return errors.Join(&db.ValidationError{Field: "cert_uuid",
+			Err: errors.New("renewal policy does not exist")}, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetDueRenewals returns the active certificates with a renewal policy that
+// are within `days_before_expiry` of expiring at `now`, along with their
+// policies. Certificates of a team are renewed for the team, other
+// certificates only as long as the user they belong to is active.
+func (pg *Postgres) GetDueRenewals(now time.Time) ([]*db.Renewal, error) {
+	query := `
+SELECT ` + certColumns + `, method, days_before_expiry, COALESCE(profile, ''),
+	updated_at
+FROM certificates
+JOIN renewal_policies ON cert_uuid = uuid
+WHERE active AND status = 'issued'
+	AND (team_uuid IS NOT NULL OR user_uuid IN (SELECT uuid FROM users WHERE active))
+	AND not_after <= $1::timestamp + days_before_expiry * INTERVAL '1 day'
+ORDER BY not_after`
+	rows, err := pg.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	var renewals []*db.Renewal
+	for rows.Next() {
+		policy := &db.RenewalPolicy{}
+		cert, errScan := scanCert(rows, &policy.Method, &policy.DaysBeforeExpiry,
+			&policy.Profile, &policy.UpdatedAt)
+		if errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+			continue
+		}
+		policy.CertUUID, policy.UserUUID = cert.UUID, cert.UserUUID
+		renewals = append(renewals, &db.Renewal{Cert: cert, Policy: policy})
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	return renewals, err
+}
+
+// RenewCert adds `cert` like AddCert as the replacement of certificate
+// `cert.ReplacesUUID`, and deactivates the replaced certificate in the same
+// transaction, so that a renewal either happens as a whole or not at all. It
+// returns a *db.ValidationError if `cert.ReplacesUUID` is empty, and errors
+// out if the replaced certificate is not active. Both changes are recorded in
+// the certificate history as done by `principal`.
+func (pg *Postgres) RenewCert(cert *db.Cert, principal *db.Principal) error {
+	if cert.ReplacesUUID == "" {
+		return &db.ValidationError{Field: "replaces_uuid", Err: errors.New("renewed certificates must replace a certificate")}
+	}
+	return pg.addCerts([]*db.Cert{cert}, principal, func(tx *sql.Tx) error {
+		if _, err := updateCertActiveStatus(tx, cert.ReplacesUUID, cert.UserUUID, false); err != nil {
+			return fmt.Errorf("failed to deactivate replaced certificate: %w", err)
+		}
+		return addCertEvent(tx, cert.ReplacesUUID, principal.UUID, db.CertEventDeactivated,
+			map[string]any{"active": true}, map[string]any{"active": false})
+	})
+}
@@ -0,0 +1,353 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var mockRenewalPolicy = &db.RenewalPolicy{
+	CertUUID:         mockCert0.UUID,
+	UserUUID:         mockUser.UUID,
+	Method:           db.RenewalMethodCA,
+	DaysBeforeExpiry: 30,
+	Profile:          "server",
+	UpdatedAt:        time.Now(),
+}
+
+func TestPostgres_SetRenewalPolicy(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	// expectCert expects the queries checking the user and the certificate,
+	// which returns `certRows`
+	expectCert := func(certRows *sqlmock.Rows) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^SELECT (.+), key_algorithm FROM certificates
+WHERE (.+) AND status = 'issued'`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(certRows)
+	}
+	// expectUpsert expects the renewal policy `policy` to be upserted
+	expectUpsert := func(policy *db.RenewalPolicy) {
+		rows := sqlmock.NewRows([]string{"updated_at"}).
+			AddRow(mockRenewalPolicy.UpdatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO renewal_policies (.+)
+VALUES (.+)
+ON CONFLICT \(cert_uuid\) DO UPDATE
+SET (.+)
+RETURNING updated_at`).
+			WithArgs(policy.CertUUID, policy.Method, policy.DaysBeforeExpiry, policy.Profile).
+			WillReturnRows(rows)
+	}
+
+	t.Run("happy_path", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+		policy.UpdatedAt = time.Time{}
+
+		expectCert(sqlmock.NewRows([]string{"authority_uuid", "key_algorithm"}).
+			AddRow(mockAuthority.UUID, mockCert0.KeyAlgorithm))
+		expectUpsert(&policy)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.SetRenewalPolicy(&policy, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, mockRenewalPolicy, &policy)
+	})
+	t.Run("happy_path_team_operator", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+		policy.UpdatedAt = time.Time{}
+		principal := &db.Principal{UUID: mockMemberUUID}
+
+		// the owner is not checked, the certificate belongs to the team
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockMemberUUID, db.RoleOperator, db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"authority_uuid", "key_algorithm"}).
+			AddRow(mockAuthority.UUID, mockCert0.KeyAlgorithm)
+		mock.ExpectQuery(`
+^SELECT (.+) FROM certificates
+WHERE (.+) AND status = 'issued'`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(rows)
+		expectUpsert(&policy)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.SetRenewalPolicy(&policy, principal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_team_viewer_with_tx_rollback", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+		principal := &db.Principal{UUID: mockMemberUUID}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockMemberUUID, db.RoleViewer, db.RoleViewer)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, pg.SetRenewalPolicy(&policy, principal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_unsupported_key_algorithm_with_tx_rollback", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+
+		expectCert(sqlmock.NewRows([]string{"authority_uuid", "key_algorithm"}).
+			AddRow(mockAuthority.UUID, "RSA-1024"))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetRenewalPolicy(&policy, mockPrincipal), &validationErr)
+		assert.Equal(t, "cert_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_ca_method_without_authority_with_tx_rollback", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+
+		expectCert(sqlmock.NewRows([]string{"authority_uuid", "key_algorithm"}).
+			AddRow("", mockCert0.KeyAlgorithm))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetRenewalPolicy(&policy, mockPrincipal), &validationErr)
+		assert.Equal(t, "method", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_cert_not_found_with_tx_rollback", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+		policy.Method = db.RenewalMethodACME
+
+		expectCert(sqlmock.NewRows([]string{"authority_uuid", "key_algorithm"}))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetRenewalPolicy(&policy, mockPrincipal), &validationErr)
+		assert.Equal(t, "cert_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_invalid_method", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+		policy.Method = "manual"
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetRenewalPolicy(&policy, mockPrincipal), &validationErr)
+		assert.Equal(t, "method", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_invalid_days_before_expiry", func(t *testing.T) {
+		policy := *mockRenewalPolicy
+		policy.DaysBeforeExpiry = 0
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.SetRenewalPolicy(&policy, mockPrincipal), &validationErr)
+		assert.Equal(t, "days_before_expiry", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_DeleteRenewalPolicy(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	// expectCert expects the queries checking the user and the certificate
+	expectCert := func() {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+	}
+
+	t.Run("happy_path", func(t *testing.T) {
+		expectCert()
+		mock.ExpectExec(`
+^DELETE FROM renewal_policies
+WHERE cert_uuid = \$1`).
+			WithArgs(mockCert0.UUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.DeleteRenewalPolicy(mockCert0.UUID, mockUser.UUID, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_team_operator", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockMemberUUID, db.RoleOperator, db.RoleViewer)
+		mock.ExpectExec(`
+^DELETE FROM renewal_policies (.+)`).
+			WithArgs(mockCert0.UUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.DeleteRenewalPolicy(mockCert0.UUID, mockUser.UUID, &db.Principal{UUID: mockMemberUUID}))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_not_found_with_tx_rollback", func(t *testing.T) {
+		expectCert()
+		mock.ExpectExec(`
+^DELETE FROM renewal_policies (.+)`).
+			WithArgs(mockCert0.UUID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.DeleteRenewalPolicy(mockCert0.UUID, mockUser.UUID, mockPrincipal), &validationErr)
+		assert.Equal(t, "cert_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_other_user", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockUser.UUID, "")
+		mock.ExpectRollback()
+
+		err := pg.DeleteRenewalPolicy(mockCert0.UUID, mockUser.UUID, &db.Principal{UUID: mockMemberUUID})
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetDueRenewals(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+	now := time.Date(2023, 12, 15, 0, 0, 0, 0, time.UTC)
+
+	t.Run("happy_path", func(t *testing.T) {
+		columns := append(append([]string{}, certColumns...),
+			"method", "days_before_expiry", "profile", "updated_at")
+		values := append(certValues(mockCert0), mockRenewalPolicy.Method,
+			mockRenewalPolicy.DaysBeforeExpiry, mockRenewalPolicy.Profile,
+			mockRenewalPolicy.UpdatedAt)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+JOIN renewal_policies ON cert_uuid = uuid
+WHERE active AND status = 'issued'
+	AND \(team_uuid IS NOT NULL OR user_uuid IN \(SELECT uuid FROM users WHERE active\)\)
+	AND (.+)
+ORDER BY not_after`).
+			WithArgs(now).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(values...))
+
+		renewals, err := pg.GetDueRenewals(now)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.Renewal{{Cert: publicCert(mockCert0), Policy: mockRenewalPolicy}}, renewals)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates`).
+			WithArgs(now).
+			WillReturnError(errors.New("mock_error"))
+
+		renewals, err := pg.GetDueRenewals(now)
+		assert.NotNil(t, err)
+		assert.Nil(t, renewals)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_RenewCert(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	// expectReplacement expects the queries adding mockCert1 as the
+	// replacement of mockCert0
+	expectReplacement := func() {
+		mock.ExpectBegin()
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockCert0.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM certificates
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows([]string{"uuid", "status", "active", "created_at"}).
+			AddRow(mockCert1.UUID, mockCert1.Status, mockCert1.Active, mockCert1.CreatedAt)
+		mock.ExpectQuery(`
+^INSERT INTO certificates (.+)
+VALUES (.+)
+RETURNING (.+)*`).
+			WillReturnRows(rows)
+		mock.ExpectExec(`
+^UPDATE renewal_policies
+SET cert_uuid = (.+)
+WHERE (.+)*`).
+			WithArgs(mockCert0.UUID, mockCert1.UUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectCertEvent(mock, mockCert1.UUID, "renewal", db.CertEventAdded)
+	}
+
+	t.Run("happy_path", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:     mockCert1.UserUUID,
+			ReplacesUUID: mockCert0.UUID,
+			PrivateKey:   mockCert1.PrivateKey,
+			Body:         mockCert1.Body,
+		}
+
+		expectReplacement()
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET active = (.+)
+WHERE (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, false, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows(certColumns).AddRow(certValues(mockCert0)...))
+		expectCertEvent(mock, mockCert0.UUID, "renewal", db.CertEventDeactivated)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.RenewCert(cert, db.SystemPrincipal("renewal")))
+		assert.Nil(t, mock.ExpectationsWereMet())
+		assert.Equal(t, mockCert1.UUID, cert.UUID)
+	})
+	t.Run("error_replaced_cert_inactive_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{
+			UserUUID:     mockCert1.UserUUID,
+			ReplacesUUID: mockCert0.UUID,
+			PrivateKey:   mockCert1.PrivateKey,
+			Body:         mockCert1.Body,
+		}
+
+		expectReplacement()
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET active = (.+)
+WHERE (.+)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, false, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows(certColumns))
+		mock.ExpectRollback()
+
+		assert.NotNil(t, pg.RenewCert(cert, db.SystemPrincipal("renewal")))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_no_replaced_cert", func(t *testing.T) {
+		cert := &db.Cert{UserUUID: mockCert1.UserUUID, Body: mockCert1.Body}
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.RenewCert(cert, db.SystemPrincipal("renewal")), &validationErr)
+		assert.Equal(t, "replaces_uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
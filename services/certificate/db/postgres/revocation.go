@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RevokeCert revokes issued certificate `cert.UUID` belonging to
+// `cert.UserUUID` with reason code `cert.RevocationReason`, deactivating it
+// for good, and fills `cert` with the revoked certificate. Certificates
+// issued by an internal CA are only revoked through the record they were
+// issued as, never through uploaded copies. It returns a *db.ValidationError
+// if the reason code is not supported or the certificate does not exist, is
+// pending, is already revoked or is such a copy, and db.ErrForbidden if
+// `principal` may not manage the certificate, see authorizeCert. The
+// revocation is recorded in the certificate history as done by `principal`.
+func (pg *Postgres) RevokeCert(cert *db.Cert, principal *db.Principal) error {
+	if err := pki.CheckRevocationReason(cert.RevocationReason); err != nil {
+		return &db.ValidationError{Field: "revocation_reason", Err: err}
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	if err := authorizeCert(tx, principal, cert.UUID, cert.UserUUID); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	query := `
+UPDATE certificates
+SET status = 'revoked', active = FALSE, revoked_at = $3, revocation_reason = $4
+WHERE uuid = $1 AND user_uuid = $2 AND status = 'issued'
+	AND (authority_uuid IS NOT NULL OR NOT EXISTS (
+		SELECT 1 FROM certificates issued
+		WHERE issued.fingerprint = certificates.fingerprint
+			AND issued.authority_uuid IS NOT NULL))
+RETURNING ` + certColumns
+	revoked, err := scanCert(tx.QueryRow(query, cert.UUID, cert.UserUUID,
+		time.Now().UTC(), cert.RevocationReason))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = &db.ValidationError{Field: "uuid", Err: errors.New("certificate does not exist, is not issued or is a copy of a certificate issued by an internal CA")}
+		} else {
+			err = fmt.Errorf("failed to revoke certificate: %w", err)
+		}
+		return errors.Join(err, tx.Rollback())
+	}
+	if err := addCertEvent(tx, revoked.UUID, principal.UUID, db.CertEventRevoked,
+		map[string]any{"status": db.CertStatusIssued},
+		map[string]any{"status": revoked.Status, "active": revoked.Active,
+			"revocation_reason": revoked.RevocationReason}); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	*cert = *revoked
+	return nil
+}
+
+// GetRevokedCerts returns all revoked certificates issued by authority
+// `authorityUUID`, ordered by their revocation time.
+func (pg *Postgres) GetRevokedCerts(authorityUUID string) ([]*db.Cert, error) {
+	query := `
+SELECT ` + certColumns + `
+FROM certificates
+WHERE authority_uuid = $1 AND status = 'revoked'
+ORDER BY revoked_at`
+	rows, err := pg.Query(query, authorityUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	var certs []*db.Cert
+	for rows.Next() {
+		if cert, errScan := scanCert(rows); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			certs = append(certs, cert)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	return certs, err
+}
+
+// GetCertBySerialNumber returns the certificate issued by authority
+// `authorityUUID` with hex encoded serial number `serialNumber`, or nil if
+// there is none. It errors out rather than pick one if there are several,
+// which the unique serial_number_idx rules out.
+func (pg *Postgres) GetCertBySerialNumber(authorityUUID, serialNumber string) (*db.Cert, error) {
+	query := `
+SELECT ` + certColumns + `
+FROM certificates
+WHERE authority_uuid = $1 AND serial_number = $2
+LIMIT 2`
+	rows, err := pg.Query(query, authorityUUID, serialNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	var certs []*db.Cert
+	for rows.Next() {
+		if cert, errScan := scanCert(rows); errScan != nil {
+			err = errors.Join(err, fmt.Errorf("failed to scan row: %w", errScan))
+		} else {
+			certs = append(certs, cert)
+		}
+	}
+	if errClose := rows.Close(); errClose != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to close rows: %w", errClose))
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch len(certs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return certs[0], nil
+	default:
+		return nil, fmt.Errorf("authority %s issued several certificates with serial number %s", authorityUUID, serialNumber)
+	}
+}
@@ -0,0 +1,222 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// mockRevokedCert is `mockCert0` revoked because its key was compromised.
+var mockRevokedCert = func() *db.Cert {
+	revoked := publicCert(mockCert0)
+	revoked.Status = db.CertStatusRevoked
+	revoked.Active = false
+	revoked.RevokedAt = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	revoked.RevocationReason = pki.ReasonKeyCompromise
+	return revoked
+}()
+
+func TestPostgres_RevokeCert(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		cert := &db.Cert{
+			UUID:             mockCert0.UUID,
+			UserUUID:         mockCert0.UserUUID,
+			RevocationReason: pki.ReasonKeyCompromise,
+		}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockRevokedCert)...)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET status = 'revoked', active = FALSE, (.+)
+WHERE uuid = \$1 AND user_uuid = \$2 AND status = 'issued'
+	AND \(authority_uuid IS NOT NULL OR NOT EXISTS \(
+		SELECT 1 FROM certificates issued
+		WHERE issued.fingerprint = certificates.fingerprint
+			AND issued.authority_uuid IS NOT NULL\)\)
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, mockCert0.UserUUID, sqlmock.AnyArg(),
+				pki.ReasonKeyCompromise).
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventRevoked)
+		mock.ExpectCommit()
+
+		assert.Nil(t, pg.RevokeCert(cert, mockPrincipal))
+		assert.Equal(t, mockRevokedCert, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_unsupported_reason", func(t *testing.T) {
+		cert := &db.Cert{UUID: mockCert0.UUID, UserUUID: mockCert0.UserUUID, RevocationReason: 6}
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.RevokeCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "revocation_reason", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_not_issued_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{UUID: mockCert0.UUID, UserUUID: mockCert0.UserUUID}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET (.+)`).
+			WithArgs(mockCert0.UUID, mockCert0.UserUUID, sqlmock.AnyArg(), 0).
+			WillReturnRows(sqlmock.NewRows(certColumns))
+		mock.ExpectRollback()
+
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, pg.RevokeCert(cert, mockPrincipal), &validationErr)
+		assert.Equal(t, "uuid", validationErr.Field)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_invalid_user_uuid_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{UUID: mockCert0.UUID, UserUUID: mockCert0.UserUUID}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, "")
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.ExpectRollback()
+
+		assert.NotNil(t, pg.RevokeCert(cert, mockPrincipal))
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_owner_left_team_with_tx_rollback", func(t *testing.T) {
+		cert := &db.Cert{UUID: mockCert0.UUID, UserUUID: mockCert0.UserUUID}
+
+		mock.ExpectBegin()
+		expectCertTeam(mock, mockCert0.UUID, mockCert0.UserUUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, "", "")
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, pg.RevokeCert(cert, mockPrincipal), db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetRevokedCerts(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockRevokedCert)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE authority_uuid = \$1 AND status = 'revoked'
+ORDER BY revoked_at`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnRows(rows)
+
+		certs, err := pg.GetRevokedCerts(mockAuthority.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, []*db.Cert{mockRevokedCert}, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates`).
+			WithArgs(mockAuthority.UUID).
+			WillReturnError(errors.New("mock_error"))
+
+		certs, err := pg.GetRevokedCerts(mockAuthority.UUID)
+		assert.NotNil(t, err)
+		assert.Nil(t, certs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetCertBySerialNumber(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockRevokedCert)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates
+WHERE authority_uuid = \$1 AND serial_number = \$2
+LIMIT 2`).
+			WithArgs(mockAuthority.UUID, mockRevokedCert.SerialNumber).
+			WillReturnRows(rows)
+
+		cert, err := pg.GetCertBySerialNumber(mockAuthority.UUID, mockRevokedCert.SerialNumber)
+		assert.Nil(t, err)
+		assert.Equal(t, mockRevokedCert, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("happy_path_unknown", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates`).
+			WithArgs(mockAuthority.UUID, "2b").
+			WillReturnRows(sqlmock.NewRows(certColumns))
+
+		cert, err := pg.GetCertBySerialNumber(mockAuthority.UUID, "2b")
+		assert.Nil(t, err)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_several_certs", func(t *testing.T) {
+		rows := sqlmock.NewRows(certColumns).
+			AddRow(certValues(mockRevokedCert)...).
+			AddRow(certValues(mockRevokedCert)...)
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates`).
+			WithArgs(mockAuthority.UUID, mockRevokedCert.SerialNumber).
+			WillReturnRows(rows)
+
+		cert, err := pg.GetCertBySerialNumber(mockAuthority.UUID, mockRevokedCert.SerialNumber)
+		assert.NotNil(t, err)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_query", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT (.+)
+FROM certificates`).
+			WithArgs(mockAuthority.UUID, "2b").
+			WillReturnError(errors.New("mock_error"))
+
+		cert, err := pg.GetCertBySerialNumber(mockAuthority.UUID, "2b")
+		assert.NotNil(t, err)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
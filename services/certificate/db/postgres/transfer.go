@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"certificate/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TransferCert moves certificate `uuid` belonging to `userUUID` to user
+// `toUserUUID`, team `toTeamUUID`, or both, and returns the transferred
+// certificate. An empty `toUserUUID` or `toTeamUUID` keeps the current user
+// or team, and db.NoTeam takes the certificate out of its team. The
+// certificate may be given away by its user, which may have been deactivated
+// since, and by the admins of its team, who alone may take it out of the
+// team. It returns a *db.ValidationError if the certificate does not exist or
+// already belongs to the new owners, errors out if `toUserUUID` does not
+// exist or is not active, and returns db.ErrForbidden if `principal` may not
+// give the certificate away or is not an operator of `toTeamUUID`. The
+// transfer is recorded in the certificate history as done by `principal`.
+func (pg *Postgres) TransferCert(uuid, userUUID, toUserUUID, toTeamUUID string, principal *db.Principal) (*db.Cert, error) {
+	if toUserUUID == "" && toTeamUUID == "" {
+		return nil, &db.ValidationError{Field: "to_user_uuid", Err: errors.New("either to_user_uuid or to_team_uuid must be set")}
+	}
+
+	// use transaction for atomicity
+	tx, err := pg.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	// lock the certificate so that it only moves once
+	query := `
+SELECT COALESCE(team_uuid::text, '') FROM certificates
+WHERE uuid = $1 AND user_uuid = $2
+FOR UPDATE`
+	var teamUUID string
+	if err := tx.QueryRow(query, uuid, userUUID).Scan(&teamUUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = &db.ValidationError{Field: "uuid", Err: errors.New("certificate does not exist")}
+		} else {
+			err = fmt.Errorf("failed to query for certificate: %w", err)
+		}
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// the admins of the team of the certificate may give it away for a user
+	// who left
+	if err := authorize(principal, userUUID); err != nil {
+		if teamUUID == "" {
+			return nil, errors.Join(err, tx.Rollback())
+		}
+		if _, _, err := authorizeTeam(tx, principal, teamUUID, db.RoleAdmin); err != nil {
+			return nil, errors.Join(err, tx.Rollback())
+		}
+	}
+
+	// check the new owners
+	if toUserUUID == "" {
+		toUserUUID = userUUID
+	} else if err := checkUser(tx, toUserUUID); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	switch toTeamUUID {
+	case "":
+		toTeamUUID = teamUUID
+	case db.NoTeam:
+		if teamUUID != "" {
+			if _, _, err := authorizeTeam(tx, principal, teamUUID, db.RoleAdmin); err != nil {
+				return nil, errors.Join(err, tx.Rollback())
+			}
+		}
+		toTeamUUID = ""
+	default:
+		if _, _, err := authorizeTeam(tx, principal, toTeamUUID, db.RoleOperator); err != nil {
+			return nil, errors.Join(err, tx.Rollback())
+		}
+	}
+	if toUserUUID == userUUID && toTeamUUID == teamUUID {
+		return nil, errors.Join(&db.ValidationError{Field: "to_user_uuid", Err: errors.New("certificate already belongs to the new owners")}, tx.Rollback())
+	}
+
+	query = `
+UPDATE certificates
+SET user_uuid = $3, team_uuid = NULLIF($4, '')::uuid
+WHERE uuid = $1 AND user_uuid = $2
+RETURNING ` + certColumns
+	transferred, err := scanCert(tx.QueryRow(query, uuid, userUUID, toUserUUID, toTeamUUID))
+	if err != nil {
+		if isUniqueViolation(err, userFingerprintIndex, userIssuerSerialIndex) {
+			err = fmt.Errorf("user %s already has the certificate: %w", toUserUUID, db.ErrConflict)
+		} else {
+			err = fmt.Errorf("failed to transfer certificate: %w", err)
+		}
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	if err := addCertEvent(tx, uuid, principal.UUID, db.CertEventTransferred,
+		map[string]any{"user_uuid": userUUID, "team_uuid": teamUUID},
+		map[string]any{"user_uuid": transferred.UserUUID, "team_uuid": transferred.TeamUUID}); err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	// commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return transferred, nil
+}
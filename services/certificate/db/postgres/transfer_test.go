@@ -0,0 +1,246 @@
+package postgres_test
+
+import (
+	"certificate/db"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// expectCertLock expects certificate `certUUID` of `userUUID` to be locked,
+// belonging to team `teamUUID` if it is not empty.
+func expectCertLock(mock sqlmock.Sqlmock, certUUID, userUUID, teamUUID string) {
+	rows := sqlmock.NewRows([]string{"team_uuid"}).
+		AddRow(teamUUID)
+	mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE uuid = \$1 AND user_uuid = \$2
+FOR UPDATE`).
+		WithArgs(certUUID, userUUID).
+		WillReturnRows(rows)
+}
+
+func TestPostgres_TransferCert(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		transferred := *mockCert0
+		transferred.UserUUID = mockMemberUUID
+		transferred.TeamUUID = mockTeam.UUID
+
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockMemberUUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockMemberUUID).
+			WillReturnRows(rows)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(&transferred)...)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET user_uuid = \$3, team_uuid = NULLIF\(\$4, ''\)::uuid
+WHERE uuid = \$1 AND user_uuid = \$2
+RETURNING (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID, mockMemberUUID, mockTeam.UUID).
+			WillReturnRows(rows)
+		expectCertEvent(mock, mockCert0.UUID, mockUser.UUID, db.CertEventTransferred)
+		mock.ExpectCommit()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, mockMemberUUID, mockTeam.UUID, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(&transferred), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_team_admin_offboarding", func(t *testing.T) {
+		orphan := *mockCert0
+		orphan.UserUUID = "mock_inactive_user_uuid"
+		orphan.TeamUUID = mockTeam.UUID
+		transferred := orphan
+		transferred.UserUUID = mockUser.UUID
+
+		mock.ExpectBegin()
+		expectCertLock(mock, orphan.UUID, orphan.UserUUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleAdmin, db.RoleViewer)
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(&transferred)...)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET (.+)`).
+			WithArgs(orphan.UUID, orphan.UserUUID, mockUser.UUID, mockTeam.UUID).
+			WillReturnRows(rows)
+		expectCertEvent(mock, orphan.UUID, mockUser.UUID, db.CertEventTransferred)
+		mock.ExpectCommit()
+
+		cert, err := pg.TransferCert(orphan.UUID, orphan.UserUUID, mockUser.UUID, "", mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(&transferred), cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("happy_path_remove_team", func(t *testing.T) {
+		teamCert := *mockCert0
+		teamCert.TeamUUID = mockTeam.UUID
+		transferred := *mockCert0
+		transferred.UserUUID = mockMemberUUID
+
+		mock.ExpectBegin()
+		expectCertLock(mock, teamCert.UUID, mockUser.UUID, mockTeam.UUID)
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockMemberUUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockMemberUUID).
+			WillReturnRows(rows)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleAdmin, db.RoleViewer)
+		rows = sqlmock.NewRows(certColumns).
+			AddRow(certValues(&transferred)...)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET (.+)`).
+			WithArgs(teamCert.UUID, mockUser.UUID, mockMemberUUID, "").
+			WillReturnRows(rows)
+		expectCertEvent(mock, teamCert.UUID, mockUser.UUID, db.CertEventTransferred)
+		mock.ExpectCommit()
+
+		cert, err := pg.TransferCert(teamCert.UUID, mockUser.UUID, mockMemberUUID, db.NoTeam, mockPrincipal)
+		assert.Nil(t, err)
+		assert.Equal(t, publicCert(&transferred), cert)
+		assert.Empty(t, cert.TeamUUID)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_remove_team_operator_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, mockUser.UUID, mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, "", db.NoTeam, mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_remove_missing_team_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, mockUser.UUID, "")
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, "", db.NoTeam, mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_other_users_cert_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, "other_user_uuid", "")
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, "other_user_uuid", mockUser.UUID, "", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_team_operator_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, "other_user_uuid", mockTeam.UUID)
+		expectTeamRole(mock, mockTeam.UUID, mockUser.UUID, db.RoleOperator, db.RoleViewer)
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, "other_user_uuid", mockUser.UUID, "", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_inactive_user_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, mockUser.UUID, "")
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockMemberUUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, mockMemberUUID, "", mockPrincipal)
+		assert.NotNil(t, err)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_duplicate_cert_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockMemberUUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockMemberUUID).
+			WillReturnRows(rows)
+		mock.ExpectQuery(`
+^UPDATE certificates
+SET (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID, mockMemberUUID, "").
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "user_fingerprint_idx"})
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, mockMemberUUID, "", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrConflict)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_cert_not_found_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+^SELECT COALESCE\(team_uuid::text, ''\) FROM certificates
+WHERE (.+)`).
+			WithArgs(mockCert0.UUID, mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"team_uuid"}))
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, mockMemberUUID, "", mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "uuid", validationErr.Field)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_same_owner_with_tx_rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCertLock(mock, mockCert0.UUID, mockUser.UUID, "")
+		rows := sqlmock.NewRows([]string{"uuid"}).
+			AddRow(mockUser.UUID)
+		mock.ExpectQuery(`
+^SELECT uuid FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, mockUser.UUID, "", mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "to_user_uuid", validationErr.Field)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("error_no_new_owner", func(t *testing.T) {
+		cert, err := pg.TransferCert(mockCert0.UUID, mockUser.UUID, "", "", mockPrincipal)
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "to_user_uuid", validationErr.Field)
+		assert.Nil(t, cert)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"certificate/db"
+	"database/sql"
+	"errors"
 	"fmt"
 )
 
@@ -22,8 +24,12 @@ RETURNING uuid, created_at`
 }
 
 // GetUser returns the user with UUID `user.UUID`, if `user` does not exist or
-// is not active, it returns an error.
-func (pg *Postgres) GetUser(userUUID string) (*db.User, error) {
+// is not active, it returns an error. It returns db.ErrForbidden if
+// `principal` may not access the user.
+func (pg *Postgres) GetUser(userUUID string, principal *db.Principal) (*db.User, error) {
+	if err := authorize(principal, userUUID); err != nil {
+		return nil, err
+	}
 	user := &db.User{}
 	query := `
 SELECT (uuid, name, email, created_at) FROM users
@@ -35,8 +41,46 @@ WHERE uuid=$1`
 	return user, nil
 }
 
-// DeleteUser sets the user with UUID `userUUID` as inactive.
-func (pg *Postgres) DeleteUser(userUUID string) error {
+// AuthenticateUser returns the active user with email address `email` if
+// `password` is its password, it returns db.ErrUnauthorized otherwise.
+func (pg *Postgres) AuthenticateUser(email, password string) (*db.User, error) {
+	user := &db.User{}
+	query := `
+SELECT uuid, name, email, active, created_at FROM users
+WHERE email = $1 AND active AND password = crypt($2, password)`
+	if err := pg.QueryRow(query, email, password).
+		Scan(&user.UUID, &user.Name, &user.Email, &user.Active, &user.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("wrong email or password, or user is not active: %w", db.ErrUnauthorized)
+		}
+		return nil, fmt.Errorf("failed to query for user: %w", err)
+	}
+	return user, nil
+}
+
+// GetPrincipal returns the principal of the active user with UUID `userUUID`,
+// it returns db.ErrUnauthorized if the user does not exist or is not active.
+func (pg *Postgres) GetPrincipal(userUUID string) (*db.Principal, error) {
+	principal := &db.Principal{}
+	query := `
+SELECT uuid, admin FROM users
+WHERE uuid = $1 AND active`
+	if err := pg.QueryRow(query, userUUID).
+		Scan(&principal.UUID, &principal.Admin); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user does not exist or is not active: %w", db.ErrUnauthorized)
+		}
+		return nil, fmt.Errorf("failed to query for user: %w", err)
+	}
+	return principal, nil
+}
+
+// DeleteUser sets the user with UUID `userUUID` as inactive, it returns
+// db.ErrForbidden if `principal` may not access the user.
+func (pg *Postgres) DeleteUser(userUUID string, principal *db.Principal) error {
+	if err := authorize(principal, userUUID); err != nil {
+		return err
+	}
 	query := `
 UPDATE users
 SET active = False
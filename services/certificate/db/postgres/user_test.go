@@ -2,6 +2,7 @@ package postgres_test
 
 import (
 	"certificate/db"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -15,6 +16,12 @@ var mockUser = &db.User{
 	CreatedAt: time.Now(),
 }
 
+// mockPrincipal is the principal of `mockUser`.
+var mockPrincipal = &db.Principal{UUID: mockUser.UUID}
+
+// mockAdmin is the principal of an admin.
+var mockAdmin = &db.Principal{UUID: "mock_admin_uuid", Admin: true}
+
 func TestPostgres_AddUser(t *testing.T) {
 	pg, mock, _ := MockConnect(t)
 
@@ -76,7 +83,7 @@ WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
 
-		resultUser, err := pg.GetUser(mockUser.UUID)
+		resultUser, err := pg.GetUser(mockUser.UUID, mockPrincipal)
 		assert.Nil(t, err)
 		assert.NotNil(t, resultUser)
 		assert.Nil(t, mock.ExpectationsWereMet())
@@ -92,13 +99,80 @@ WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnRows(rows)
 
-		resultUser, err := pg.GetUser(mockUser.UUID)
+		resultUser, err := pg.GetUser(mockUser.UUID, mockPrincipal)
 		assert.NotNil(t, err)
 		assert.Nil(t, resultUser)
 		assert.Nil(t, mock.ExpectationsWereMet())
 	})
 }
 
+func TestPostgres_AuthenticateUser(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		expected := *mockUser
+		expected.Active = true
+		rows := sqlmock.NewRows([]string{"uuid", "name", "email", "active", "created_at"}).
+			AddRow(mockUser.UUID, mockUser.Name, mockUser.Email, true, mockUser.CreatedAt)
+		mock.ExpectQuery(`
+^SELECT (.+) FROM users
+WHERE email = \$1 AND active AND password = crypt\(\$2, password\)`).
+			WithArgs(mockUser.Email, "tuna").
+			WillReturnRows(rows)
+
+		user, err := pg.AuthenticateUser(mockUser.Email, "tuna")
+		assert.Nil(t, err)
+		assert.Equal(t, &expected, user)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_wrong_password", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"uuid", "name", "email", "active", "created_at"})
+		mock.ExpectQuery(`
+^SELECT (.+) FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.Email, "salmon").
+			WillReturnRows(rows)
+
+		user, err := pg.AuthenticateUser(mockUser.Email, "salmon")
+		assert.True(t, errors.Is(err, db.ErrUnauthorized))
+		assert.Nil(t, user)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgres_GetPrincipal(t *testing.T) {
+	pg, mock, _ := MockConnect(t)
+
+	t.Run("happy_path", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"uuid", "admin"}).
+			AddRow(mockUser.UUID, false)
+		mock.ExpectQuery(`
+^SELECT uuid, admin FROM users
+WHERE uuid = \$1 AND active`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(rows)
+
+		principal, err := pg.GetPrincipal(mockUser.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, mockPrincipal, principal)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error_inactive_user", func(t *testing.T) {
+		mock.ExpectQuery(`
+^SELECT uuid, admin FROM users
+WHERE (.+)*`).
+			WithArgs(mockUser.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid", "admin"}))
+
+		principal, err := pg.GetPrincipal(mockUser.UUID)
+		assert.True(t, errors.Is(err, db.ErrUnauthorized))
+		assert.Nil(t, principal)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestPostgres_DeleteUser(t *testing.T) {
 	pg, mock, _ := MockConnect(t)
 
@@ -110,7 +184,7 @@ WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
-		err := pg.DeleteUser(mockUser.UUID)
+		err := pg.DeleteUser(mockUser.UUID, mockPrincipal)
 		assert.Nil(t, err)
 		assert.Nil(t, mock.ExpectationsWereMet())
 	})
@@ -123,8 +197,14 @@ WHERE (.+)*`).
 			WithArgs(mockUser.UUID).
 			WillReturnResult(sqlmock.NewResult(0, 0))
 
-		err := pg.DeleteUser(mockUser.UUID)
+		err := pg.DeleteUser(mockUser.UUID, mockPrincipal)
 		assert.NotNil(t, err)
 		assert.Nil(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("error_forbidden", func(t *testing.T) {
+		err := pg.DeleteUser("other_user_uuid", mockPrincipal)
+		assert.ErrorIs(t, err, db.ErrForbidden)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
 }
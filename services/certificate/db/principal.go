@@ -0,0 +1,39 @@
+package db
+
+// Principal is the authenticated caller of a database operation. Operations
+// on the records of a user are only allowed to that user and to admins.
+// Principals authenticated with an API key are limited to its `Scopes`.
+type Principal struct {
+	UUID   string   `json:"uuid"`
+	Admin  bool     `json:"admin"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// SystemPrincipal returns the principal of job `name` of the service itself,
+// like renewal, which acts on the records of all users.
+func SystemPrincipal(name string) *Principal {
+	return &Principal{UUID: name, Admin: true}
+}
+
+// HasScope returns whether `p` may make requests of scope `scope`, principals
+// without scopes have all of them.
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	if len(p.Scopes) == 0 {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess returns whether `p` may read and modify the records of the user
+// with UUID `userUUID`.
+func (p *Principal) CanAccess(userUUID string) bool {
+	return p != nil && (p.Admin || p.UUID == userUUID)
+}
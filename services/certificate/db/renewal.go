@@ -0,0 +1,41 @@
+package db
+
+import (
+	"time"
+)
+
+// Renewal methods.
+const (
+	// RenewalMethodCA renews certificates through the internal CA that issued
+	// them.
+	RenewalMethodCA = "ca"
+	// RenewalMethodACME renews certificates through the configured ACME
+	// directory.
+	RenewalMethodACME = "acme"
+)
+
+// RenewalPolicy represents the database schema for the renewal policy of a
+// certificate.
+type RenewalPolicy struct {
+	CertUUID         string    `json:"cert_uuid"`
+	UserUUID         string    `json:"user_uuid"`
+	Method           string    `json:"method"`
+	DaysBeforeExpiry int       `json:"days_before_expiry"`
+	Profile          string    `json:"profile,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at,omitempty"`
+}
+
+// Renewal is a certificate that is due for renewal along with its policy.
+type Renewal struct {
+	Cert   *Cert
+	Policy *RenewalPolicy
+}
+
+// RenewalDatabase is the interface that wraps all database operations related
+// to certificate renewal.
+type RenewalDatabase interface {
+	SetRenewalPolicy(policy *RenewalPolicy, principal *Principal) error
+	DeleteRenewalPolicy(certUUID, userUUID string, principal *Principal) error
+	GetDueRenewals(now time.Time) ([]*Renewal, error)
+	RenewCert(cert *Cert, principal *Principal) error
+}
@@ -18,6 +18,8 @@ type User struct {
 // users.
 type UserDatabase interface {
 	AddUser(user *User) error
-	GetUser(userUUID string) (*User, error)
-	DeleteUser(userUUID string) error
+	GetUser(userUUID string, principal *Principal) (*User, error)
+	AuthenticateUser(email, password string) (*User, error)
+	GetPrincipal(userUUID string) (*Principal, error)
+	DeleteUser(userUUID string, principal *Principal) error
 }
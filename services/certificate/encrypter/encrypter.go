@@ -0,0 +1,24 @@
+package encrypter
+
+// EncryptedKey is a private key sealed with a per-row data key, stored along
+// with the data key wrapped by a master key.
+type EncryptedKey struct {
+	Ciphertext     []byte
+	WrappedDataKey []byte
+	MasterKeyID    string
+}
+
+// KeyEncrypter is the interface that wraps envelope encryption of private
+// keys at rest.
+type KeyEncrypter interface {
+	// MasterKeyID returns the ID of the master key new data keys are wrapped
+	// with.
+	MasterKeyID() string
+	// Encrypt seals `plaintext` with a new data key.
+	Encrypt(plaintext []byte) (*EncryptedKey, error)
+	// Decrypt unwraps the data key of `key` and opens its ciphertext.
+	Decrypt(key *EncryptedKey) ([]byte, error)
+	// Rewrap returns `key` with its data key wrapped by the current master
+	// key, leaving its ciphertext untouched.
+	Rewrap(key *EncryptedKey) (*EncryptedKey, error)
+}
@@ -0,0 +1,197 @@
+package encrypter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// keySize is the size of master and data keys in bytes, selecting AES-256.
+const keySize = 32
+
+// Local is a KeyEncrypter using AES-GCM with master keys read from local
+// files. New data keys are wrapped with the current master key, and retired
+// master keys are kept around to unwrap data keys that have not been rotated
+// yet.
+type Local struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// LoadLocal returns a Local using the master key in file `path` as its
+// current master key.
+func LoadLocal(path string) (*Local, error) {
+	key, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	id := keyID(key)
+	return &Local{currentID: id, keys: map[string][]byte{id: key}}, nil
+}
+
+// AddRetiredKeyFile loads the master key in file `path` so that data keys
+// wrapped with it can still be unwrapped.
+func (l *Local) AddRetiredKeyFile(path string) error {
+	key, err := readKeyFile(path)
+	if err != nil {
+		return err
+	}
+	l.keys[keyID(key)] = key
+	return nil
+}
+
+// GenerateKeyFile writes a new random hex encoded master key to file `path`,
+// it errors out if the file already exists.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return fmt.Errorf("failed to generate master key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create master key file: %w", err)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return errors.Join(fmt.Errorf("failed to write master key file: %w", err), f.Close())
+	}
+	return f.Close()
+}
+
+// readKeyFile reads a hex encoded master key from file `path`.
+func readKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode master key: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("master key is %d bytes, should be %d", len(key), keySize)
+	}
+	return key, nil
+}
+
+// keyID derives a non-secret identifier from master key `key`.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// MasterKeyID returns the ID of the current master key.
+func (l *Local) MasterKeyID() string {
+	return l.currentID
+}
+
+// Encrypt seals `plaintext` with a new random data key, and wraps the data key
+// with the current master key.
+func (l *Local) Encrypt(plaintext []byte) (*EncryptedKey, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal private key: %w", err)
+	}
+	wrapped, err := seal(l.keys[l.currentID], dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return &EncryptedKey{
+		Ciphertext:     ciphertext,
+		WrappedDataKey: wrapped,
+		MasterKeyID:    l.currentID,
+	}, nil
+}
+
+// Decrypt unwraps the data key of `key` with the master key it was wrapped
+// with, and opens the ciphertext of `key` with it.
+func (l *Local) Decrypt(key *EncryptedKey) ([]byte, error) {
+	dataKey, err := l.unwrap(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataKey, key.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open private key: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap unwraps the data key of `key` and wraps it again with the current
+// master key.
+func (l *Local) Rewrap(key *EncryptedKey) (*EncryptedKey, error) {
+	if key.MasterKeyID == l.currentID {
+		return key, nil
+	}
+	dataKey, err := l.unwrap(key)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(l.keys[l.currentID], dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return &EncryptedKey{
+		Ciphertext:     key.Ciphertext,
+		WrappedDataKey: wrapped,
+		MasterKeyID:    l.currentID,
+	}, nil
+}
+
+// unwrap opens the wrapped data key of `key`.
+func (l *Local) unwrap(key *EncryptedKey) ([]byte, error) {
+	masterKey, ok := l.keys[key.MasterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", key.MasterKeyID)
+	}
+	dataKey, err := open(masterKey, key.WrappedDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// seal encrypts `plaintext` with AES-GCM under `key`, and prepends the random
+// nonce to the returned ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts `ciphertext` produced by `seal` under `key`.
+func open(key, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
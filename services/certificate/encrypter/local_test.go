@@ -0,0 +1,137 @@
+package encrypter_test
+
+import (
+	"certificate/encrypter"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const mockPrivateKey = "private_key"
+
+// mockKeyFile generates a master key file in a temporary directory and
+// returns its path.
+func mockKeyFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "master.key")
+	assert.Nil(t, encrypter.GenerateKeyFile(path))
+	return path
+}
+
+func TestGenerateKeyFile(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		path := mockKeyFile(t)
+		info, err := os.Stat(path)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+	t.Run("error_file_exists", func(t *testing.T) {
+		assert.NotNil(t, encrypter.GenerateKeyFile(mockKeyFile(t)))
+	})
+}
+
+func TestLoadLocal(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		l, err := encrypter.LoadLocal(mockKeyFile(t))
+		assert.Nil(t, err)
+		assert.Len(t, l.MasterKeyID(), 16)
+	})
+	t.Run("error_missing_file", func(t *testing.T) {
+		l, err := encrypter.LoadLocal(filepath.Join(t.TempDir(), "missing.key"))
+		assert.NotNil(t, err)
+		assert.Nil(t, l)
+	})
+	t.Run("error_wrong_key_size", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "short.key")
+		assert.Nil(t, os.WriteFile(path, []byte("deadbeef\n"), 0600))
+		l, err := encrypter.LoadLocal(path)
+		assert.NotNil(t, err)
+		assert.Nil(t, l)
+	})
+}
+
+func TestLocal_Encrypt(t *testing.T) {
+	l, err := encrypter.LoadLocal(mockKeyFile(t))
+	assert.Nil(t, err)
+
+	t.Run("happy_path", func(t *testing.T) {
+		encrypted, err := l.Encrypt([]byte(mockPrivateKey))
+		assert.Nil(t, err)
+		assert.Equal(t, l.MasterKeyID(), encrypted.MasterKeyID)
+		assert.NotContains(t, string(encrypted.Ciphertext), mockPrivateKey)
+
+		plaintext, err := l.Decrypt(encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, mockPrivateKey, string(plaintext))
+	})
+	t.Run("happy_path_unique_data_keys", func(t *testing.T) {
+		encrypted0, err := l.Encrypt([]byte(mockPrivateKey))
+		assert.Nil(t, err)
+		encrypted1, err := l.Encrypt([]byte(mockPrivateKey))
+		assert.Nil(t, err)
+		assert.NotEqual(t, encrypted0.WrappedDataKey, encrypted1.WrappedDataKey)
+		assert.NotEqual(t, encrypted0.Ciphertext, encrypted1.Ciphertext)
+	})
+}
+
+func TestLocal_Decrypt(t *testing.T) {
+	l, err := encrypter.LoadLocal(mockKeyFile(t))
+	assert.Nil(t, err)
+	encrypted, err := l.Encrypt([]byte(mockPrivateKey))
+	assert.Nil(t, err)
+
+	t.Run("error_unknown_master_key", func(t *testing.T) {
+		other, err := encrypter.LoadLocal(mockKeyFile(t))
+		assert.Nil(t, err)
+		plaintext, err := other.Decrypt(encrypted)
+		assert.NotNil(t, err)
+		assert.Nil(t, plaintext)
+	})
+	t.Run("error_tampered_ciphertext", func(t *testing.T) {
+		tampered := *encrypted
+		tampered.Ciphertext = append([]byte{}, encrypted.Ciphertext...)
+		tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+		plaintext, err := l.Decrypt(&tampered)
+		assert.NotNil(t, err)
+		assert.Nil(t, plaintext)
+	})
+}
+
+func TestLocal_Rewrap(t *testing.T) {
+	oldKeyFile := mockKeyFile(t)
+	old, err := encrypter.LoadLocal(oldKeyFile)
+	assert.Nil(t, err)
+	encrypted, err := old.Encrypt([]byte(mockPrivateKey))
+	assert.Nil(t, err)
+
+	t.Run("happy_path", func(t *testing.T) {
+		newKeyFile := mockKeyFile(t)
+		l, err := encrypter.LoadLocal(newKeyFile)
+		assert.Nil(t, err)
+		assert.Nil(t, l.AddRetiredKeyFile(oldKeyFile))
+
+		rewrapped, err := l.Rewrap(encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, l.MasterKeyID(), rewrapped.MasterKeyID)
+		assert.Equal(t, encrypted.Ciphertext, rewrapped.Ciphertext)
+
+		// the new master key alone is enough to decrypt after rotation
+		l, err = encrypter.LoadLocal(newKeyFile)
+		assert.Nil(t, err)
+		plaintext, err := l.Decrypt(rewrapped)
+		assert.Nil(t, err)
+		assert.Equal(t, mockPrivateKey, string(plaintext))
+	})
+	t.Run("happy_path_current_master_key", func(t *testing.T) {
+		rewrapped, err := old.Rewrap(encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, encrypted, rewrapped)
+	})
+	t.Run("error_unknown_master_key", func(t *testing.T) {
+		l, err := encrypter.LoadLocal(mockKeyFile(t))
+		assert.Nil(t, err)
+		rewrapped, err := l.Rewrap(encrypted)
+		assert.NotNil(t, err)
+		assert.Nil(t, rewrapped)
+	})
+}
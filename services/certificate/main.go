@@ -1,35 +1,178 @@
 package main
 
 import (
+	"certificate/acme"
+	"certificate/auth"
+	"certificate/ca"
 	"certificate/db/postgres"
+	"certificate/encrypter"
 	"certificate/notifier"
 	"certificate/notifier/kafka"
+	"certificate/ocsp"
 	"certificate/router"
+	"certificate/scheduler"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"strings"
+	"time"
 )
 
 func main() {
+	// load the master key used to encrypt private keys at rest, generating
+	// one on first start
+	masterKeyFile := os.Getenv("MASTER_KEY_FILE")
+	if masterKeyFile == "" {
+		log.Fatal("MASTER_KEY_FILE ENV not set")
+	}
+	if _, err := os.Stat(masterKeyFile); errors.Is(err, fs.ErrNotExist) {
+		log.Println("generating new master key at", masterKeyFile)
+		if err := encrypter.GenerateKeyFile(masterKeyFile); err != nil {
+			log.Fatal(fmt.Errorf("failed to generate master key: %w", err))
+		}
+	}
+	keyEncrypter, err := encrypter.LoadLocal(masterKeyFile)
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to load master key: %w", err))
+	}
+
+	// load the key session tokens are signed with, generating one on first
+	// start, along with the retired keys tokens may still be signed with
+	authKeyFile := os.Getenv("AUTH_KEY_FILE")
+	if authKeyFile == "" {
+		log.Fatal("AUTH_KEY_FILE ENV not set")
+	}
+	if _, err := os.Stat(authKeyFile); errors.Is(err, fs.ErrNotExist) {
+		log.Println("generating new auth signing key at", authKeyFile)
+		if err := auth.GenerateKeyFile(authKeyFile); err != nil {
+			log.Fatal(fmt.Errorf("failed to generate auth signing key: %w", err))
+		}
+	}
+	tokens, err := auth.LoadTokens(authKeyFile)
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to load auth signing key: %w", err))
+	}
+	if env := os.Getenv("AUTH_RETIRED_KEY_FILES"); env != "" {
+		for _, path := range strings.Split(env, ",") {
+			if err := tokens.AddRetiredKeyFile(path); err != nil {
+				log.Fatal(fmt.Errorf("failed to load retired auth signing key: %w", err))
+			}
+		}
+	}
+	tokens.WithExpiry(intervalEnv("AUTH_TOKEN_EXPIRY"))
+
 	// create database instance
 	db, err := postgres.Connect()
 	if err != nil {
 		log.Fatal(fmt.Errorf("failed to connect to db: %w", err))
 	}
+	db.WithKeyEncrypter(keyEncrypter)
+
+	// create a kafka instance for every topic
+	connectKafka := func(topic string) *kafka.Kafka {
+		k := kafka.New().
+			WithNetwork("tcp").
+			WithAddress("kafka:29092").
+			WithTopic(topic).
+			WithPartition(0)
+		if err := k.Connect(); err != nil {
+			log.Fatal(fmt.Errorf("failed to connect to kafka: %w", err))
+		}
+		return k
+	}
+	n := notifier.New(connectKafka(notifier.TopicCertToggled)).
+		WithWriter(notifier.TopicCertExpiring, connectKafka(notifier.TopicCertExpiring)).
+		WithWriter(notifier.TopicCertRenewed, connectKafka(notifier.TopicCertRenewed)).
+		WithWriter(notifier.TopicCertTransferred, connectKafka(notifier.TopicCertTransferred))
+
+	// load CA profiles if configured, otherwise use the defaults
+	profiles := ca.DefaultProfiles
+	if profilesFile := os.Getenv("CA_PROFILES_FILE"); profilesFile != "" {
+		if profiles, err = ca.LoadProfiles(profilesFile); err != nil {
+			log.Fatal(fmt.Errorf("failed to load CA profiles: %w", err))
+		}
+	}
+
+	// issued certificates point to the CRLs and the OCSP responder under the
+	// public URL of the service if it is configured
+	baseURL := os.Getenv("PUBLIC_BASE_URL")
+
+	// create the ACME client if a directory is configured, generating its
+	// account key on first start
+	r := router.New()
+	renewer := scheduler.NewRenewer(db, n).WithProfiles(profiles).WithBaseURL(baseURL)
+	if directoryURL := os.Getenv("ACME_DIRECTORY_URL"); directoryURL != "" {
+		accountKeyFile := os.Getenv("ACME_ACCOUNT_KEY_FILE")
+		if accountKeyFile == "" {
+			log.Fatal("ACME_ACCOUNT_KEY_FILE ENV not set")
+		}
+		if _, err := os.Stat(accountKeyFile); errors.Is(err, fs.ErrNotExist) {
+			log.Println("generating new ACME account key at", accountKeyFile)
+			if err := acme.GenerateAccountKeyFile(accountKeyFile); err != nil {
+				log.Fatal(fmt.Errorf("failed to generate ACME account key: %w", err))
+			}
+		}
+		accountKey, err := acme.LoadAccountKey(accountKeyFile)
+		if err != nil {
+			log.Fatal(fmt.Errorf("failed to load ACME account key: %w", err))
+		}
+		challenges := acme.NewHTTP01Solver()
+		client := acme.New(directoryURL, accountKey).
+			WithSolver(acme.ChallengeHTTP01, challenges)
+		if email := os.Getenv("ACME_EMAIL"); email != "" {
+			client.WithContact(email)
+		}
+		if hook := os.Getenv("ACME_DNS_HOOK"); hook != "" {
+			client.WithSolver(acme.ChallengeDNS01, &acme.DNS01Hook{Command: hook})
+		}
+		r.WithACME(client).WithACMEChallenges(challenges)
+		renewer.WithACME(client)
+	}
 
-	// create kafka instance
-	k := kafka.New().
-		WithNetwork("tcp").
-		WithAddress("kafka:29092").
-		WithTopic("cert-active-status-toggled").
-		WithPartition(0)
-	if err := k.Connect(); err != nil {
-		log.Fatal(fmt.Errorf("failed to connect to kafka: %w", err))
+	// scan for expiring certificates, renew them and publish CRLs in the
+	// background
+	thresholds := scheduler.DefaultExpiryThresholds
+	if env := os.Getenv("EXPIRY_THRESHOLDS"); env != "" {
+		if thresholds, err = scheduler.ParseThresholds(env); err != nil {
+			log.Fatal(fmt.Errorf("failed to parse EXPIRY_THRESHOLDS: %w", err))
+		}
 	}
+	crls := scheduler.NewCRLPublisher(db)
+	scheduler.New().
+		WithJob("expiry scan", intervalEnv("EXPIRY_SCAN_INTERVAL"), scheduler.NewExpiryScanner(db, n, thresholds).Scan).
+		WithJob("renewal", intervalEnv("RENEWAL_INTERVAL"), renewer.Renew).
+		WithJob("CRL publishing", intervalEnv("CRL_INTERVAL"), crls.PublishAll).
+		Start(context.Background())
 
 	// create and start HTTP server
-	if err := router.New().
+	if err := r.
 		WithDatabase(db).
-		WithNotifier(notifier.New(k)).Start("0.0.0.0:8080"); err != nil {
+		WithNotifier(n).
+		WithTokens(tokens).
+		WithProfiles(profiles).
+		WithBaseURL(baseURL).
+		WithCRLPublisher(crls).
+		WithOCSPResponder(ocsp.NewResponder(db)).Start("0.0.0.0:8080"); err != nil {
 		log.Fatal(fmt.Errorf("failed to start http server: %w", err))
 	}
 }
+
+// intervalEnv returns the duration in env `name`, like "30m", or an hour if it
+// is not set.
+func intervalEnv(name string) time.Duration {
+	env := os.Getenv(name)
+	if env == "" {
+		return time.Hour
+	}
+	interval, err := time.ParseDuration(env)
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to parse %s: %w", name, err))
+	}
+	if interval <= 0 {
+		log.Fatal(name + " must be positive")
+	}
+	return interval
+}
@@ -6,28 +6,104 @@ import (
 	"time"
 )
 
-// CertNotifier is the interface that wraps the `SendCertToggled` method.
+// CertNotifier is the interface that wraps all certificate related messages.
 type CertNotifier interface {
-	SendCertToggled(uuid string, active bool) error
+	SendCertToggled(uuid string, active bool, labels map[string]string) error
+	SendCertExpiring(uuid string, notAfter time.Time, thresholdDays int) error
+	SendCertRenewed(uuid, replacesUUID string) error
+	SendCertTransferred(uuid, fromUserUUID, userUUID, teamUUID string) error
 }
 
-// SendCertToggled writes a JSON message using its Writer.
-func (n *Notifier) SendCertToggled(uuid string, active bool) error {
+// SendCertToggled writes a JSON message using its Writer, including the
+// labels of the certificate if it has any.
+func (n *Notifier) SendCertToggled(uuid string, active bool, labels map[string]string) error {
 	// include an UpdatedAt timestamp to message
 	jsonCert, err := json.Marshal(struct {
-		UUID      string    `json:"uuid"`
-		Active    bool      `json:"active"`
-		UpdatedAt time.Time `json:"updated_at"`
+		UUID      string            `json:"uuid"`
+		Active    bool              `json:"active"`
+		Labels    map[string]string `json:"labels,omitempty"`
+		UpdatedAt time.Time         `json:"updated_at"`
 	}{
 		UUID:      uuid,
 		Active:    active,
+		Labels:    labels,
 		UpdatedAt: time.Now(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to marshal cert: %w", err)
 	}
 	// TODO: add retry logic if write fails
-	if err := n.Writer.WriteMessage(jsonCert); err != nil {
+	if err := n.write(TopicCertToggled, jsonCert); err != nil {
+		return fmt.Errorf("failed to send kafka message: %w", err)
+	}
+	return nil
+}
+
+// SendCertExpiring writes a JSON message to TopicCertExpiring saying that
+// certificate `uuid` expires at `notAfter`, within `thresholdDays` days.
+func (n *Notifier) SendCertExpiring(uuid string, notAfter time.Time, thresholdDays int) error {
+	jsonCert, err := json.Marshal(struct {
+		UUID          string    `json:"uuid"`
+		NotAfter      time.Time `json:"not_after"`
+		ThresholdDays int       `json:"threshold_days"`
+		CreatedAt     time.Time `json:"created_at"`
+	}{
+		UUID:          uuid,
+		NotAfter:      notAfter,
+		ThresholdDays: thresholdDays,
+		CreatedAt:     time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cert: %w", err)
+	}
+	if err := n.write(TopicCertExpiring, jsonCert); err != nil {
+		return fmt.Errorf("failed to send kafka message: %w", err)
+	}
+	return nil
+}
+
+// SendCertRenewed writes a JSON message to TopicCertRenewed saying that
+// certificate `uuid` was issued to replace certificate `replacesUUID`.
+func (n *Notifier) SendCertRenewed(uuid, replacesUUID string) error {
+	jsonCert, err := json.Marshal(struct {
+		UUID         string    `json:"uuid"`
+		ReplacesUUID string    `json:"replaces_uuid"`
+		UpdatedAt    time.Time `json:"updated_at"`
+	}{
+		UUID:         uuid,
+		ReplacesUUID: replacesUUID,
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cert: %w", err)
+	}
+	if err := n.write(TopicCertRenewed, jsonCert); err != nil {
+		return fmt.Errorf("failed to send kafka message: %w", err)
+	}
+	return nil
+}
+
+// SendCertTransferred writes a JSON message to TopicCertTransferred saying
+// that certificate `uuid` moved from user `fromUserUUID` to user `userUUID`
+// and team `teamUUID`, if it belongs to one.
+func (n *Notifier) SendCertTransferred(uuid, fromUserUUID, userUUID, teamUUID string) error {
+	jsonCert, err := json.Marshal(struct {
+		UUID         string    `json:"uuid"`
+		FromUserUUID string    `json:"from_user_uuid"`
+		UserUUID     string    `json:"user_uuid"`
+		TeamUUID     string    `json:"team_uuid,omitempty"`
+		UpdatedAt    time.Time `json:"updated_at"`
+	}{
+		UUID:         uuid,
+		FromUserUUID: fromUserUUID,
+		UserUUID:     userUUID,
+		TeamUUID:     teamUUID,
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cert: %w", err)
+	}
+	if err := n.write(TopicCertTransferred, jsonCert); err != nil {
 		return fmt.Errorf("failed to send kafka message: %w", err)
 	}
 	return nil
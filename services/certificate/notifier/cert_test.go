@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 const mockCertUUID = "mock_cert_uuid"
@@ -13,18 +14,85 @@ func TestCertImpl_SendCertToggled(t *testing.T) {
 	mn := &MockNotifier{}
 	n := notifier.New(mn)
 	t.Run("happy_path", func(t *testing.T) {
-		assert.Nil(t, n.SendCertToggled(mockCertUUID, true))
-		assert.Nil(t, n.SendCertToggled(mockCertUUID, false))
+		assert.Nil(t, n.SendCertToggled(mockCertUUID, true, nil))
+		assert.Nil(t, n.SendCertToggled(mockCertUUID, false, nil))
 		assert.Equal(t, 2, len(mn.Messages))
 		assert.Regexp(t, "{\"uuid\":\"mock_cert_uuid\",\"active\":true,\"updated_at\":\"(.+)T(.+)\"}", string(mn.Messages[0]))
 		assert.Regexp(t, "{\"uuid\":\"mock_cert_uuid\",\"active\":false,\"updated_at\":\"(.+)T(.+)\"}", string(mn.Messages[1]))
 	})
+	t.Run("happy_path_labels", func(t *testing.T) {
+		assert.Nil(t, n.SendCertToggled(mockCertUUID, true, map[string]string{"env": "prod", "team": "payments"}))
+		assert.Regexp(t, "{\"uuid\":\"mock_cert_uuid\",\"active\":true,\"labels\":{\"env\":\"prod\",\"team\":\"payments\"},\"updated_at\":\"(.+)T(.+)\"}", string(mn.Messages[len(mn.Messages)-1]))
+	})
 	t.Run("err_write_message", func(t *testing.T) {
 		mn.Err = errors.New("mock_error")
 		defer func() {
 			mn.Err = nil
 		}()
-		assert.NotNil(t, n.SendCertToggled(mockCertUUID, true))
-		assert.NotNil(t, n.SendCertToggled(mockCertUUID, false))
+		assert.NotNil(t, n.SendCertToggled(mockCertUUID, true, nil))
+		assert.NotNil(t, n.SendCertToggled(mockCertUUID, false, nil))
+	})
+}
+
+func TestCertImpl_SendCertExpiring(t *testing.T) {
+	mn := &MockNotifier{}
+	expiring := &MockNotifier{}
+	n := notifier.New(mn).WithWriter(notifier.TopicCertExpiring, expiring)
+	notAfter := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	t.Run("happy_path", func(t *testing.T) {
+		assert.Nil(t, n.SendCertExpiring(mockCertUUID, notAfter, 7))
+		assert.Empty(t, mn.Messages)
+		assert.Equal(t, 1, len(expiring.Messages))
+		assert.Regexp(t, "{\"uuid\":\"mock_cert_uuid\",\"not_after\":\"2024-01-02T03:04:05Z\",\"threshold_days\":7,\"created_at\":\"(.+)T(.+)\"}", string(expiring.Messages[0]))
+	})
+	t.Run("err_write_message", func(t *testing.T) {
+		expiring.Err = errors.New("mock_error")
+		defer func() {
+			expiring.Err = nil
+		}()
+		assert.NotNil(t, n.SendCertExpiring(mockCertUUID, notAfter, 7))
+	})
+	t.Run("err_no_writer", func(t *testing.T) {
+		assert.NotNil(t, notifier.New(mn).SendCertExpiring(mockCertUUID, notAfter, 7))
+	})
+}
+
+func TestCertImpl_SendCertRenewed(t *testing.T) {
+	mn := &MockNotifier{}
+	renewed := &MockNotifier{}
+	n := notifier.New(mn).WithWriter(notifier.TopicCertRenewed, renewed)
+	t.Run("happy_path", func(t *testing.T) {
+		assert.Nil(t, n.SendCertRenewed(mockCertUUID, "mock_replaced_uuid"))
+		assert.Empty(t, mn.Messages)
+		assert.Equal(t, 1, len(renewed.Messages))
+		assert.Regexp(t, "{\"uuid\":\"mock_cert_uuid\",\"replaces_uuid\":\"mock_replaced_uuid\",\"updated_at\":\"(.+)T(.+)\"}", string(renewed.Messages[0]))
+	})
+	t.Run("err_write_message", func(t *testing.T) {
+		renewed.Err = errors.New("mock_error")
+		defer func() {
+			renewed.Err = nil
+		}()
+		assert.NotNil(t, n.SendCertRenewed(mockCertUUID, "mock_replaced_uuid"))
+	})
+}
+
+func TestCertImpl_SendCertTransferred(t *testing.T) {
+	mn := &MockNotifier{}
+	transferred := &MockNotifier{}
+	n := notifier.New(mn).WithWriter(notifier.TopicCertTransferred, transferred)
+	t.Run("happy_path", func(t *testing.T) {
+		assert.Nil(t, n.SendCertTransferred(mockCertUUID, "mock_from_user_uuid", "mock_user_uuid", "mock_team_uuid"))
+		assert.Nil(t, n.SendCertTransferred(mockCertUUID, "mock_from_user_uuid", "mock_user_uuid", ""))
+		assert.Empty(t, mn.Messages)
+		assert.Equal(t, 2, len(transferred.Messages))
+		assert.Regexp(t, "{\"uuid\":\"mock_cert_uuid\",\"from_user_uuid\":\"mock_from_user_uuid\",\"user_uuid\":\"mock_user_uuid\",\"team_uuid\":\"mock_team_uuid\",\"updated_at\":\"(.+)T(.+)\"}", string(transferred.Messages[0]))
+		assert.Regexp(t, "{\"uuid\":\"mock_cert_uuid\",\"from_user_uuid\":\"mock_from_user_uuid\",\"user_uuid\":\"mock_user_uuid\",\"updated_at\":\"(.+)T(.+)\"}", string(transferred.Messages[1]))
+	})
+	t.Run("err_write_message", func(t *testing.T) {
+		transferred.Err = errors.New("mock_error")
+		defer func() {
+			transferred.Err = nil
+		}()
+		assert.NotNil(t, n.SendCertTransferred(mockCertUUID, "mock_from_user_uuid", "mock_user_uuid", ""))
 	})
 }
@@ -1,13 +1,42 @@
 package notifier
 
-// Notifier wraps a Writer.
+import (
+	"fmt"
+)
+
+// Kafka topics messages are sent to.
+const (
+	TopicCertToggled     = "cert-active-status-toggled"
+	TopicCertExpiring    = "cert-expiring"
+	TopicCertRenewed     = "cert-renewed"
+	TopicCertTransferred = "cert-transferred"
+)
+
+// Notifier wraps a Writer for TopicCertToggled, and Writers for every other
+// topic it sends messages to.
 type Notifier struct {
 	Writer
+	writers map[string]Writer
 }
 
-// New returns a Notifier using Writer `w`.
+// New returns a Notifier using Writer `w` for TopicCertToggled.
 func New(w Writer) *Notifier {
-	return &Notifier{Writer: w}
+	return &Notifier{Writer: w, writers: map[string]Writer{TopicCertToggled: w}}
+}
+
+// WithWriter sets the Writer used for messages to `topic`.
+func (n *Notifier) WithWriter(topic string, w Writer) *Notifier {
+	n.writers[topic] = w
+	return n
+}
+
+// write sends `value` with the Writer of `topic`.
+func (n *Notifier) write(topic string, value []byte) error {
+	w, ok := n.writers[topic]
+	if !ok {
+		return fmt.Errorf("no writer for topic %q", topic)
+	}
+	return w.WriteMessage(value)
 }
 
 // Writer is the interface that wraps the `WriteMessage` method.
@@ -0,0 +1,228 @@
+package ocsp
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"certificate/pki"
+	"crypto"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"sync"
+	"time"
+)
+
+const (
+	// responseValidity is how long signed responses are valid for, cached
+	// responses are signed again once half of it has passed.
+	responseValidity = time.Hour
+	// responderValidity is how long delegated responder certificates are
+	// valid for.
+	responderValidity = 7 * 24 * time.Hour
+	// issuerReloadInterval is how often authorities are reloaded at most to
+	// look up issuers that are not known yet.
+	issuerReloadInterval = time.Minute
+)
+
+// response is a signed response cached for the certificate it is about.
+type response struct {
+	certUUID   string
+	der        []byte
+	thisUpdate time.Time
+}
+
+// Responder answers OCSP (RFC 6960) requests about the certificates issued by
+// internal CAs, signing responses with a delegated responder certificate of
+// each CA. Signed responses are cached until they are half way to their next
+// update or the certificate they are about changes.
+type Responder struct {
+	db db.Database
+
+	mu         sync.Mutex
+	issuers    map[string]string
+	reloaded   map[crypto.Hash]time.Time
+	responders map[string]*ca.OCSPResponder
+	responses  map[string]*response
+}
+
+// NewResponder returns a Responder for the authorities and certificates in
+// `db`.
+func NewResponder(db db.Database) *Responder {
+	return &Responder{
+		db:         db,
+		issuers:    map[string]string{},
+		reloaded:   map[crypto.Hash]time.Time{},
+		responders: map[string]*ca.OCSPResponder{},
+		responses:  map[string]*response{},
+	}
+}
+
+// Respond returns the DER encoded response to DER encoded OCSP request
+// `request`. Malformed requests and requests about certificates of unknown
+// issuers are answered with an OCSP error response, other failures are
+// returned as errors.
+func (r *Responder) Respond(request []byte) ([]byte, error) {
+	req, err := ocsp.ParseRequest(request)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+	authorityUUID, err := r.issuer(req)
+	if err != nil {
+		return nil, err
+	}
+	if authorityUUID == "" {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	// answer from the cache unless the response is getting old
+	key := authorityUUID + "/" + req.SerialNumber.Text(16)
+	r.mu.Lock()
+	cached, ok := r.responses[key]
+	r.mu.Unlock()
+	if ok && time.Since(cached.thisUpdate) < responseValidity/2 {
+		return cached.der, nil
+	}
+
+	cert, err := r.db.GetCertBySerialNumber(authorityUUID, req.SerialNumber.Text(16))
+	if err != nil {
+		return nil, err
+	}
+	responder, err := r.responder(authorityUUID)
+	if err != nil {
+		return nil, err
+	}
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: req.SerialNumber,
+		IssuerHash:   req.HashAlgorithm,
+		ThisUpdate:   time.Now().UTC().Truncate(time.Second),
+	}
+	template.NextUpdate = template.ThisUpdate.Add(responseValidity)
+	switch {
+	case cert == nil:
+	case cert.Status == db.CertStatusRevoked:
+		template.Status = ocsp.Revoked
+		template.RevokedAt = cert.RevokedAt
+		template.RevocationReason = cert.RevocationReason
+	case cert.Status == db.CertStatusIssued:
+		template.Status = ocsp.Good
+	}
+	der, err := responder.Sign(template)
+	if err != nil {
+		return nil, err
+	}
+
+	// unknown serial numbers are not cached, anyone can ask for any number of
+	// them
+	if cert != nil {
+		r.mu.Lock()
+		r.responses[key] = &response{certUUID: cert.UUID, der: der, thisUpdate: template.ThisUpdate}
+		r.mu.Unlock()
+	}
+	return der, nil
+}
+
+// Invalidate drops the cached responses about certificate `certUUID`, so
+// that the next request about it is answered with its current status.
+func (r *Responder) Invalidate(certUUID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, cached := range r.responses {
+		if cached.certUUID == certUUID {
+			delete(r.responses, key)
+		}
+	}
+}
+
+// issuer returns the UUID of the authority identified by the issuer name
+// and key hashes of `req`, or an empty string if there is no such authority.
+// Authorities are reloaded at most once per issuerReloadInterval and hash
+// algorithm, so that requests about unknown issuers are cheap.
+func (r *Responder) issuer(req *ocsp.Request) (string, error) {
+	key := fmt.Sprintf("%d/%x/%x", req.HashAlgorithm, req.IssuerNameHash, req.IssuerKeyHash)
+	r.mu.Lock()
+	authorityUUID, ok := r.issuers[key]
+	reloaded := r.reloaded[req.HashAlgorithm]
+	r.mu.Unlock()
+	if ok {
+		return authorityUUID, nil
+	}
+	if time.Since(reloaded) < issuerReloadInterval {
+		return "", nil
+	}
+
+	// hash every authority with the algorithm of the request, new authorities
+	// may have been added since the last lookup
+	authorities, err := r.db.GetAuthorities()
+	if err != nil {
+		return "", fmt.Errorf("failed to get authorities: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reloaded[req.HashAlgorithm] = time.Now()
+	for _, authority := range authorities {
+		nameHash, keyHash, err := issuerHashes(authority.Body, req.HashAlgorithm)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash authority %s: %w", authority.UUID, err)
+		}
+		r.issuers[fmt.Sprintf("%d/%x/%x", req.HashAlgorithm, nameHash, keyHash)] = authority.UUID
+	}
+	return r.issuers[key], nil
+}
+
+// responder returns the delegated responder of authority `authorityUUID`,
+// creating a new one if it has none yet or its current one expires before
+// the responses it would sign.
+func (r *Responder) responder(authorityUUID string) (*ca.OCSPResponder, error) {
+	r.mu.Lock()
+	responder, ok := r.responders[authorityUUID]
+	r.mu.Unlock()
+	if ok && time.Now().Add(responseValidity).Before(responder.Cert.NotAfter) {
+		return responder, nil
+	}
+
+	authority, err := r.db.GetAuthority(authorityUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authority: %w", err)
+	}
+	loaded, err := ca.Load(authority.Body, authority.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authority: %w", err)
+	}
+	if responder, err = loaded.NewOCSPResponder(responderValidity); err != nil {
+		return nil, err
+	}
+	if !time.Now().Add(responseValidity).Before(responder.Cert.NotAfter) {
+		return nil, errors.New("authority expires before the responses it would sign")
+	}
+	r.mu.Lock()
+	r.responders[authorityUUID] = responder
+	r.mu.Unlock()
+	return responder, nil
+}
+
+// issuerHashes returns the hashes of the subject name and the public key of
+// PEM encoded CA certificate `body` with `hash`, which identify it in OCSP
+// requests.
+func issuerHashes(body string, hash crypto.Hash) ([]byte, []byte, error) {
+	if !hash.Available() {
+		return nil, nil, fmt.Errorf("unsupported hash algorithm %s", hash)
+	}
+	cert, err := pki.ParseCertificate(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	var publicKeyInfo struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal public key: %w", err)
+	}
+	nameHash := hash.New()
+	nameHash.Write(cert.RawSubject)
+	keyHash := hash.New()
+	keyHash.Write(publicKeyInfo.PublicKey.RightAlign())
+	return nameHash.Sum(nil), keyHash.Sum(nil), nil
+}
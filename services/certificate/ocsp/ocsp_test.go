@@ -0,0 +1,179 @@
+package ocsp_test
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"certificate/ocsp"
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	xocsp "golang.org/x/crypto/ocsp"
+	"testing"
+	"time"
+)
+
+// MockDatabase returns `Authorities` and the certificates in `Certs` by
+// serial number, and counts the authority reloads and certificate lookups.
+type MockDatabase struct {
+	db.Database
+	Authorities map[string]*db.Authority
+	Certs       map[string]*db.Cert
+	Reloads     int
+	Lookups     int
+	Err         error
+}
+
+func (m *MockDatabase) GetAuthority(uuid string) (*db.Authority, error) {
+	authority, ok := m.Authorities[uuid]
+	if !ok {
+		return nil, errors.New("authority does not exist")
+	}
+	return authority, nil
+}
+
+func (m *MockDatabase) GetAuthorities() ([]*db.Authority, error) {
+	m.Reloads++
+	var authorities []*db.Authority
+	for _, authority := range m.Authorities {
+		authorities = append(authorities, authority)
+	}
+	return authorities, m.Err
+}
+
+func (m *MockDatabase) GetCertBySerialNumber(authorityUUID, serialNumber string) (*db.Cert, error) {
+	m.Lookups++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	cert, ok := m.Certs[serialNumber]
+	if !ok || cert.AuthorityUUID != authorityUUID {
+		return nil, nil
+	}
+	return cert, nil
+}
+
+func TestResponder_Respond(t *testing.T) {
+	root, err := ca.NewRoot(pkix.Name{CommonName: "Mock Root CA"}, 24*time.Hour)
+	assert.Nil(t, err)
+	rootKey, err := pki.EncodePrivateKey(root.Key)
+	assert.Nil(t, err)
+	authority := &db.Authority{UUID: "mock_authority_uuid", Body: pki.EncodeCertificate(root.Cert), PrivateKey: rootKey}
+
+	// newCert returns a new certificate issued by `root` along with its
+	// database record.
+	newCert := func(uuid, status string) (*x509.Certificate, *db.Cert) {
+		csr, err := pki.CreateCSR(pkitest.NewKey(), pkix.Name{CommonName: "dog.example.com"}, []string{"dog.example.com"})
+		assert.Nil(t, err)
+		parsed, err := ca.ParseCSR(csr)
+		assert.Nil(t, err)
+		issued, err := root.Sign(parsed, ca.DefaultProfiles["server"])
+		assert.Nil(t, err)
+		return issued, &db.Cert{
+			UUID:          uuid,
+			AuthorityUUID: authority.UUID,
+			Status:        status,
+			SerialNumber:  pki.SerialNumber(issued),
+		}
+	}
+	goodX509, good := newCert("mock_good_cert_uuid", db.CertStatusIssued)
+	revokedX509, revoked := newCert("mock_revoked_cert_uuid", db.CertStatusRevoked)
+	revoked.RevokedAt = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	revoked.RevocationReason = pki.ReasonKeyCompromise
+	unknownX509, _ := newCert("mock_unknown_cert_uuid", db.CertStatusIssued)
+	newDatabase := func() *MockDatabase {
+		return &MockDatabase{
+			Authorities: map[string]*db.Authority{authority.UUID: authority},
+			Certs:       map[string]*db.Cert{good.SerialNumber: good, revoked.SerialNumber: revoked},
+		}
+	}
+
+	// respond asks `responder` about `cert` and returns the parsed response.
+	respond := func(t *testing.T, responder *ocsp.Responder, cert *x509.Certificate) *xocsp.Response {
+		request, err := xocsp.CreateRequest(cert, root.Cert, &xocsp.RequestOptions{Hash: crypto.SHA256})
+		assert.Nil(t, err)
+		der, err := responder.Respond(request)
+		assert.Nil(t, err)
+		resp, err := xocsp.ParseResponseForCert(der, cert, root.Cert)
+		assert.Nil(t, err)
+		return resp
+	}
+
+	t.Run("happy_path_good", func(t *testing.T) {
+		resp := respond(t, ocsp.NewResponder(newDatabase()), goodX509)
+		assert.Equal(t, xocsp.Good, resp.Status)
+		assert.Equal(t, goodX509.SerialNumber, resp.SerialNumber)
+		assert.Equal(t, time.Hour, resp.NextUpdate.Sub(resp.ThisUpdate))
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, resp.Certificate.ExtKeyUsage)
+	})
+	t.Run("happy_path_revoked", func(t *testing.T) {
+		resp := respond(t, ocsp.NewResponder(newDatabase()), revokedX509)
+		assert.Equal(t, xocsp.Revoked, resp.Status)
+		assert.Equal(t, revoked.RevokedAt, resp.RevokedAt)
+		assert.Equal(t, xocsp.KeyCompromise, resp.RevocationReason)
+	})
+	t.Run("happy_path_unknown", func(t *testing.T) {
+		resp := respond(t, ocsp.NewResponder(newDatabase()), unknownX509)
+		assert.Equal(t, xocsp.Unknown, resp.Status)
+	})
+	t.Run("happy_path_deactivated", func(t *testing.T) {
+		mdb := newDatabase()
+		deactivated := *good
+		deactivated.Active = false
+		mdb.Certs[good.SerialNumber] = &deactivated
+
+		resp := respond(t, ocsp.NewResponder(mdb), goodX509)
+		assert.Equal(t, xocsp.Good, resp.Status)
+	})
+	t.Run("happy_path_cached_until_invalidated", func(t *testing.T) {
+		mdb := newDatabase()
+		responder := ocsp.NewResponder(mdb)
+		assert.Equal(t, xocsp.Good, respond(t, responder, goodX509).Status)
+
+		// the status change is only seen after invalidating the cache
+		changed := *good
+		changed.Status = db.CertStatusRevoked
+		mdb.Certs[good.SerialNumber] = &changed
+		assert.Equal(t, xocsp.Good, respond(t, responder, goodX509).Status)
+		assert.Equal(t, 1, mdb.Lookups)
+
+		responder.Invalidate(good.UUID)
+		assert.Equal(t, xocsp.Revoked, respond(t, responder, goodX509).Status)
+		assert.Equal(t, 2, mdb.Lookups)
+	})
+	t.Run("happy_path_malformed", func(t *testing.T) {
+		der, err := ocsp.NewResponder(newDatabase()).Respond([]byte("request"))
+		assert.Nil(t, err)
+		assert.Equal(t, xocsp.MalformedRequestErrorResponse, der)
+	})
+	t.Run("happy_path_unknown_issuer", func(t *testing.T) {
+		other, err := ca.NewRoot(pkix.Name{CommonName: "Other Root CA"}, 24*time.Hour)
+		assert.Nil(t, err)
+		request, err := xocsp.CreateRequest(goodX509, other.Cert, nil)
+		assert.Nil(t, err)
+
+		mdb := newDatabase()
+		responder := ocsp.NewResponder(mdb)
+		for i := 0; i < 3; i++ {
+			der, err := responder.Respond(request)
+			assert.Nil(t, err)
+			assert.Equal(t, xocsp.UnauthorizedErrorResponse, der)
+		}
+
+		// authorities are not reloaded for every request about the issuer
+		assert.Equal(t, 1, mdb.Reloads)
+	})
+	t.Run("error_database", func(t *testing.T) {
+		mdb := newDatabase()
+		mdb.Err = errors.New("mock_error")
+		request, err := xocsp.CreateRequest(goodX509, root.Cert, nil)
+		assert.Nil(t, err)
+
+		der, err := ocsp.NewResponder(mdb).Respond(request)
+		assert.NotNil(t, err)
+		assert.Nil(t, der)
+	})
+}
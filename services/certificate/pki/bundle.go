@@ -0,0 +1,110 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// pemTypeECParameters is the type of the PEM block OpenSSL writes before SEC1
+// keys, it only names the curve of the key.
+const pemTypeECParameters = "EC PARAMETERS"
+
+// Bundle is a certificate along with its private key and chain, as found in
+// PKCS#12 files and PEM bundles.
+type Bundle struct {
+	// Key is nil if the bundle has no private key.
+	Key   crypto.Signer
+	Leaf  *x509.Certificate
+	Chain []*x509.Certificate
+}
+
+// ParseBundle splits `data`, either PEM blocks or a PKCS#12 file encrypted
+// with `password`, into a private key, the certificate of that key, and the
+// other certificates as its chain. Without a private key, the certificate is
+// the first one that is not a CA certificate.
+func ParseBundle(data []byte, password string) (*Bundle, error) {
+	var key crypto.Signer
+	var certs []*x509.Certificate
+	if block, _ := pem.Decode(data); block != nil {
+		keys, pemCerts, err := ParsePEM(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 1 {
+			return nil, errors.New("bundle has more than one private key")
+		}
+		if len(keys) == 1 {
+			key = keys[0]
+		}
+		certs = pemCerts
+	} else {
+		decodedKey, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PKCS#12: %w", err)
+		}
+		signer, ok := decodedKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", decodedKey)
+		}
+		key, certs = signer, append([]*x509.Certificate{cert}, caCerts...)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("bundle has no certificate")
+	}
+
+	// find the certificate of the key, or the first end-entity certificate
+	leaf := -1
+	for i, cert := range certs {
+		if key != nil && CheckKeyPair(cert, key) == nil || key == nil && !cert.IsCA {
+			leaf = i
+			break
+		}
+	}
+	switch {
+	case leaf < 0 && key != nil:
+		return nil, errors.New("private key does not belong to any certificate of the bundle")
+	case leaf < 0:
+		leaf = 0
+	}
+	bundle := &Bundle{Key: key, Leaf: certs[leaf]}
+	bundle.Chain = append(append(bundle.Chain, certs[:leaf]...), certs[leaf+1:]...)
+	return bundle, nil
+}
+
+// ParsePEM returns the private keys and the certificates in PEM blocks
+// `data`, in the order they appear.
+func ParsePEM(data []byte) ([]crypto.Signer, []*x509.Certificate, error) {
+	var keys []crypto.Signer
+	var certs []*x509.Certificate
+	for rest := data; len(bytes.TrimSpace(rest)) > 0; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil && keys == nil && certs == nil {
+			return nil, nil, errors.New("no PEM data found")
+		} else if block == nil {
+			return nil, nil, errors.New("trailing data after PEM blocks")
+		}
+		switch block.Type {
+		case pemTypeCertificate:
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse x509 certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		case pemTypePKCS1, pemTypePKCS8, pemTypeSEC1:
+			key, err := ParsePrivateKey(string(pem.EncodeToMemory(block)))
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, key)
+		case pemTypeECParameters:
+		default:
+			return nil, nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+	}
+	return keys, certs, nil
+}
@@ -0,0 +1,99 @@
+package pki_test
+
+import (
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto/x509"
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+	"testing"
+)
+
+func TestParseBundle(t *testing.T) {
+	root, intermediate, leaf, key := mockChain()
+	encodedKey, err := pki.EncodePrivateKey(key)
+	assert.Nil(t, err)
+
+	t.Run("happy_path_pem", func(t *testing.T) {
+		data := pki.EncodeCertificates([]*x509.Certificate{intermediate, leaf, root}) + encodedKey
+		bundle, err := pki.ParseBundle([]byte(data), "")
+		assert.Nil(t, err)
+		assert.Equal(t, key, bundle.Key)
+		assert.Equal(t, leaf, bundle.Leaf)
+		assert.Equal(t, []*x509.Certificate{intermediate, root}, bundle.Chain)
+	})
+	t.Run("happy_path_pem_without_key", func(t *testing.T) {
+		data := pki.EncodeCertificates([]*x509.Certificate{root, intermediate, leaf})
+		bundle, err := pki.ParseBundle([]byte(data), "")
+		assert.Nil(t, err)
+		assert.Nil(t, bundle.Key)
+		assert.Equal(t, leaf, bundle.Leaf)
+		assert.Equal(t, []*x509.Certificate{root, intermediate}, bundle.Chain)
+	})
+	t.Run("happy_path_p12", func(t *testing.T) {
+		data, err := pkcs12.Modern.Encode(key, leaf, []*x509.Certificate{intermediate, root}, "mock_password")
+		assert.Nil(t, err)
+		bundle, err := pki.ParseBundle(data, "mock_password")
+		assert.Nil(t, err)
+		assert.Equal(t, key, bundle.Key)
+		assert.Equal(t, leaf, bundle.Leaf)
+		assert.Equal(t, []*x509.Certificate{intermediate, root}, bundle.Chain)
+	})
+	t.Run("error_p12_wrong_password", func(t *testing.T) {
+		data, err := pkcs12.Modern.Encode(key, leaf, nil, "mock_password")
+		assert.Nil(t, err)
+		bundle, err := pki.ParseBundle(data, "wrong_password")
+		assert.NotNil(t, err)
+		assert.Nil(t, bundle)
+	})
+	t.Run("error_key_mismatch", func(t *testing.T) {
+		data := pki.EncodeCertificate(leaf) + pkitest.EncodeKey(pkitest.NewKey())
+		bundle, err := pki.ParseBundle([]byte(data), "")
+		assert.NotNil(t, err)
+		assert.Nil(t, bundle)
+	})
+	t.Run("error_two_keys", func(t *testing.T) {
+		data := pki.EncodeCertificate(leaf) + encodedKey + encodedKey
+		bundle, err := pki.ParseBundle([]byte(data), "")
+		assert.NotNil(t, err)
+		assert.Nil(t, bundle)
+	})
+	t.Run("error_no_certificate", func(t *testing.T) {
+		bundle, err := pki.ParseBundle([]byte(encodedKey), "")
+		assert.NotNil(t, err)
+		assert.Nil(t, bundle)
+	})
+	t.Run("error_not_a_bundle", func(t *testing.T) {
+		bundle, err := pki.ParseBundle([]byte("bundle"), "")
+		assert.NotNil(t, err)
+		assert.Nil(t, bundle)
+	})
+}
+
+func TestParsePEM(t *testing.T) {
+	root, _, leaf, key := mockChain()
+	encodedKey, err := pki.EncodePrivateKey(key)
+	assert.Nil(t, err)
+
+	t.Run("happy_path", func(t *testing.T) {
+		otherKey := pkitest.NewKey()
+		data := encodedKey + pki.EncodeCertificates([]*x509.Certificate{leaf, root}) + pkitest.EncodeKey(otherKey)
+		keys, certs, err := pki.ParsePEM([]byte(data))
+		assert.Nil(t, err)
+		assert.Len(t, keys, 2)
+		assert.Equal(t, key, keys[0])
+		assert.Equal(t, []*x509.Certificate{leaf, root}, certs)
+	})
+	t.Run("error_unexpected_block_type", func(t *testing.T) {
+		keys, certs, err := pki.ParsePEM([]byte("-----BEGIN CERTIFICATE REQUEST-----\n-----END CERTIFICATE REQUEST-----\n"))
+		assert.NotNil(t, err)
+		assert.Nil(t, keys)
+		assert.Nil(t, certs)
+	})
+	t.Run("error_not_pem", func(t *testing.T) {
+		keys, certs, err := pki.ParsePEM([]byte("cert"))
+		assert.NotNil(t, err)
+		assert.Nil(t, keys)
+		assert.Nil(t, certs)
+	})
+}
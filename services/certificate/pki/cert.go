@@ -0,0 +1,151 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+const pemTypeCertificate = "CERTIFICATE"
+
+// ParseCertificate decodes `body` as a single X.509 certificate. `body` can
+// be PEM encoded, base64 encoded DER, or raw DER.
+func ParseCertificate(body string) (*x509.Certificate, error) {
+	der, err := certificateDER(body)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse x509 certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// certificateDER extracts the DER bytes of the certificate in `body`.
+func certificateDER(body string) ([]byte, error) {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" {
+		return nil, errors.New("certificate body is empty")
+	}
+	if block, _ := pem.Decode([]byte(trimmed)); block != nil {
+		if block.Type != pemTypeCertificate {
+			return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+		return block.Bytes, nil
+	}
+	if der, err := base64.StdEncoding.DecodeString(trimmed); err == nil {
+		return der, nil
+	}
+	return []byte(body), nil
+}
+
+// EncodeCertificate returns `cert` as a PEM encoded string.
+func EncodeCertificate(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: pemTypeCertificate, Bytes: cert.Raw}))
+}
+
+// Fingerprint returns the hex encoded SHA-256 digest of the DER encoding of
+// `cert`.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// SerialNumber returns the serial number of `cert` as lowercase hex.
+func SerialNumber(cert *x509.Certificate) string {
+	return cert.SerialNumber.Text(16)
+}
+
+// NormalizeFingerprint returns `fingerprint`, a hex encoded SHA-256
+// fingerprint optionally separated by colons, in the format of Fingerprint.
+func NormalizeFingerprint(fingerprint string) (string, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	if decoded, err := hex.DecodeString(normalized); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("%q is not a hex encoded SHA-256 fingerprint", fingerprint)
+	}
+	return normalized, nil
+}
+
+// NormalizeSerialNumber returns `serialNumber`, hex encoded and optionally
+// separated by colons, in the format of SerialNumber.
+func NormalizeSerialNumber(serialNumber string) (string, error) {
+	n, ok := new(big.Int).SetString(strings.ReplaceAll(serialNumber, ":", ""), 16)
+	if !ok || n.Sign() < 0 {
+		return "", fmt.Errorf("%q is not a hex encoded serial number", serialNumber)
+	}
+	return n.Text(16), nil
+}
+
+// SANs returns every subject alternative name of `cert` as a string: DNS
+// names, IP addresses, email addresses and URIs, in that order. DNS names are
+// lowercased like NormalizeSAN does.
+func SANs(cert *x509.Certificate) []string {
+	var sans []string
+	for _, name := range cert.DNSNames {
+		sans = append(sans, strings.ToLower(name))
+	}
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// NormalizeSAN returns subject alternative name `san` the way SANs returns
+// it: DNS names, which are case-insensitive, are lowercased and IP addresses
+// are put in canonical form, email addresses and URIs are kept as they are.
+func NormalizeSAN(san string) string {
+	if ip := net.ParseIP(san); ip != nil {
+		return ip.String()
+	}
+	if strings.Contains(san, "://") || strings.Contains(san, "@") {
+		return san
+	}
+	return strings.ToLower(san)
+}
+
+// CoveringSANs returns the subject alternative names that cover `name`: the
+// name itself, normalized with NormalizeSAN, and the wildcard DNS name one
+// level above it. IP addresses, email addresses, URIs and wildcard names are
+// only covered by themselves.
+func CoveringSANs(name string) []string {
+	name = NormalizeSAN(name)
+	sans := []string{name}
+	if strings.HasPrefix(name, "*.") || net.ParseIP(name) != nil ||
+		strings.Contains(name, "://") || strings.Contains(name, "@") {
+		return sans
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok && strings.Contains(parent, ".") {
+		sans = append(sans, "*."+parent)
+	}
+	return sans
+}
+
+// KeyAlgorithm describes the public key of `cert`, e.g. "RSA-2048",
+// "ECDSA-P256" or "Ed25519".
+func KeyAlgorithm(cert *x509.Certificate) string {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + strings.ReplaceAll(pub.Curve.Params().Name, "-", "")
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
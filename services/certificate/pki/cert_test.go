@@ -0,0 +1,137 @@
+package pki_test
+
+import (
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mockNotBefore = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var mockX509Cert, mockCertPEM, _ = pkitest.SelfSigned(&x509.Certificate{
+	SerialNumber:   big.NewInt(0xbeef),
+	Subject:        pkix.Name{CommonName: "mock.example.com"},
+	DNSNames:       []string{"mock.example.com", "*.mock.example.com"},
+	IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+	EmailAddresses: []string{"dog@cat.com"},
+	URIs:           []*url.URL{{Scheme: "spiffe", Host: "cat.com", Path: "/dog"}},
+	NotBefore:      mockNotBefore,
+	NotAfter:       mockNotBefore.Add(24 * time.Hour),
+})
+
+func TestParseCertificate(t *testing.T) {
+	t.Run("happy_path_pem", func(t *testing.T) {
+		cert, err := pki.ParseCertificate(mockCertPEM)
+		assert.Nil(t, err)
+		assert.Equal(t, mockX509Cert.Raw, cert.Raw)
+	})
+	t.Run("happy_path_base64_der", func(t *testing.T) {
+		cert, err := pki.ParseCertificate(base64.StdEncoding.EncodeToString(mockX509Cert.Raw))
+		assert.Nil(t, err)
+		assert.Equal(t, mockX509Cert.Raw, cert.Raw)
+	})
+	t.Run("happy_path_der", func(t *testing.T) {
+		cert, err := pki.ParseCertificate(string(mockX509Cert.Raw))
+		assert.Nil(t, err)
+		assert.Equal(t, mockX509Cert.Raw, cert.Raw)
+	})
+	t.Run("error_empty", func(t *testing.T) {
+		cert, err := pki.ParseCertificate(" \n")
+		assert.NotNil(t, err)
+		assert.Nil(t, cert)
+	})
+	t.Run("error_malformed", func(t *testing.T) {
+		cert, err := pki.ParseCertificate("cert_body")
+		assert.NotNil(t, err)
+		assert.Nil(t, cert)
+	})
+	t.Run("error_wrong_pem_type", func(t *testing.T) {
+		cert, err := pki.ParseCertificate(pkitest.EncodeKey(pkitest.NewKey()))
+		assert.NotNil(t, err)
+		assert.Nil(t, cert)
+	})
+}
+
+func TestEncodeCertificate(t *testing.T) {
+	assert.Equal(t, mockCertPEM, pki.EncodeCertificate(mockX509Cert))
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Regexp(t, "^[0-9a-f]{64}$", pki.Fingerprint(mockX509Cert))
+}
+
+func TestSerialNumber(t *testing.T) {
+	assert.Equal(t, "beef", pki.SerialNumber(mockX509Cert))
+}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	fingerprint := pki.Fingerprint(mockX509Cert)
+	normalized, err := pki.NormalizeFingerprint(strings.ToUpper(fingerprint))
+	assert.Nil(t, err)
+	assert.Equal(t, fingerprint, normalized)
+	var colons []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colons = append(colons, fingerprint[i:i+2])
+	}
+	normalized, err = pki.NormalizeFingerprint(strings.Join(colons, ":"))
+	assert.Nil(t, err)
+	assert.Equal(t, fingerprint, normalized)
+	_, err = pki.NormalizeFingerprint("beef")
+	assert.NotNil(t, err)
+}
+
+func TestNormalizeSerialNumber(t *testing.T) {
+	normalized, err := pki.NormalizeSerialNumber("00:BE:EF")
+	assert.Nil(t, err)
+	assert.Equal(t, pki.SerialNumber(mockX509Cert), normalized)
+	_, err = pki.NormalizeSerialNumber("dog")
+	assert.NotNil(t, err)
+}
+
+func TestSANs(t *testing.T) {
+	assert.Equal(t, []string{
+		"mock.example.com",
+		"*.mock.example.com",
+		"10.0.0.1",
+		"dog@cat.com",
+		"spiffe://cat.com/dog",
+	}, pki.SANs(mockX509Cert))
+
+	// DNS names are lowercased
+	cert, _, _ := pkitest.SelfSigned(&x509.Certificate{
+		SerialNumber: big.NewInt(0xbeef),
+		DNSNames:     []string{"Mock.Example.com"},
+		NotBefore:    mockNotBefore,
+		NotAfter:     mockNotBefore.Add(24 * time.Hour),
+	})
+	assert.Equal(t, []string{"mock.example.com"}, pki.SANs(cert))
+}
+
+func TestCoveringSANs(t *testing.T) {
+	assert.Equal(t, []string{"dog.mock.example.com", "*.mock.example.com"}, pki.CoveringSANs("Dog.Mock.Example.com"))
+	assert.Equal(t, []string{"*.mock.example.com"}, pki.CoveringSANs("*.mock.example.com"))
+	assert.Equal(t, []string{"example.com"}, pki.CoveringSANs("example.com"))
+	assert.Equal(t, []string{"10.0.0.1"}, pki.CoveringSANs("10.0.0.1"))
+	assert.Equal(t, []string{"spiffe://cat.com/Dog"}, pki.CoveringSANs("spiffe://cat.com/Dog"))
+}
+
+func TestNormalizeSAN(t *testing.T) {
+	assert.Equal(t, "dog.example.com", pki.NormalizeSAN("Dog.EXAMPLE.com"))
+	assert.Equal(t, "*.example.com", pki.NormalizeSAN("*.Example.com"))
+	assert.Equal(t, "2001:db8::1", pki.NormalizeSAN("2001:DB8:0::1"))
+	assert.Equal(t, "Dog@cat.com", pki.NormalizeSAN("Dog@cat.com"))
+	assert.Equal(t, "spiffe://cat.com/Dog", pki.NormalizeSAN("spiffe://cat.com/Dog"))
+}
+
+func TestKeyAlgorithm(t *testing.T) {
+	assert.Equal(t, "ECDSA-P256", pki.KeyAlgorithm(mockX509Cert))
+}
@@ -0,0 +1,84 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseCertificates decodes `body` as a bundle of one or more X.509
+// certificates. `body` can be a concatenation of PEM blocks, or a single
+// certificate in any encoding ParseCertificate accepts.
+func ParseCertificates(body string) ([]*x509.Certificate, error) {
+	rest := []byte(strings.TrimSpace(body))
+	if block, _ := pem.Decode(rest); block == nil {
+		cert, err := ParseCertificate(body)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+	var certs []*x509.Certificate
+	for len(bytes.TrimSpace(rest)) > 0 {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return nil, errors.New("trailing data after PEM blocks")
+		}
+		if block.Type != pemTypeCertificate {
+			return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse x509 certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// EncodeCertificates returns `certs` as a bundle of PEM blocks, in order.
+func EncodeCertificates(certs []*x509.Certificate) string {
+	var bundle strings.Builder
+	for _, cert := range certs {
+		bundle.WriteString(EncodeCertificate(cert))
+	}
+	return bundle.String()
+}
+
+// KeyID returns the hex encoding of a subject or authority key identifier,
+// the way issuers are linked to the certificates they signed.
+func KeyID(id []byte) string {
+	return hex.EncodeToString(id)
+}
+
+// IsSelfSigned returns whether `cert` is signed by its own key, like root
+// certificates are.
+func IsSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// BuildChain returns the path from `leaf` to one of `roots`, which are
+// trusted, through `intermediates`. The path starts with `leaf` and ends with
+// the root. It errors out if there is no such path that is valid now.
+func BuildChain(leaf *x509.Certificate, roots, intermediates []*x509.Certificate) ([]*x509.Certificate, error) {
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, root := range roots {
+		opts.Roots.AddCert(root)
+	}
+	for _, intermediate := range intermediates {
+		opts.Intermediates.AddCert(intermediate)
+	}
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify chain: %w", err)
+	}
+	return chains[0], nil
+}
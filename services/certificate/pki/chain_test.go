@@ -0,0 +1,90 @@
+package pki_test
+
+import (
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// mockChain returns a new root, intermediate and leaf certificate, each
+// signed by the previous one, along with the key of the leaf.
+func mockChain() (root, intermediate, leaf *x509.Certificate, leafKey *ecdsa.PrivateKey) {
+	rootKey, intermediateKey, leafKey := pkitest.NewKey(), pkitest.NewKey(), pkitest.NewKey()
+	root = pkitest.NewCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Mock Root CA"},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, rootKey.Public(), nil, rootKey)
+	intermediate = pkitest.NewCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Mock Intermediate CA"},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, intermediateKey.Public(), root, rootKey)
+	leaf = pkitest.NewCert(&x509.Certificate{
+		Subject:  pkix.Name{CommonName: "dog.example.com"},
+		DNSNames: []string{"dog.example.com"},
+	}, leafKey.Public(), intermediate, intermediateKey)
+	return root, intermediate, leaf, leafKey
+}
+
+func TestParseCertificates(t *testing.T) {
+	root, intermediate, leaf, _ := mockChain()
+
+	t.Run("happy_path_bundle", func(t *testing.T) {
+		certs, err := pki.ParseCertificates(pki.EncodeCertificates([]*x509.Certificate{leaf, intermediate, root}))
+		assert.Nil(t, err)
+		assert.Equal(t, []*x509.Certificate{leaf, intermediate, root}, certs)
+	})
+	t.Run("happy_path_single", func(t *testing.T) {
+		certs, err := pki.ParseCertificates(pki.EncodeCertificate(leaf))
+		assert.Nil(t, err)
+		assert.Equal(t, []*x509.Certificate{leaf}, certs)
+	})
+	t.Run("error_wrong_pem_type", func(t *testing.T) {
+		certs, err := pki.ParseCertificates(pki.EncodeCertificate(leaf) + pkitest.EncodeKey(pkitest.NewKey()))
+		assert.NotNil(t, err)
+		assert.Nil(t, certs)
+	})
+	t.Run("error_trailing_data", func(t *testing.T) {
+		certs, err := pki.ParseCertificates(pki.EncodeCertificate(leaf) + "cert")
+		assert.NotNil(t, err)
+		assert.Nil(t, certs)
+	})
+}
+
+func TestIsSelfSigned(t *testing.T) {
+	root, intermediate, _, _ := mockChain()
+	assert.True(t, pki.IsSelfSigned(root))
+	assert.False(t, pki.IsSelfSigned(intermediate))
+}
+
+func TestBuildChain(t *testing.T) {
+	root, intermediate, leaf, _ := mockChain()
+
+	t.Run("happy_path", func(t *testing.T) {
+		chain, err := pki.BuildChain(leaf, []*x509.Certificate{root}, []*x509.Certificate{intermediate})
+		assert.Nil(t, err)
+		assert.Equal(t, []*x509.Certificate{leaf, intermediate, root}, chain)
+	})
+	t.Run("error_missing_intermediate", func(t *testing.T) {
+		chain, err := pki.BuildChain(leaf, []*x509.Certificate{root}, nil)
+		assert.NotNil(t, err)
+		assert.Nil(t, chain)
+	})
+	t.Run("error_missing_root", func(t *testing.T) {
+		chain, err := pki.BuildChain(leaf, nil, []*x509.Certificate{intermediate})
+		assert.NotNil(t, err)
+		assert.Nil(t, chain)
+	})
+	t.Run("error_untrusted_root", func(t *testing.T) {
+		chain, err := pki.BuildChain(leaf, nil, []*x509.Certificate{intermediate, root})
+		assert.NotNil(t, err)
+		assert.Nil(t, chain)
+	})
+}
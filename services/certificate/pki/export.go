@@ -0,0 +1,111 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatPEM    = "pem"
+	FormatDER    = "der"
+	FormatPKCS12 = "p12"
+	FormatJKS    = "jks"
+)
+
+// ExportContentTypes are the media types of the export formats.
+var ExportContentTypes = map[string]string{
+	FormatPEM:    "application/x-pem-file",
+	FormatDER:    "application/pkix-cert",
+	FormatPKCS12: "application/x-pkcs12",
+	FormatJKS:    "application/x-java-keystore",
+}
+
+// Export encodes `chain`, which starts with the exported certificate, along
+// with private key `key` of the certificate if it is not nil in `format`:
+//   - FormatPEM is a bundle of PEM blocks, the key is a PKCS#8 block at the end
+//   - FormatDER is the certificate alone and cannot hold a key
+//   - FormatPKCS12 and FormatJKS are encrypted with `password` and hold the
+//     key and chain under `alias`, or the chain as trusted certificates if
+//     there is no key
+func Export(format string, chain []*x509.Certificate, key crypto.Signer, alias, password string) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("no certificate to export")
+	}
+	switch format {
+	case FormatPEM:
+		bundle := EncodeCertificates(chain)
+		if key != nil {
+			encoded, err := EncodePrivateKey(key)
+			if err != nil {
+				return nil, err
+			}
+			bundle += encoded
+		}
+		return []byte(bundle), nil
+	case FormatDER:
+		if key != nil {
+			return nil, errors.New("DER cannot hold a private key")
+		}
+		return chain[0].Raw, nil
+	case FormatPKCS12:
+		if password == "" {
+			return nil, errors.New("PKCS#12 requires a password")
+		}
+		if key == nil {
+			return pkcs12.Modern.EncodeTrustStore(chain, password)
+		}
+		return pkcs12.Modern.Encode(key, chain[0], chain[1:], password)
+	case FormatJKS:
+		if password == "" {
+			return nil, errors.New("JKS requires a password")
+		}
+		return exportJKS(chain, key, alias, password)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// exportJKS encodes `chain` and `key` as a Java KeyStore, see Export.
+func exportJKS(chain []*x509.Certificate, key crypto.Signer, alias, password string) ([]byte, error) {
+	ks := keystore.New(keystore.WithOrderedAliases())
+	now := time.Now()
+	if key != nil {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal private key: %w", err)
+		}
+		entry := keystore.PrivateKeyEntry{CreationTime: now, PrivateKey: der}
+		for _, cert := range chain {
+			entry.CertificateChain = append(entry.CertificateChain,
+				keystore.Certificate{Type: "X509", Content: cert.Raw})
+		}
+		if err := ks.SetPrivateKeyEntry(alias, entry, []byte(password)); err != nil {
+			return nil, fmt.Errorf("failed to set private key entry: %w", err)
+		}
+	} else {
+		for i, cert := range chain {
+			entryAlias := alias
+			if i > 0 {
+				entryAlias = fmt.Sprintf("%s-%d", alias, i)
+			}
+			if err := ks.SetTrustedCertificateEntry(entryAlias, keystore.TrustedCertificateEntry{
+				CreationTime: now,
+				Certificate:  keystore.Certificate{Type: "X509", Content: cert.Raw},
+			}); err != nil {
+				return nil, fmt.Errorf("failed to set trusted certificate entry: %w", err)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := ks.Store(&buf, []byte(password)); err != nil {
+		return nil, fmt.Errorf("failed to store keystore: %w", err)
+	}
+	return buf.Bytes(), nil
+}
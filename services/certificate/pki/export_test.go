@@ -0,0 +1,83 @@
+package pki_test
+
+import (
+	"bytes"
+	"certificate/pki"
+	"crypto/x509"
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	root, intermediate, leaf, key := mockChain()
+	chain := []*x509.Certificate{leaf, intermediate, root}
+
+	t.Run("happy_path_pem", func(t *testing.T) {
+		exported, err := pki.Export(pki.FormatPEM, chain, key, "mock_alias", "")
+		assert.Nil(t, err)
+		encodedKey, err := pki.EncodePrivateKey(key)
+		assert.Nil(t, err)
+		assert.Equal(t, pki.EncodeCertificates(chain)+encodedKey, string(exported))
+	})
+	t.Run("happy_path_der", func(t *testing.T) {
+		exported, err := pki.Export(pki.FormatDER, chain, nil, "mock_alias", "")
+		assert.Nil(t, err)
+		assert.Equal(t, leaf.Raw, exported)
+	})
+	t.Run("happy_path_p12", func(t *testing.T) {
+		exported, err := pki.Export(pki.FormatPKCS12, chain, key, "mock_alias", "mock_password")
+		assert.Nil(t, err)
+		decodedKey, decodedCert, caCerts, err := pkcs12.DecodeChain(exported, "mock_password")
+		assert.Nil(t, err)
+		assert.Equal(t, key, decodedKey)
+		assert.Equal(t, leaf, decodedCert)
+		assert.Equal(t, []*x509.Certificate{intermediate, root}, caCerts)
+	})
+	t.Run("happy_path_p12_without_key", func(t *testing.T) {
+		exported, err := pki.Export(pki.FormatPKCS12, chain, nil, "mock_alias", "mock_password")
+		assert.Nil(t, err)
+		certs, err := pkcs12.DecodeTrustStore(exported, "mock_password")
+		assert.Nil(t, err)
+		assert.Equal(t, chain, certs)
+	})
+	t.Run("happy_path_jks", func(t *testing.T) {
+		exported, err := pki.Export(pki.FormatJKS, chain, key, "mock_alias", "mock_password")
+		assert.Nil(t, err)
+		ks := keystore.New()
+		assert.Nil(t, ks.Load(bytes.NewReader(exported), []byte("mock_password")))
+		entry, err := ks.GetPrivateKeyEntry("mock_alias", []byte("mock_password"))
+		assert.Nil(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		assert.Nil(t, err)
+		assert.Equal(t, der, entry.PrivateKey)
+		assert.Len(t, entry.CertificateChain, 3)
+		assert.Equal(t, leaf.Raw, entry.CertificateChain[0].Content)
+	})
+	t.Run("happy_path_jks_without_key", func(t *testing.T) {
+		exported, err := pki.Export(pki.FormatJKS, chain, nil, "mock_alias", "mock_password")
+		assert.Nil(t, err)
+		ks := keystore.New()
+		assert.Nil(t, ks.Load(bytes.NewReader(exported), []byte("mock_password")))
+		assert.ElementsMatch(t, []string{"mock_alias", "mock_alias-1", "mock_alias-2"}, ks.Aliases())
+		assert.True(t, ks.IsTrustedCertificateEntry("mock_alias"))
+	})
+	t.Run("error_der_with_key", func(t *testing.T) {
+		exported, err := pki.Export(pki.FormatDER, chain, key, "mock_alias", "")
+		assert.NotNil(t, err)
+		assert.Nil(t, exported)
+	})
+	t.Run("error_no_password", func(t *testing.T) {
+		for _, format := range []string{pki.FormatPKCS12, pki.FormatJKS} {
+			exported, err := pki.Export(format, chain, key, "mock_alias", "")
+			assert.NotNil(t, err)
+			assert.Nil(t, exported)
+		}
+	})
+	t.Run("error_unknown_format", func(t *testing.T) {
+		exported, err := pki.Export("pfx", chain, key, "mock_alias", "mock_password")
+		assert.NotNil(t, err)
+		assert.Nil(t, exported)
+	})
+}
@@ -0,0 +1,83 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Key types supported by GenerateKey, named the same way KeyAlgorithm
+// describes them.
+const (
+	KeyTypeRSA2048   = "RSA-2048"
+	KeyTypeRSA4096   = "RSA-4096"
+	KeyTypeECDSAP256 = "ECDSA-P256"
+	KeyTypeECDSAP384 = "ECDSA-P384"
+	KeyTypeEd25519   = "Ed25519"
+)
+
+// CheckKeyType returns an error unless GenerateKey supports `keyType`.
+func CheckKeyType(keyType string) error {
+	switch keyType {
+	case KeyTypeRSA2048, KeyTypeRSA4096, KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeEd25519:
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// GenerateKey returns a new private key of type `keyType`.
+func GenerateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// CreateCSR returns a PEM encoded PKCS#10 CSR for `subject` and `sans` signed
+// by `key`. Each SAN is added as an IP address, URI, email address or DNS
+// name depending on what it looks like.
+func CreateCSR(key crypto.Signer, subject pkix.Name, sans []string) (string, error) {
+	template := &x509.CertificateRequest{Subject: subject}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if strings.Contains(san, "://") {
+			uri, err := url.Parse(san)
+			if err != nil {
+				return "", fmt.Errorf("invalid URI SAN %q: %w", san, err)
+			}
+			template.URIs = append(template.URIs, uri)
+		} else if strings.Contains(san, "@") {
+			template.EmailAddresses = append(template.EmailAddresses, san)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create CSR: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
@@ -0,0 +1,66 @@
+package pki_test
+
+import (
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	for _, keyType := range []string{
+		pki.KeyTypeRSA2048,
+		pki.KeyTypeECDSAP256,
+		pki.KeyTypeECDSAP384,
+		pki.KeyTypeEd25519,
+	} {
+		t.Run("happy_path_"+keyType, func(t *testing.T) {
+			key, err := pki.GenerateKey(keyType)
+			assert.Nil(t, err)
+			cert := pkitest.NewCert(&x509.Certificate{}, key.Public(), nil, key)
+			assert.Equal(t, keyType, pki.KeyAlgorithm(cert))
+		})
+	}
+	t.Run("error_unsupported_key_type", func(t *testing.T) {
+		key, err := pki.GenerateKey("DSA-1024")
+		assert.NotNil(t, err)
+		assert.Nil(t, key)
+	})
+}
+
+func TestCheckKeyType(t *testing.T) {
+	assert.Nil(t, pki.CheckKeyType(pki.KeyTypeRSA4096))
+	assert.NotNil(t, pki.CheckKeyType("RSA-1024"))
+	assert.NotNil(t, pki.CheckKeyType(""))
+}
+
+func TestCreateCSR(t *testing.T) {
+	key := pkitest.NewKey()
+
+	t.Run("happy_path", func(t *testing.T) {
+		encoded, err := pki.CreateCSR(key, pkix.Name{CommonName: "dog"},
+			[]string{"dog.example.com", "10.0.0.1", "dog@cat.com", "spiffe://cat.com/dog"})
+		assert.Nil(t, err)
+
+		block, _ := pem.Decode([]byte(encoded))
+		assert.Equal(t, "CERTIFICATE REQUEST", block.Type)
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		assert.Nil(t, err)
+		assert.Nil(t, csr.CheckSignature())
+		assert.Equal(t, "CN=dog", csr.Subject.String())
+		assert.Equal(t, []string{"dog.example.com"}, csr.DNSNames)
+		assert.True(t, net.ParseIP("10.0.0.1").Equal(csr.IPAddresses[0]))
+		assert.Equal(t, []string{"dog@cat.com"}, csr.EmailAddresses)
+		assert.Equal(t, "spiffe://cat.com/dog", csr.URIs[0].String())
+		assert.True(t, key.PublicKey.Equal(csr.PublicKey))
+	})
+	t.Run("error_invalid_uri", func(t *testing.T) {
+		encoded, err := pki.CreateCSR(key, pkix.Name{}, []string{"spiffe://cat com/%zz"})
+		assert.NotNil(t, err)
+		assert.Empty(t, encoded)
+	})
+}
@@ -0,0 +1,98 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	pemTypePKCS1 = "RSA PRIVATE KEY"
+	pemTypePKCS8 = "PRIVATE KEY"
+	pemTypeSEC1  = "EC PRIVATE KEY"
+)
+
+// ParsePrivateKey decodes `key` as a PKCS#1 RSA, PKCS#8 (RSA, ECDSA or
+// Ed25519) or SEC1 EC private key. `key` can be PEM encoded, base64 encoded
+// DER, or raw DER.
+func ParsePrivateKey(key string) (crypto.Signer, error) {
+	trimmed := strings.TrimSpace(key)
+	if trimmed == "" {
+		return nil, errors.New("private key is empty")
+	}
+
+	// PEM block types tell us which encoding to use
+	if block, _ := pem.Decode([]byte(trimmed)); block != nil {
+		switch block.Type {
+		case pemTypePKCS1:
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case pemTypeSEC1:
+			return x509.ParseECPrivateKey(block.Bytes)
+		case pemTypePKCS8:
+			return parsePKCS8PrivateKey(block.Bytes)
+		default:
+			return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+	}
+
+	// otherwise try every encoding in turn
+	der, err := base64.StdEncoding.DecodeString(trimmed)
+	if err != nil {
+		der = []byte(key)
+	}
+	if signer, err := parsePKCS8PrivateKey(der); err == nil {
+		return signer, nil
+	}
+	if signer, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return signer, nil
+	}
+	if signer, err := x509.ParseECPrivateKey(der); err == nil {
+		return signer, nil
+	}
+	return nil, errors.New("failed to parse private key as PKCS#8, PKCS#1 or SEC1")
+}
+
+// parsePKCS8PrivateKey parses a DER encoded PKCS#8 private key that can be
+// used for signing.
+func parsePKCS8PrivateKey(der []byte) (crypto.Signer, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// publicKey is implemented by all public key types in the standard library.
+type publicKey interface {
+	Equal(x crypto.PublicKey) bool
+}
+
+// CheckKeyPair returns an error if `key` is not the private key of the public
+// key in `cert`.
+func CheckKeyPair(cert *x509.Certificate, key crypto.Signer) error {
+	pub, ok := key.Public().(publicKey)
+	if !ok {
+		return fmt.Errorf("unsupported public key type %T", key.Public())
+	}
+	if !pub.Equal(cert.PublicKey) {
+		return errors.New("private key does not match the certificate's public key")
+	}
+	return nil
+}
+
+// EncodePrivateKey returns `key` as a PEM encoded PKCS#8 private key.
+func EncodePrivateKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: pemTypePKCS8, Bytes: der})), nil
+}
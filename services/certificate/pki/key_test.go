@@ -0,0 +1,109 @@
+package pki_test
+
+import (
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	assert.Nil(t, err)
+
+	for name, test := range map[string]struct {
+		key      string
+		expected crypto.Signer
+	}{
+		"happy_path_pkcs1": {
+			key:      string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})),
+			expected: rsaKey,
+		},
+		"happy_path_sec1": {
+			key:      string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})),
+			expected: ecKey,
+		},
+		"happy_path_pkcs8_rsa": {
+			key:      pkitest.EncodeKey(rsaKey),
+			expected: rsaKey,
+		},
+		"happy_path_pkcs8_ecdsa": {
+			key:      pkitest.EncodeKey(ecKey),
+			expected: ecKey,
+		},
+		"happy_path_pkcs8_ed25519": {
+			key:      pkitest.EncodeKey(edKey),
+			expected: edKey,
+		},
+		"happy_path_base64_der_sec1": {
+			key:      base64.StdEncoding.EncodeToString(sec1),
+			expected: ecKey,
+		},
+		"happy_path_der_pkcs1": {
+			key:      string(x509.MarshalPKCS1PrivateKey(rsaKey)),
+			expected: rsaKey,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			key, err := pki.ParsePrivateKey(test.key)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, key)
+		})
+	}
+
+	t.Run("error_empty", func(t *testing.T) {
+		key, err := pki.ParsePrivateKey("")
+		assert.NotNil(t, err)
+		assert.Nil(t, key)
+	})
+	t.Run("error_malformed", func(t *testing.T) {
+		key, err := pki.ParsePrivateKey("private_key")
+		assert.NotNil(t, err)
+		assert.Nil(t, key)
+	})
+	t.Run("error_wrong_pem_type", func(t *testing.T) {
+		key, err := pki.ParsePrivateKey(mockCertPEM)
+		assert.NotNil(t, err)
+		assert.Nil(t, key)
+	})
+}
+
+func TestCheckKeyPair(t *testing.T) {
+	key := pkitest.NewKey()
+	cert := pkitest.NewCert(&x509.Certificate{Subject: pkix.Name{CommonName: "dog"}}, key.Public(), nil, key)
+
+	t.Run("happy_path", func(t *testing.T) {
+		assert.Nil(t, pki.CheckKeyPair(cert, key))
+	})
+	t.Run("error_mismatch", func(t *testing.T) {
+		assert.NotNil(t, pki.CheckKeyPair(cert, pkitest.NewKey()))
+	})
+	t.Run("error_different_algorithm", func(t *testing.T) {
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		assert.Nil(t, err)
+		assert.NotNil(t, pki.CheckKeyPair(cert, edKey))
+	})
+}
+
+func TestEncodePrivateKey(t *testing.T) {
+	key := pkitest.NewKey()
+	encoded, err := pki.EncodePrivateKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, pkitest.EncodeKey(key), encoded)
+}
@@ -0,0 +1,74 @@
+// Package pkitest provides certificate and key fixtures for tests. Its
+// functions panic on failure so they can be used to initialize package level
+// test variables.
+package pkitest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"time"
+)
+
+// NewKey returns a new ECDSA P-256 private key.
+func NewKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// EncodeKey returns `key` as a PEM encoded PKCS#8 private key.
+func EncodeKey(key crypto.Signer) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// NewCert signs `template` for `pub` with `parentKey`, using `parent` as the
+// issuer. If `parent` is nil the certificate is self-signed. Serial number and
+// validity get defaults if left empty in `template`.
+func NewCert(template *x509.Certificate, pub crypto.PublicKey,
+	parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	if template.SerialNumber == nil {
+		template.SerialNumber = big.NewInt(1)
+	}
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = template.NotBefore.Add(365 * 24 * time.Hour)
+	}
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	return cert
+}
+
+// EncodeCert returns `cert` as a PEM encoded certificate.
+func EncodeCert(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+// SelfSigned returns a self-signed certificate for `template` along with the
+// PEM encodings of the certificate and its newly generated private key.
+func SelfSigned(template *x509.Certificate) (cert *x509.Certificate, certPEM, keyPEM string) {
+	key := NewKey()
+	cert = NewCert(template, key.Public(), nil, key)
+	return cert, EncodeCert(cert), EncodeKey(key)
+}
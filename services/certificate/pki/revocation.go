@@ -0,0 +1,31 @@
+package pki
+
+import (
+	"fmt"
+)
+
+// RFC 5280 CRL reason codes. certificateHold (6) and removeFromCRL (8) are
+// left out because revocations are final.
+const (
+	ReasonUnspecified          = 0
+	ReasonKeyCompromise        = 1
+	ReasonCACompromise         = 2
+	ReasonAffiliationChanged   = 3
+	ReasonSuperseded           = 4
+	ReasonCessationOfOperation = 5
+	ReasonPrivilegeWithdrawn   = 9
+	ReasonAACompromise         = 10
+)
+
+// CheckRevocationReason returns an error if `reason` is not one of the
+// supported reason codes.
+func CheckRevocationReason(reason int) error {
+	switch reason {
+	case ReasonUnspecified, ReasonKeyCompromise, ReasonCACompromise,
+		ReasonAffiliationChanged, ReasonSuperseded, ReasonCessationOfOperation,
+		ReasonPrivilegeWithdrawn, ReasonAACompromise:
+		return nil
+	default:
+		return fmt.Errorf("unsupported reason code %d", reason)
+	}
+}
@@ -0,0 +1,20 @@
+package pki_test
+
+import (
+	"certificate/pki"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckRevocationReason(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		for _, reason := range []int{0, 1, 2, 3, 4, 5, 9, 10} {
+			assert.Nil(t, pki.CheckRevocationReason(reason))
+		}
+	})
+	t.Run("error_unsupported", func(t *testing.T) {
+		for _, reason := range []int{-1, 6, 7, 8, 11} {
+			assert.NotNil(t, pki.CheckRevocationReason(reason))
+		}
+	})
+}
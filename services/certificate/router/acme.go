@@ -0,0 +1,106 @@
+package router
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const (
+	certACMEPath          = "/cert/acme"
+	acmeChallengePath     = "/.well-known/acme-challenge/:token"
+	acmeChallengeTokenKey = "token"
+)
+
+func (r *Router) routeACME() {
+	r.POST(certACMEPath, r.obtainCert)
+	r.GET(acmeChallengePath, r.getACMEChallenge)
+}
+
+// obtainCert generates a private key, obtains a certificate for it from the
+// configured ACME directory, and adds both as a certificate to an existing
+// user and optionally a team.
+func (r *Router) obtainCert(c echo.Context) error {
+	if r.acme == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			errors.New("ACME is not configured"))
+	}
+
+	// decode the request body into `req`
+	req := &struct {
+		UserUUID string   `json:"user_uuid"`
+		TeamUUID string   `json:"team_uuid"`
+		KeyType  string   `json:"key_type"`
+		SANs     []string `json:"sans"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+	if err := r.db.CheckCertOwners(req.UserUUID, req.TeamUUID, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to check cert owners: %w", err))
+	}
+
+	// generate the key pair and let the ACME server sign it
+	key, err := pki.GenerateKey(req.KeyType)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("failed to generate key: %w", err))
+	}
+	chain, err := r.acme.Obtain(c.Request().Context(), key, req.SANs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to obtain cert: %w", err))
+	}
+	if len(chain) == 0 {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			errors.New("failed to obtain cert: ACME directory returned no certificate"))
+	}
+	privateKey, err := pki.EncodePrivateKey(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to encode private key: %w", err))
+	}
+
+	// add cert to database along with the intermediates the directory sent
+	// and let it fill db-generated fields
+	cert := &db.Cert{
+		UserUUID:   req.UserUUID,
+		TeamUUID:   req.TeamUUID,
+		PrivateKey: privateKey,
+		Body:       pki.EncodeCertificate(chain[0]),
+	}
+	for _, issuer := range chain[1:] {
+		cert.Chain = append(cert.Chain, pki.EncodeCertificate(issuer))
+	}
+	if err := r.db.AddCert(cert, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to add cert: %w", err))
+	}
+
+	// send message to notifier
+	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active, cert.Labels); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to send cert toggled message: %w", err))
+	}
+
+	// wipe private key and write to response with generated fields
+	cert.PrivateKey = ""
+	return c.JSON(http.StatusOK, cert)
+}
+
+// getACMEChallenge serves the response to a pending HTTP-01 challenge.
+func (r *Router) getACMEChallenge(c echo.Context) error {
+	if r.acmeChallenges == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	response, ok := r.acmeChallenges.Response(c.Param(acmeChallengeTokenKey))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	return c.String(http.StatusOK, response)
+}
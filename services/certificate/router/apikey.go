@@ -0,0 +1,72 @@
+package router
+
+import (
+	"certificate/db"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+const (
+	userAPIKeysPath = "/user/:uuid/api-keys"
+	userAPIKeyPath  = "/user/:uuid/api-keys/:key_uuid"
+)
+
+func (r *Router) routeAPIKey() {
+	r.POST(userAPIKeysPath, r.addAPIKey)
+	r.GET(userAPIKeysPath, r.getAPIKeys)
+	r.DELETE(userAPIKeyPath, r.revokeAPIKey)
+}
+
+// addAPIKey creates an API key for an existing user, the key is only returned
+// in this response.
+func (r *Router) addAPIKey(c echo.Context) error {
+	// decode the request path and body into `req`
+	req := &struct {
+		UserUUID  string    `param:"uuid"`
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// add the key and let the database generate it
+	key := &db.APIKey{
+		UserUUID:  req.UserUUID,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := r.db.AddAPIKey(key, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to add API key: %w", err))
+	}
+
+	// write the key to response
+	return c.JSON(http.StatusOK, key)
+}
+
+// getAPIKeys returns the API keys of an existing user without their secrets.
+func (r *Router) getAPIKeys(c echo.Context) error {
+	keys, err := r.db.GetAPIKeys(c.Param("uuid"), principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get API keys: %w", err))
+	}
+
+	// write `keys` to response
+	return c.JSON(http.StatusOK, keys)
+}
+
+// revokeAPIKey revokes an API key of an existing user.
+func (r *Router) revokeAPIKey(c echo.Context) error {
+	if err := r.db.RevokeAPIKey(c.Param("key_uuid"), c.Param("uuid"), principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to revoke API key: %w", err))
+	}
+	return c.String(http.StatusOK, "success!")
+}
@@ -0,0 +1,183 @@
+package router
+
+import (
+	"certificate/auth"
+	"certificate/db"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const authLoginPath = "/auth/login"
+
+// principalKey is the key of the *db.Principal of the authenticated user in
+// the echo context.
+const principalKey = "principal"
+
+func (r *Router) routeAuth() {
+	r.POST(authLoginPath, r.login)
+}
+
+// login checks the email and password of an active user, and returns a
+// session token to authenticate its requests with.
+func (r *Router) login(c echo.Context) error {
+	if r.tokens == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			errors.New("authentication is not configured"))
+	}
+
+	// decode the request body into `req`
+	req := &struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// check the credentials against the stored password hash
+	user, err := r.db.AuthenticateUser(req.Email, req.Password)
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to authenticate user: %w", err))
+	}
+
+	// issue a token for the user
+	token, expiresAt, err := r.tokens.Issue(user.UUID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to issue token: %w", err))
+	}
+
+	// write the token to response
+	return c.JSON(http.StatusOK, struct {
+		Token     string    `json:"token"`
+		UserUUID  string    `json:"user_uuid"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{
+		Token:     token,
+		UserUUID:  user.UUID,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// authenticate is a middleware that rejects requests to /cert, /user, /org,
+// /team, /ca and /chain routes without a valid session token or API key of an
+// active user in the `Authorization: Bearer` header, or with an API key
+// without the scope of the route, and stores the principal of the
+// authenticated user in the context otherwise. Signing up with `POST /user`
+// and fetching CRLs do not need a token.
+func (r *Router) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !requiresAuth(c) {
+			return next(c)
+		}
+
+		header := c.Request().Header.Get(echo.HeaderAuthorization)
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized,
+				errors.New("missing bearer token"))
+		}
+		principal, err := r.authenticateToken(token)
+		if err != nil {
+			return err
+		}
+		if scope := requiredScope(c); !principal.HasScope(scope) {
+			return echo.NewHTTPError(http.StatusForbidden,
+				fmt.Errorf("API key does not have scope %s", scope))
+		}
+		c.Set(principalKey, principal)
+		return next(c)
+	}
+}
+
+// authenticateToken returns the principal of bearer token `token`, which is
+// either an API key or a session token.
+func (r *Router) authenticateToken(token string) (*db.Principal, error) {
+	if auth.IsAPIKey(token) {
+		principal, err := r.db.AuthenticateAPIKey(token)
+		if err != nil {
+			return nil, echo.NewHTTPError(httpStatus(err),
+				fmt.Errorf("failed to authenticate API key: %w", err))
+		}
+		return principal, nil
+	}
+
+	if r.tokens == nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			errors.New("authentication is not configured"))
+	}
+	userUUID, err := r.tokens.Verify(token)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized,
+			fmt.Errorf("failed to verify token: %w", err))
+	}
+	principal, err := r.db.GetPrincipal(userUUID)
+	if err != nil {
+		return nil, echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get principal: %w", err))
+	}
+	return principal, nil
+}
+
+// requiresAuth returns whether the route of `c` needs an authenticated user.
+func requiresAuth(c echo.Context) bool {
+	path := c.Path()
+	if path == userPath && c.Request().Method == http.MethodPost {
+		return false
+	}
+	if path == caCRLPath {
+		return false
+	}
+	for _, prefix := range []string{certPath, userPath, orgPath, teamPath, caPath, chainPath} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredScope returns the API key scope the route of `c` needs, reading
+// for GET requests and writing otherwise. CA and chain routes fall under the
+// certificate scopes.
+func requiredScope(c echo.Context) string {
+	read := c.Request().Method == http.MethodGet
+	cert := strings.HasPrefix(c.Path(), certPath) || strings.HasPrefix(c.Path(), caPath) ||
+		strings.HasPrefix(c.Path(), chainPath)
+	org := strings.HasPrefix(c.Path(), orgPath) || strings.HasPrefix(c.Path(), teamPath)
+	switch {
+	case cert && read:
+		return db.ScopeCertRead
+	case cert:
+		return db.ScopeCertWrite
+	case org && read:
+		return db.ScopeOrgRead
+	case org:
+		return db.ScopeOrgWrite
+	case read:
+		return db.ScopeUserRead
+	default:
+		return db.ScopeUserWrite
+	}
+}
+
+// requireAdmin returns an error unless the request was authenticated by an
+// admin.
+func requireAdmin(c echo.Context) error {
+	if p := principal(c); p == nil || !p.Admin {
+		return echo.NewHTTPError(http.StatusForbidden,
+			fmt.Errorf("admin required: %w", db.ErrForbidden))
+	}
+	return nil
+}
+
+// principal returns the principal of the user that authenticated the request.
+func principal(c echo.Context) *db.Principal {
+	principal, _ := c.Get(principalKey).(*db.Principal)
+	return principal
+}
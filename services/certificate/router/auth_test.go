@@ -0,0 +1,186 @@
+package router_test
+
+import (
+	"certificate/auth"
+	"certificate/db"
+	"certificate/router"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// MockDatabase returns the principals in `Principals` by user UUID,
+// authenticates the API keys in `APIKeys` and fails to add users, any other
+// operation panics.
+type MockDatabase struct {
+	db.Database
+	Principals map[string]*db.Principal
+	APIKeys    map[string]*db.Principal
+}
+
+func (m *MockDatabase) GetPrincipal(userUUID string) (*db.Principal, error) {
+	principal, ok := m.Principals[userUUID]
+	if !ok {
+		return nil, fmt.Errorf("unknown user: %w", db.ErrUnauthorized)
+	}
+	return principal, nil
+}
+
+func (m *MockDatabase) AuthenticateAPIKey(key string) (*db.Principal, error) {
+	principal, ok := m.APIKeys[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown API key: %w", db.ErrUnauthorized)
+	}
+	return principal, nil
+}
+
+func (m *MockDatabase) AddUser(user *db.User) error {
+	return errors.New("mock_error")
+}
+
+const (
+	mockUserUUID     = "mock_user_uuid"
+	mockAdminUUID    = "mock_admin_uuid"
+	mockCertReadKey  = "cert_mock_read"
+	mockCertWriteKey = "cert_mock_write"
+	mockUserReadKey  = "cert_mock_user_read"
+)
+
+// newRouter returns a router that verifies session tokens with `tokens`, for
+// which mockUserUUID is a user and mockAdminUUID an admin, and API keys of
+// mockUserUUID with a single scope each.
+func newRouter(t *testing.T) (*router.Router, *auth.Tokens) {
+	path := filepath.Join(t.TempDir(), "auth.key")
+	assert.Nil(t, auth.GenerateKeyFile(path))
+	tokens, err := auth.LoadTokens(path)
+	assert.Nil(t, err)
+	return router.New().WithDatabase(&MockDatabase{Principals: map[string]*db.Principal{
+		mockUserUUID:  {UUID: mockUserUUID},
+		mockAdminUUID: {UUID: mockAdminUUID, Admin: true},
+	}, APIKeys: map[string]*db.Principal{
+		mockCertReadKey:  {UUID: mockUserUUID, Scopes: []string{db.ScopeCertRead}},
+		mockCertWriteKey: {UUID: mockUserUUID, Scopes: []string{db.ScopeCertWrite}},
+		mockUserReadKey:  {UUID: mockUserUUID, Scopes: []string{db.ScopeUserRead}},
+	}}).WithTokens(tokens), tokens
+}
+
+// mockToken issues a session token for `userUUID`.
+func mockToken(t *testing.T, tokens *auth.Tokens, userUUID string) string {
+	token, _, err := tokens.Issue(userUUID)
+	assert.Nil(t, err)
+	return token
+}
+
+// serve sends a `method` request to `path` with bearer token `token` if it
+// is not empty, and returns the response status.
+func serve(r *router.Router, method, path, token, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRouter_Authenticate(t *testing.T) {
+	r, tokens := newRouter(t)
+	params := regexp.MustCompile(`:[a-z_]+|\*`)
+	protected := []string{"/cert", "/user", "/org", "/team", "/ca", "/chain"}
+	exempt := map[string]bool{"POST /user": true, "GET /ca/:uuid/crl": true}
+	public := map[string]bool{
+		"POST /auth/login":                       true,
+		"POST /ocsp":                             true,
+		"GET /ocsp/*":                            true,
+		"GET /.well-known/acme-challenge/:token": true,
+	}
+
+	// every route is public, exempt, or rejected without valid credentials
+	for _, route := range r.Routes() {
+		route := route
+		name := route.Method + " " + route.Path
+		path := params.ReplaceAllString(route.Path, "mock")
+		t.Run(name, func(t *testing.T) {
+			switch {
+			case public[name]:
+				for _, prefix := range protected {
+					assert.False(t, strings.HasPrefix(route.Path, prefix))
+				}
+			case exempt[name]:
+				assert.NotEqual(t, http.StatusUnauthorized, serve(r, route.Method, path, "", ""))
+			default:
+				assert.Equal(t, http.StatusUnauthorized, serve(r, route.Method, path, "", ""))
+				assert.Equal(t, http.StatusUnauthorized, serve(r, route.Method, path, "mock_invalid_token", ""))
+				assert.Equal(t, http.StatusUnauthorized, serve(r, route.Method, path, "cert_unknown", ""))
+			}
+		})
+	}
+	t.Run("happy_path_protected_prefixes", func(t *testing.T) {
+		for _, route := range r.Routes() {
+			name := route.Method + " " + route.Path
+			if public[name] {
+				continue
+			}
+			covered := false
+			for _, prefix := range protected {
+				covered = covered || strings.HasPrefix(route.Path, prefix)
+			}
+			assert.True(t, covered, name)
+		}
+	})
+	t.Run("happy_path_valid_token", func(t *testing.T) {
+		token := mockToken(t, tokens, mockAdminUUID)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(r, http.MethodPost, "/chain", token, `{"body": ""}`))
+	})
+	t.Run("error_unknown_user", func(t *testing.T) {
+		token := mockToken(t, tokens, "mock_unknown_user_uuid")
+		assert.Equal(t, http.StatusUnauthorized, serve(r, http.MethodPost, "/chain", token, `{"body": ""}`))
+	})
+}
+
+func TestRouter_RequiredScope(t *testing.T) {
+	r, _ := newRouter(t)
+
+	for name, tc := range map[string]struct {
+		method, path, token string
+	}{
+		"error_cert_write_with_read_key":  {http.MethodPost, "/cert", mockCertReadKey},
+		"error_ca_write_with_read_key":    {http.MethodPost, "/ca", mockCertReadKey},
+		"error_chain_write_with_read_key": {http.MethodPost, "/chain", mockCertReadKey},
+		"error_cert_read_with_user_key":   {http.MethodGet, "/cert/mock/history", mockUserReadKey},
+		"error_user_write_with_user_key":  {http.MethodDelete, "/user", mockUserReadKey},
+		"error_user_read_with_cert_key":   {http.MethodGet, "/user", mockCertWriteKey},
+		"error_org_read_with_cert_key":    {http.MethodGet, "/org/mock/teams", mockCertWriteKey},
+		"error_team_write_with_cert_key":  {http.MethodPost, "/team/mock/members", mockCertWriteKey},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusForbidden, serve(r, tc.method, tc.path, tc.token, ""))
+		})
+	}
+}
+
+func TestRouter_RequireAdmin(t *testing.T) {
+	r, tokens := newRouter(t)
+	token := mockToken(t, tokens, mockUserUUID)
+
+	for name, tc := range map[string]struct {
+		method, path, body string
+	}{
+		"error_ca_write":           {http.MethodPost, "/ca", `{"name": "Mock CA"}`},
+		"error_chain_write":        {http.MethodPost, "/chain", `{"body": ""}`},
+		"error_unrestricted_issue": {http.MethodPost, "/cert/issue", `{"profile": "server"}`},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusForbidden, serve(r, tc.method, tc.path, token, tc.body))
+		})
+	}
+}
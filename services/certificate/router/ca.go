@@ -0,0 +1,126 @@
+package router
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"certificate/pki"
+	"crypto/x509/pkix"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+const caPath = "/ca"
+
+const (
+	defaultRootValidity         = 10 * 365 * 24 * time.Hour
+	defaultIntermediateValidity = 5 * 365 * 24 * time.Hour
+)
+
+func (r *Router) routeCA() {
+	r.POST(caPath, r.addAuthority)
+	r.GET(caPath, r.getAuthorities)
+}
+
+// addAuthority adds a certificate authority. It imports the CA from `body` and
+// `private_key` if given, otherwise it creates a new root CA, or a new
+// intermediate CA signed by `parent_uuid` if given. Only admins may add
+// authorities.
+func (r *Router) addAuthority(c echo.Context) error {
+	if err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	// decode the request body into `req`
+	req := &struct {
+		Name         string      `json:"name"`
+		ParentUUID   string      `json:"parent_uuid"`
+		CommonName   string      `json:"common_name"`
+		Organization string      `json:"organization"`
+		Validity     ca.Duration `json:"validity"`
+		Body         string      `json:"body"`
+		PrivateKey   string      `json:"private_key"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+	authority := &db.Authority{
+		Name:       req.Name,
+		ParentUUID: req.ParentUUID,
+		Body:       req.Body,
+		PrivateKey: req.PrivateKey,
+	}
+
+	// create the CA unless it is imported
+	if authority.Body == "" {
+		subject := pkix.Name{CommonName: req.CommonName}
+		if req.Organization != "" {
+			subject.Organization = []string{req.Organization}
+		}
+		created, err := r.createAuthority(req.ParentUUID, subject, time.Duration(req.Validity))
+		if err != nil {
+			return echo.NewHTTPError(httpStatus(err),
+				fmt.Errorf("failed to create authority: %w", err))
+		}
+		authority.Body = pki.EncodeCertificate(created.Cert)
+		if authority.PrivateKey, err = pki.EncodePrivateKey(created.Key); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError,
+				fmt.Errorf("failed to encode authority key: %w", err))
+		}
+	}
+
+	// add authority to database and let it fill db-generated fields
+	if err := r.db.AddAuthority(authority); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to add authority: %w", err))
+	}
+
+	// wipe private key and write to response with generated fields
+	authority.PrivateKey = ""
+	return c.JSON(http.StatusOK, authority)
+}
+
+// createAuthority creates a new root CA for `subject` if `parentUUID` is
+// empty, otherwise a new intermediate CA signed by authority `parentUUID`.
+func (r *Router) createAuthority(parentUUID string, subject pkix.Name, validity time.Duration) (*ca.CA, error) {
+	if parentUUID == "" {
+		if validity == 0 {
+			validity = defaultRootValidity
+		}
+		return ca.NewRoot(subject, validity)
+	}
+	if validity == 0 {
+		validity = defaultIntermediateValidity
+	}
+	parent, err := r.loadAuthority(parentUUID)
+	if err != nil {
+		return nil, err
+	}
+	return parent.NewIntermediate(subject, validity)
+}
+
+// loadAuthority returns the CA of authority `uuid` ready for signing.
+func (r *Router) loadAuthority(uuid string) (*ca.CA, error) {
+	authority, err := r.db.GetAuthority(uuid)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := ca.Load(authority.Body, authority.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return loaded.WithRevocationURLs(r.baseURL, uuid), nil
+}
+
+// getAuthorities returns all certificate authorities without their private
+// keys.
+func (r *Router) getAuthorities(c echo.Context) error {
+	authorities, err := r.db.GetAuthorities()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to get authorities: %w", err))
+	}
+	return c.JSON(http.StatusOK, authorities)
+}
@@ -1,37 +1,232 @@
 package router
 
 import (
+	"certificate/ca"
 	"certificate/db"
+	"certificate/pki"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
-const certPath = "/cert"
+const (
+	certPath           = "/cert"
+	certsPath          = "/certs"
+	certPrivateKeyPath = "/cert/private-key"
+	certIssuePath      = "/cert/issue"
+	certCSRPath        = "/cert/csr"
+	certLabelsPath     = "/cert/labels"
+	certHistoryPath    = "/cert/:uuid/history"
+	certTransferPath   = "/cert/:uuid/transfer"
+)
+
+// Number of certificates per page of certificate listings.
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// maxUploadSize is the maximum size of a certificate bundle uploaded as a
+// file.
+const maxUploadSize = 1 << 20
 
 func (r *Router) routeCert() {
 	r.POST(certPath, r.addCert)
 	r.GET(certPath, r.getCerts)
+	r.GET(certsPath, r.queryCerts)
 	r.PATCH(certPath, r.setCertActiveStatus)
+	r.PATCH(certLabelsPath, r.setCertLabels)
+	r.GET(certHistoryPath, r.getCertHistory)
+	r.POST(certTransferPath, r.transferCert)
+	r.POST(certPrivateKeyPath, r.exportPrivateKey)
+	r.POST(certIssuePath, r.issueCert)
+	r.POST(certCSRPath, r.createCSR)
+	r.PUT(certPath, r.completeCert)
 }
 
-// addCert adds a certificate that belongs to an existing user.
+// addCert adds a certificate that belongs to an existing user. The
+// certificate is either sent as JSON, or uploaded as a PKCS#12 or PEM bundle
+// file in a multipart form, which is split into the private key, the
+// certificate and its chain. Only certificates issued by the service belong
+// to an internal CA, so uploads may not set `authority_uuid`.
 func (r *Router) addCert(c echo.Context) error {
-	// decode the request body into `cert`
-	cert := &db.Cert{}
-	if err := c.Bind(cert); err != nil {
+	var cert *db.Cert
+	var chain []*x509.Certificate
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		var err error
+		if cert, chain, err = bindCertUpload(c); err != nil {
+			return err
+		}
+	} else {
+		// decode the request body into `cert`
+		cert = &db.Cert{}
+		if err := c.Bind(cert); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Errorf("failed to decode cert: %w", err))
+		}
+		if cert.AuthorityUUID != "" {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity,
+				errors.New("authority_uuid is only set by issuing certificates"))
+		}
+
+		// AddCert only keeps the first certificate of the bundle, the others
+		// are its chain
+		if certs, err := pki.ParseCertificates(cert.Body); err == nil && len(certs) > 1 {
+			chain = certs[1:]
+		}
+	}
+
+	// add cert to database along with the intermediates it is bundled with
+	// and let it fill db-generated fields
+	for _, issuer := range chain {
+		cert.Chain = append(cert.Chain, pki.EncodeCertificate(issuer))
+	}
+	if err := r.db.AddCert(cert, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to add cert: %w", err))
+	}
+
+	// send message to notifier
+	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active, cert.Labels); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to send cert toggled message: %w", err))
+	}
+
+	// wipe private key and write to response with generated fields
+	cert.PrivateKey = ""
+	return c.JSON(http.StatusOK, cert)
+}
+
+// bindCertUpload reads the certificate bundle uploaded as `file` in a
+// multipart form, decrypted with the `password` form value if it is a PKCS#12
+// file, and returns the certificate with its private key and the labels of
+// the `label` form values, and the chain.
+func bindCertUpload(c echo.Context) (*db.Cert, []*x509.Certificate, error) {
+	// read the uploaded file
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to read uploaded file: %w", err))
+	}
+	if header.Size > maxUploadSize {
+		return nil, nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Errorf("uploaded file is larger than %d bytes", maxUploadSize))
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to open uploaded file: %w", err))
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to read uploaded file: %w", err))
+	}
+
+	// split the bundle into the certificate, its private key and chain
+	bundle, err := pki.ParseBundle(data, c.FormValue("password"))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("invalid bundle: %w", err))
+	}
+	form, err := c.FormParams()
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to read form: %w", err))
+	}
+	labels, err := parseLabels(form["label"])
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+	cert := &db.Cert{
+		UserUUID:     c.FormValue("user_uuid"),
+		TeamUUID:     c.FormValue("team_uuid"),
+		ReplacesUUID: c.FormValue("replaces_uuid"),
+		Body:         pki.EncodeCertificate(bundle.Leaf),
+		Labels:       labels,
+	}
+	if bundle.Key != nil {
+		if cert.PrivateKey, err = pki.EncodePrivateKey(bundle.Key); err != nil {
+			return nil, nil, echo.NewHTTPError(http.StatusUnprocessableEntity,
+				fmt.Errorf("failed to encode private key: %w", err))
+		}
+	}
+	return cert, bundle.Chain, nil
+}
+
+// issueCert signs a PKCS#10 CSR with an internal certificate authority under
+// one of the configured profiles, and adds the issued certificate to an
+// existing user and optionally a team.
+func (r *Router) issueCert(c echo.Context) error {
+	// decode the request body into `req`
+	req := &struct {
+		UserUUID      string `json:"user_uuid"`
+		TeamUUID      string `json:"team_uuid"`
+		AuthorityUUID string `json:"authority_uuid"`
+		Profile       string `json:"profile"`
+		CSR           string `json:"csr"`
+	}{}
+	if err := c.Bind(req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			fmt.Errorf("failed to decode cert: %w", err))
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// check the request before loading the authority
+	profile, ok := r.profiles[req.Profile]
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("unknown profile %q", req.Profile))
+	}
+	// profiles that allow names outside of their allowed domains can only be
+	// used by admins
+	if !profile.Restricted() {
+		if err := requireAdmin(c); err != nil {
+			return err
+		}
+	}
+	csr, err := ca.ParseCSR(req.CSR)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("invalid csr: %w", err))
+	}
+	if err := r.db.CheckCertOwners(req.UserUUID, req.TeamUUID, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to check cert owners: %w", err))
+	}
+
+	// sign the CSR with the authority
+	authority, err := r.loadAuthority(req.AuthorityUUID)
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to load authority: %w", err))
+	}
+	issued, err := authority.Sign(csr, profile)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("failed to sign csr: %w", err))
 	}
 
 	// add cert to database and let it fill db-generated fields
-	if err := r.db.AddCert(cert); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError,
+	cert := &db.Cert{
+		UserUUID:      req.UserUUID,
+		TeamUUID:      req.TeamUUID,
+		AuthorityUUID: req.AuthorityUUID,
+		Body:          pki.EncodeCertificate(issued),
+	}
+	if err := r.db.AddCert(cert, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
 			fmt.Errorf("failed to add cert: %w", err))
 	}
 
 	// send message to notifier
-	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active); err != nil {
+	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active, cert.Labels); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Errorf("failed to send cert toggled message: %w", err))
 	}
@@ -40,24 +235,228 @@ func (r *Router) addCert(c echo.Context) error {
 	return c.JSON(http.StatusOK, cert)
 }
 
-// getCerts returns all active certificates belonging to an existing user.
-func (r *Router) getCerts(c echo.Context) error {
-	// decode request body to get the querying user's UUID
+// createCSR generates a private key and a CSR for an existing user, and adds
+// them as a pending certificate. The private key stays in the service, the
+// CSR is returned for signing.
+func (r *Router) createCSR(c echo.Context) error {
+	// decode the request body into `req`
+	req := &struct {
+		UserUUID     string   `json:"user_uuid"`
+		KeyType      string   `json:"key_type"`
+		CommonName   string   `json:"common_name"`
+		Organization string   `json:"organization"`
+		SANs         []string `json:"sans"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// generate the key pair and the CSR
+	key, err := pki.GenerateKey(req.KeyType)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("failed to generate key: %w", err))
+	}
+	subject := pkix.Name{CommonName: req.CommonName}
+	if req.Organization != "" {
+		subject.Organization = []string{req.Organization}
+	}
+	csr, err := pki.CreateCSR(key, subject, req.SANs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("failed to create csr: %w", err))
+	}
+	privateKey, err := pki.EncodePrivateKey(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to encode private key: %w", err))
+	}
+
+	// add pending cert to database and let it fill db-generated fields
+	cert := &db.Cert{
+		UserUUID:     req.UserUUID,
+		PrivateKey:   privateKey,
+		CSR:          csr,
+		Subject:      subject.String(),
+		SANs:         req.SANs,
+		KeyAlgorithm: req.KeyType,
+	}
+	if err := r.db.AddPendingCert(cert, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to add pending cert: %w", err))
+	}
+
+	// wipe private key and write to response with generated fields
+	cert.PrivateKey = ""
+	return c.JSON(http.StatusOK, cert)
+}
+
+// completeCert uploads the signed certificate of an existing user's pending
+// certificate, which activates it, and sends a message through notifier.
+func (r *Router) completeCert(c echo.Context) error {
+	// decode the request body into `cert`
 	cert := &db.Cert{}
 	if err := c.Bind(cert); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			fmt.Errorf("failed to decode cert: %w", err))
 	}
 
+	// complete the pending cert and let the database fill all other fields
+	if err := r.db.CompletePendingCert(cert, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to complete cert: %w", err))
+	}
+
+	// send message to notifier
+	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active, cert.Labels); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to send cert toggled message: %w", err))
+	}
+
+	// write to response with the completed fields
+	return c.JSON(http.StatusOK, cert)
+}
+
+// getCerts returns a page of the public metadata of the active certificates
+// belonging to an existing user.
+func (r *Router) getCerts(c echo.Context) error {
+	// decode the request body or query into `req`
+	req := &struct {
+		UserUUID string `json:"user_uuid" query:"user_uuid"`
+		Cursor   string `json:"cursor" query:"cursor"`
+		Limit    int    `json:"limit" query:"limit"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+	limit, err := pageLimit(req.Limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+
 	// query the database for certificates belonging to this user
-	certs, err := r.db.GetCerts(cert.UserUUID)
+	page, err := r.db.GetCerts(req.UserUUID, req.Cursor, limit, principal(c))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError,
+		return echo.NewHTTPError(httpStatus(err),
 			fmt.Errorf("failed to get certs: %w", err))
 	}
 
-	// write `certs` to response
-	return c.JSON(http.StatusOK, certs)
+	// write `page` to response
+	return c.JSON(http.StatusOK, page)
+}
+
+// queryCerts returns a page of the public metadata of an existing user's or
+// team's certificates that match all of the given filters. Only active
+// certificates are returned unless `active` is "false" or "any".
+func (r *Router) queryCerts(c echo.Context) error {
+	// decode the request body or query into `req`
+	req := &struct {
+		UserUUID      string   `json:"user_uuid" query:"user_uuid"`
+		TeamUUID      string   `json:"team_uuid" query:"team_uuid"`
+		SAN           string   `json:"san" query:"san"`
+		Subject       string   `json:"subject" query:"subject"`
+		Issuer        string   `json:"issuer" query:"issuer"`
+		ExpiresBefore string   `json:"expires_before" query:"expires_before"`
+		ExpiresAfter  string   `json:"expires_after" query:"expires_after"`
+		Active        string   `json:"active" query:"active"`
+		Labels        []string `json:"labels" query:"label"`
+		Cursor        string   `json:"cursor" query:"cursor"`
+		Limit         int      `json:"limit" query:"limit"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// translate the request into a query
+	query := &db.CertQuery{
+		UserUUID: req.UserUUID,
+		TeamUUID: req.TeamUUID,
+		SAN:      req.SAN,
+		Subject:  req.Subject,
+		Issuer:   req.Issuer,
+		Cursor:   req.Cursor,
+	}
+	var err error
+	if query.Limit, err = pageLimit(req.Limit); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+	if query.Labels, err = parseLabels(req.Labels); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+	if query.ExpiresBefore, err = parseQueryTime(req.ExpiresBefore); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("invalid expires_before: %w", err))
+	}
+	if query.ExpiresAfter, err = parseQueryTime(req.ExpiresAfter); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("invalid expires_after: %w", err))
+	}
+	switch req.Active {
+	case "", "true":
+		active := true
+		query.Active = &active
+	case "false":
+		active := false
+		query.Active = &active
+	case "any":
+	default:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("invalid active %q, expected true, false or any", req.Active))
+	}
+
+	// query the database for matching certificates
+	page, err := r.db.QueryCerts(query, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to query certs: %w", err))
+	}
+
+	// write `page` to response
+	return c.JSON(http.StatusOK, page)
+}
+
+// pageLimit returns the number of certificates per page for the requested
+// `limit`, defaultPageLimit if it is 0.
+func pageLimit(limit int) (int, error) {
+	switch {
+	case limit == 0:
+		return defaultPageLimit, nil
+	case limit < 0 || limit > maxPageLimit:
+		return 0, fmt.Errorf("invalid limit %d, expected 1 to %d", limit, maxPageLimit)
+	default:
+		return limit, nil
+	}
+}
+
+// parseLabels parses `labels` of the form "key=value" into a map.
+func parseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	parsed := map[string]string{}
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// parseQueryTime parses `value` as an RFC 3339 timestamp or a date, an empty
+// `value` is the zero time.
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
 }
 
 // setCertActiveStatus activates/deactivates an existing user's certificate
@@ -72,16 +471,137 @@ func (r *Router) setCertActiveStatus(c echo.Context) error {
 	}
 
 	// update the certificate's status in database to active
-	if err := r.db.SetCertActiveStatus(cert.UUID, cert.UserUUID, cert.Active); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError,
+	toggled, err := r.db.SetCertActiveStatus(cert.UUID, cert.UserUUID, cert.Active, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
 			fmt.Errorf("failed to toggle cert status: %w", err))
 	}
 
 	// send message to notifier
-	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active); err != nil {
+	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active, toggled.Labels); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			fmt.Errorf("failed to send cert toggled message: %w", err))
 	}
 
 	return c.String(http.StatusOK, "success!")
 }
+
+// transferCert moves an existing user's certificate to another active user,
+// a team, or both, e.g. when its user leaves, or takes it out of its team.
+func (r *Router) transferCert(c echo.Context) error {
+	// decode the request path and body into `req`
+	req := &struct {
+		UUID       string `param:"uuid"`
+		UserUUID   string `json:"user_uuid"`
+		ToUserUUID string `json:"to_user_uuid"`
+		ToTeamUUID string `json:"to_team_uuid"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// move the certificate and let the database return it
+	cert, err := r.db.TransferCert(req.UUID, req.UserUUID, req.ToUserUUID, req.ToTeamUUID, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to transfer cert: %w", err))
+	}
+
+	// send message to notifier
+	if err := r.notifier.SendCertTransferred(cert.UUID, req.UserUUID, cert.UserUUID, cert.TeamUUID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to send cert transferred message: %w", err))
+	}
+
+	// write cert to response
+	return c.JSON(http.StatusOK, cert)
+}
+
+// setCertLabels sets the labels of an existing user's certificate, labels
+// with an empty value are removed and labels that are not given are kept.
+func (r *Router) setCertLabels(c echo.Context) error {
+	// decode the request body into `req`
+	req := &struct {
+		UUID     string            `json:"uuid"`
+		UserUUID string            `json:"user_uuid"`
+		Labels   map[string]string `json:"labels"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// merge the labels and let the database return the updated cert
+	cert, err := r.db.SetCertLabels(req.UUID, req.UserUUID, req.Labels, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to set cert labels: %w", err))
+	}
+
+	// write the updated cert to response
+	return c.JSON(http.StatusOK, cert)
+}
+
+// getCertHistory returns the events of an existing user's certificate, oldest
+// first, with who made each change and the changed fields before and after.
+func (r *Router) getCertHistory(c echo.Context) error {
+	// decode the request path, body or query into `req`
+	req := &struct {
+		UUID     string `param:"uuid"`
+		UserUUID string `json:"user_uuid" query:"user_uuid"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// query the database for the events of the certificate
+	events, err := r.db.GetCertHistory(req.UUID, req.UserUUID, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get cert history: %w", err))
+	}
+
+	// write `events` to response
+	return c.JSON(http.StatusOK, events)
+}
+
+// exportPrivateKey returns the private key of an existing user's certificate
+// after checking the caller's password, and records the export in the audit
+// log.
+func (r *Router) exportPrivateKey(c echo.Context) error {
+	// decode the request body into `req`
+	req := &struct {
+		UUID     string `json:"uuid"`
+		UserUUID string `json:"user_uuid"`
+		Password string `json:"password"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// ask the database for the private key, which records the export
+	export := &db.KeyExport{
+		CertUUID:   req.UUID,
+		UserUUID:   req.UserUUID,
+		RemoteAddr: c.RealIP(),
+	}
+	privateKey, err := r.db.ExportPrivateKey(export, req.Password, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to export private key: %w", err))
+	}
+
+	// write the private key to response
+	return c.JSON(http.StatusOK, struct {
+		UUID       string `json:"uuid"`
+		PrivateKey string `json:"private_key"`
+		ExportUUID string `json:"export_uuid"`
+	}{
+		UUID:       req.UUID,
+		PrivateKey: privateKey,
+		ExportUUID: export.UUID,
+	})
+}
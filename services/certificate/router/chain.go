@@ -0,0 +1,130 @@
+package router
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"crypto/x509"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const (
+	chainPath     = "/chain"
+	certChainPath = "/cert/:uuid/chain"
+)
+
+func (r *Router) routeChain() {
+	r.POST(chainPath, r.addChainCerts)
+	r.GET(certChainPath, r.getCertChain)
+}
+
+// addChainCerts adds the intermediate and root certificates of a PEM bundle,
+// which are trusted from then on if `trusted` is set. Only admins may add
+// chain certificates.
+func (r *Router) addChainCerts(c echo.Context) error {
+	if err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	// decode the request body into `req`
+	req := &struct {
+		Body    string `json:"body"`
+		Trusted bool   `json:"trusted"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+	certs, err := pki.ParseCertificates(req.Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("failed to parse body: %w", err))
+	}
+
+	// add the certificates to database and let it fill db-generated fields
+	chainCerts := make([]*db.ChainCert, 0, len(certs))
+	for _, cert := range certs {
+		chainCert := &db.ChainCert{Body: pki.EncodeCertificate(cert), Trusted: req.Trusted}
+		if err := r.db.AddChainCert(chainCert); err != nil {
+			return echo.NewHTTPError(httpStatus(err),
+				fmt.Errorf("failed to add chain certs: %w", err))
+		}
+		chainCerts = append(chainCerts, chainCert)
+	}
+	return c.JSON(http.StatusOK, chainCerts)
+}
+
+// getCertChain returns the verified path from a certificate of an existing
+// user to a trusted root as a PEM bundle, starting with the certificate.
+func (r *Router) getCertChain(c echo.Context) error {
+	// decode the path and the request body or query into `req`
+	req := &struct {
+		UUID     string `param:"uuid"`
+		UserUUID string `json:"user_uuid" query:"user_uuid"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	cert, err := r.db.GetCert(req.UUID, req.UserUUID, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get cert: %w", err))
+	}
+	if cert.Status == db.CertStatusPending {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("cert %s is still pending", cert.UUID))
+	}
+	leaf, err := pki.ParseCertificate(cert.Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to parse cert: %w", err))
+	}
+
+	// build the chain out of every stored issuer above the certificate
+	roots, intermediates, err := r.issuerCerts(leaf)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to get issuer certs: %w", err))
+	}
+	chain, err := pki.BuildChain(leaf, roots, intermediates)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("failed to build chain to a trusted root: %w", err))
+	}
+	return c.Blob(http.StatusOK, "application/pem-certificate-chain",
+		[]byte(pki.EncodeCertificates(chain)))
+}
+
+// issuerCerts returns the stored issuers of `cert`, the issuers of those, and
+// so on up to the trusted roots, following authority key identifiers. The
+// trusted ones are returned as `roots`, the others as `intermediates`.
+func (r *Router) issuerCerts(cert *x509.Certificate) (roots, intermediates []*x509.Certificate, err error) {
+	seen := map[string]bool{}
+	for queue := []*x509.Certificate{cert}; len(queue) > 0; queue = queue[1:] {
+		keyID := pki.KeyID(queue[0].AuthorityKeyId)
+		if keyID == "" || seen[keyID] || pki.IsSelfSigned(queue[0]) {
+			continue
+		}
+		seen[keyID] = true
+		issuerCerts, err := r.db.GetIssuerCerts(keyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, issuerCert := range issuerCerts {
+			issuer, err := pki.ParseCertificate(issuerCert.Body)
+			if err != nil {
+				return nil, nil, err
+			}
+			if issuerCert.Trusted {
+				roots = append(roots, issuer)
+			} else {
+				intermediates = append(intermediates, issuer)
+				queue = append(queue, issuer)
+			}
+		}
+	}
+	return roots, intermediates, nil
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"certificate/db"
+	"certificate/pki"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const certExportPath = "/cert/:uuid/export"
+
+func (r *Router) routeExport() {
+	r.GET(certExportPath, r.exportCert)
+}
+
+// exportCert packages a certificate of an existing user with its chain, and
+// with its private key if the caller's password is given, in one of the
+// export formats.
+func (r *Router) exportCert(c echo.Context) error {
+	// decode the path and the request body or query into `req`
+	req := &struct {
+		UUID           string `param:"uuid"`
+		UserUUID       string `json:"user_uuid" query:"user_uuid"`
+		Format         string `json:"format" query:"format"`
+		Password       string `json:"password"`
+		ExportPassword string `json:"export_password"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+	contentType, ok := pki.ExportContentTypes[req.Format]
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("unknown export format %q", req.Format))
+	}
+
+	// reject what the format cannot hold before the private key is exported
+	switch {
+	case req.Format == pki.FormatDER && req.Password != "":
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			errors.New("DER cannot hold a private key"))
+	case (req.Format == pki.FormatPKCS12 || req.Format == pki.FormatJKS) && req.ExportPassword == "":
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%s requires an export_password", req.Format))
+	}
+
+	cert, err := r.db.GetCert(req.UUID, req.UserUUID, principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get cert: %w", err))
+	}
+	if cert.Status == db.CertStatusPending {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("cert %s is still pending", cert.UUID))
+	}
+	leaf, err := pki.ParseCertificate(cert.Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to parse cert: %w", err))
+	}
+
+	// export the certificate alone if there is no chain to a trusted root
+	roots, intermediates, err := r.issuerCerts(leaf)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to get issuer certs: %w", err))
+	}
+	chain, err := pki.BuildChain(leaf, roots, intermediates)
+	if err != nil {
+		chain = []*x509.Certificate{leaf}
+	}
+
+	// ask the database for the private key if the caller's password is given,
+	// which records the export
+	var key crypto.Signer
+	if req.Password != "" {
+		export := &db.KeyExport{
+			CertUUID:   cert.UUID,
+			UserUUID:   cert.UserUUID,
+			RemoteAddr: c.RealIP(),
+		}
+		privateKey, err := r.db.ExportPrivateKey(export, req.Password, principal(c))
+		if err != nil {
+			return echo.NewHTTPError(httpStatus(err),
+				fmt.Errorf("failed to export private key: %w", err))
+		}
+		if key, err = pki.ParsePrivateKey(privateKey); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError,
+				fmt.Errorf("failed to parse private key: %w", err))
+		}
+	}
+
+	exported, err := pki.Export(req.Format, chain, key, cert.UUID, req.ExportPassword)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to export cert: %w", err))
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=%q", cert.UUID+"."+req.Format))
+	return c.Blob(http.StatusOK, contentType, exported)
+}
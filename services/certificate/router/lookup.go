@@ -0,0 +1,53 @@
+package router
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/url"
+)
+
+const (
+	certByFingerprintPath = "/cert/by-fingerprint/:fingerprint"
+	certBySerialPath      = "/cert/by-serial/:issuer/:serial"
+)
+
+func (r *Router) routeLookup() {
+	r.GET(certByFingerprintPath, r.getCertsByFingerprint)
+	r.GET(certBySerialPath, r.getCertsByIssuerSerial)
+}
+
+// getCertsByFingerprint returns the public metadata, including the owner, of
+// the certificates with a SHA-256 fingerprint, of all users for admins and of
+// the caller otherwise.
+func (r *Router) getCertsByFingerprint(c echo.Context) error {
+	certs, err := r.db.GetCertsByFingerprint(c.Param("fingerprint"), principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get certs: %w", err))
+	}
+
+	// write `certs` to response
+	return c.JSON(http.StatusOK, certs)
+}
+
+// getCertsByIssuerSerial returns the public metadata, including the owner, of
+// the certificates with an issuer distinguished name and a serial number, of
+// all users for admins and of the caller otherwise.
+func (r *Router) getCertsByIssuerSerial(c echo.Context) error {
+	// the issuer is path escaped, as distinguished names can contain slashes
+	issuer, err := url.PathUnescape(c.Param("issuer"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode issuer: %w", err))
+	}
+
+	certs, err := r.db.GetCertsByIssuerSerial(issuer, c.Param("serial"), principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get certs: %w", err))
+	}
+
+	// write `certs` to response
+	return c.JSON(http.StatusOK, certs)
+}
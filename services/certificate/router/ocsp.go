@@ -0,0 +1,58 @@
+package router
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	ocspPath           = "/ocsp"
+	ocspGetPath        = "/ocsp/*"
+	ocspContentType    = "application/ocsp-response"
+	ocspMaxRequestSize = 4096
+)
+
+func (r *Router) routeOCSP() {
+	r.POST(ocspPath, r.respondOCSP)
+	r.GET(ocspGetPath, r.respondOCSP)
+}
+
+// respondOCSP answers an OCSP request about a certificate issued by an
+// internal CA, sent either as the body of a POST or base64 encoded in the
+// path of a GET, see RFC 6960 appendix A.1.
+func (r *Router) respondOCSP(c echo.Context) error {
+	if r.ocsp == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			errors.New("OCSP is not configured"))
+	}
+
+	// decode the request from the body or the path
+	var request []byte
+	var err error
+	if c.Request().Method == http.MethodGet {
+		var encoded string
+		if encoded, err = url.PathUnescape(c.Param("*")); err == nil {
+			request, err = base64.StdEncoding.DecodeString(encoded)
+		}
+	} else {
+		request, err = io.ReadAll(io.LimitReader(c.Request().Body, ocspMaxRequestSize))
+	}
+	if err != nil {
+		return c.Blob(http.StatusOK, ocspContentType, ocsp.MalformedRequestErrorResponse)
+	}
+
+	// failures are answered with an OCSP error response, which clients
+	// understand better than an HTTP error
+	response, err := r.ocsp.Respond(request)
+	if err != nil {
+		c.Logger().Error(fmt.Errorf("failed to respond to OCSP request: %w", err))
+		return c.Blob(http.StatusOK, ocspContentType, ocsp.InternalErrorErrorResponse)
+	}
+	return c.Blob(http.StatusOK, ocspContentType, response)
+}
@@ -0,0 +1,190 @@
+package router
+
+import (
+	"certificate/db"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const (
+	orgPath         = "/org"
+	orgUUIDPath     = "/org/:uuid"
+	orgMembersPath  = "/org/:uuid/members"
+	orgMemberPath   = "/org/:uuid/members/:user_uuid"
+	orgTeamsPath    = "/org/:uuid/teams"
+	teamPath        = "/team"
+	teamMembersPath = "/team/:uuid/members"
+	teamMemberPath  = "/team/:uuid/members/:user_uuid"
+)
+
+func (r *Router) routeOrg() {
+	r.POST(orgPath, r.addOrg)
+	r.GET(orgUUIDPath, r.getOrg)
+	r.GET(orgMembersPath, r.getOrgMembers)
+	r.PUT(orgMemberPath, r.setOrgMember)
+	r.DELETE(orgMemberPath, r.removeOrgMember)
+	r.POST(orgTeamsPath, r.addTeam)
+	r.GET(orgTeamsPath, r.getTeams)
+	r.GET(teamMembersPath, r.getTeamMembers)
+	r.PUT(teamMemberPath, r.setTeamMember)
+	r.DELETE(teamMemberPath, r.removeTeamMember)
+}
+
+// addOrg adds an organization owned by the authenticated user.
+func (r *Router) addOrg(c echo.Context) error {
+	// decode the request body into `org`
+	org := &db.Org{}
+	if err := c.Bind(org); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode org: %w", err))
+	}
+
+	// add org to database and let it fill db-generated fields
+	if err := r.db.AddOrg(org, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to add org: %w", err))
+	}
+
+	// write org to response with generated fields
+	return c.JSON(http.StatusOK, org)
+}
+
+// getOrg gets an existing organization.
+func (r *Router) getOrg(c echo.Context) error {
+	org, err := r.db.GetOrg(c.Param("uuid"), principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get org: %w", err))
+	}
+
+	// write org to response
+	return c.JSON(http.StatusOK, org)
+}
+
+// getOrgMembers returns the members of an existing organization with their
+// roles.
+func (r *Router) getOrgMembers(c echo.Context) error {
+	members, err := r.db.GetOrgMembers(c.Param("uuid"), principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get org members: %w", err))
+	}
+
+	// write `members` to response
+	return c.JSON(http.StatusOK, members)
+}
+
+// setOrgMember adds a user to an existing organization, or changes its role.
+func (r *Router) setOrgMember(c echo.Context) error {
+	// decode the request path and body into `req`
+	req := &struct {
+		OrgUUID  string `param:"uuid"`
+		UserUUID string `param:"user_uuid"`
+		Role     string `json:"role"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// set the member and let the database fill db-generated fields
+	member := &db.Member{UserUUID: req.UserUUID, Role: req.Role}
+	if err := r.db.SetOrgMember(req.OrgUUID, member, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to set org member: %w", err))
+	}
+
+	// write member to response
+	return c.JSON(http.StatusOK, member)
+}
+
+// removeOrgMember removes a user from an existing organization and its teams.
+func (r *Router) removeOrgMember(c echo.Context) error {
+	if err := r.db.RemoveOrgMember(c.Param("uuid"), c.Param("user_uuid"), principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to remove org member: %w", err))
+	}
+	return c.String(http.StatusOK, "success!")
+}
+
+// addTeam adds a team to an existing organization.
+func (r *Router) addTeam(c echo.Context) error {
+	// decode the request path and body into `req`
+	req := &struct {
+		OrgUUID string `param:"uuid"`
+		Name    string `json:"name"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// add team to database and let it fill db-generated fields
+	team := &db.Team{OrgUUID: req.OrgUUID, Name: req.Name}
+	if err := r.db.AddTeam(team, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to add team: %w", err))
+	}
+
+	// write team to response with generated fields
+	return c.JSON(http.StatusOK, team)
+}
+
+// getTeams returns the teams of an existing organization.
+func (r *Router) getTeams(c echo.Context) error {
+	teams, err := r.db.GetTeams(c.Param("uuid"), principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get teams: %w", err))
+	}
+
+	// write `teams` to response
+	return c.JSON(http.StatusOK, teams)
+}
+
+// getTeamMembers returns the members of an existing team with their roles.
+func (r *Router) getTeamMembers(c echo.Context) error {
+	members, err := r.db.GetTeamMembers(c.Param("uuid"), principal(c))
+	if err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to get team members: %w", err))
+	}
+
+	// write `members` to response
+	return c.JSON(http.StatusOK, members)
+}
+
+// setTeamMember adds a member of the organization to an existing team, or
+// changes its role.
+func (r *Router) setTeamMember(c echo.Context) error {
+	// decode the request path and body into `req`
+	req := &struct {
+		TeamUUID string `param:"uuid"`
+		UserUUID string `param:"user_uuid"`
+		Role     string `json:"role"`
+	}{}
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode request: %w", err))
+	}
+
+	// set the member and let the database fill db-generated fields
+	member := &db.Member{UserUUID: req.UserUUID, Role: req.Role}
+	if err := r.db.SetTeamMember(req.TeamUUID, member, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to set team member: %w", err))
+	}
+
+	// write member to response
+	return c.JSON(http.StatusOK, member)
+}
+
+// removeTeamMember removes a user from an existing team.
+func (r *Router) removeTeamMember(c echo.Context) error {
+	if err := r.db.RemoveTeamMember(c.Param("uuid"), c.Param("user_uuid"), principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to remove team member: %w", err))
+	}
+	return c.String(http.StatusOK, "success!")
+}
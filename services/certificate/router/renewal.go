@@ -0,0 +1,61 @@
+package router
+
+import (
+	"certificate/db"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const certRenewalPolicyPath = "/cert/renewal-policy"
+
+func (r *Router) routeRenewal() {
+	r.PUT(certRenewalPolicyPath, r.setRenewalPolicy)
+	r.DELETE(certRenewalPolicyPath, r.deleteRenewalPolicy)
+}
+
+// setRenewalPolicy adds or replaces the renewal policy of a certificate that
+// belongs to an existing user.
+func (r *Router) setRenewalPolicy(c echo.Context) error {
+	// decode the request body into `policy`
+	policy := &db.RenewalPolicy{}
+	if err := c.Bind(policy); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode renewal policy: %w", err))
+	}
+
+	// check that the policy can be carried out with this configuration
+	switch {
+	case policy.Method == db.RenewalMethodCA && r.profiles[policy.Profile] == nil:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			fmt.Errorf("unknown profile %q", policy.Profile))
+	case policy.Method == db.RenewalMethodACME && r.acme == nil:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity,
+			errors.New("ACME is not configured"))
+	}
+
+	// add policy to database and let it fill db-generated fields
+	if err := r.db.SetRenewalPolicy(policy, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to set renewal policy: %w", err))
+	}
+	return c.JSON(http.StatusOK, policy)
+}
+
+// deleteRenewalPolicy deletes the renewal policy of a certificate that
+// belongs to an existing user.
+func (r *Router) deleteRenewalPolicy(c echo.Context) error {
+	// decode the request body into `policy`
+	policy := &db.RenewalPolicy{}
+	if err := c.Bind(policy); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode renewal policy: %w", err))
+	}
+
+	if err := r.db.DeleteRenewalPolicy(policy.CertUUID, policy.UserUUID, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to delete renewal policy: %w", err))
+	}
+	return c.String(http.StatusOK, "success!")
+}
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"certificate/db"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+const (
+	certRevokePath = "/cert/revoke"
+	caCRLPath      = "/ca/:uuid/crl"
+)
+
+func (r *Router) routeRevocation() {
+	r.POST(certRevokePath, r.revokeCert)
+	r.GET(caCRLPath, r.getCRL)
+}
+
+// revokeCert revokes a certificate that belongs to an existing user and
+// republishes the CRL of its internal CA if it has one.
+func (r *Router) revokeCert(c echo.Context) error {
+	// decode the request body into `cert`
+	cert := &db.Cert{}
+	if err := c.Bind(cert); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Errorf("failed to decode cert: %w", err))
+	}
+
+	// revoke the certificate and let the database fill the revoked fields
+	if err := r.db.RevokeCert(cert, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
+			fmt.Errorf("failed to revoke cert: %w", err))
+	}
+
+	if r.ocsp != nil {
+		r.ocsp.Invalidate(cert.UUID)
+	}
+
+	// the revocation stands even if the CRL fails to publish, the scheduled
+	// job publishes it later
+	if cert.AuthorityUUID != "" && r.crls != nil {
+		if _, err := r.crls.Publish(cert.AuthorityUUID); err != nil {
+			c.Logger().Errorf("failed to publish CRL: %v", err)
+		}
+	}
+
+	// send message to notifier
+	if err := r.notifier.SendCertToggled(cert.UUID, false, cert.Labels); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			fmt.Errorf("failed to send cert toggled message: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, cert)
+}
+
+// getCRL returns the DER encoded CRL of an internal CA.
+func (r *Router) getCRL(c echo.Context) error {
+	if r.crls == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			errors.New("CRLs are not configured"))
+	}
+	crl, err := r.crls.Get(c.Param("uuid"))
+	if err != nil {
+		// the authority in the path is not found rather than invalid
+		status := http.StatusInternalServerError
+		var validationErr *db.ValidationError
+		if errors.As(err, &validationErr) {
+			status = http.StatusNotFound
+		}
+		return echo.NewHTTPError(status, fmt.Errorf("failed to get CRL: %w", err))
+	}
+	c.Response().Header().Set(echo.HeaderLastModified, crl.ThisUpdate.Format(http.TimeFormat))
+	c.Response().Header().Set("Expires", crl.NextUpdate.Format(http.TimeFormat))
+	c.Response().Header().Set(echo.HeaderCacheControl,
+		fmt.Sprintf("max-age=%d", int(time.Until(crl.NextUpdate).Seconds())))
+	return c.Blob(http.StatusOK, "application/pkix-crl", crl.DER)
+}
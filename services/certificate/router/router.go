@@ -1,23 +1,49 @@
 package router
 
 import (
+	"certificate/acme"
+	"certificate/auth"
+	"certificate/ca"
 	"certificate/db"
 	"certificate/notifier"
+	"certificate/ocsp"
+	"certificate/scheduler"
+	"errors"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"net/http"
 )
 
 type Router struct {
-	db       db.Database
-	notifier *notifier.Notifier
+	db             db.Database
+	notifier       *notifier.Notifier
+	profiles       ca.Profiles
+	acme           *acme.Client
+	acmeChallenges *acme.HTTP01Solver
+	crls           *scheduler.CRLPublisher
+	ocsp           *ocsp.Responder
+	tokens         *auth.Tokens
+	baseURL        string
 	*echo.Echo
 }
 
 func New() *Router {
-	r := &Router{Echo: echo.New()}
+	r := &Router{Echo: echo.New(), profiles: ca.DefaultProfiles}
 	r.Use(middleware.Logger())
+	r.Use(r.authenticate)
+	r.routeAuth()
 	r.routeCert()
 	r.routeUser()
+	r.routeAPIKey()
+	r.routeOrg()
+	r.routeCA()
+	r.routeACME()
+	r.routeRenewal()
+	r.routeRevocation()
+	r.routeOCSP()
+	r.routeChain()
+	r.routeExport()
+	r.routeLookup()
 	return r
 }
 
@@ -34,3 +60,58 @@ func (r *Router) WithNotifier(notifier *notifier.Notifier) *Router {
 	r.notifier = notifier
 	return r
 }
+
+func (r *Router) WithProfiles(profiles ca.Profiles) *Router {
+	r.profiles = profiles
+	return r
+}
+
+func (r *Router) WithACME(client *acme.Client) *Router {
+	r.acme = client
+	return r
+}
+
+func (r *Router) WithACMEChallenges(challenges *acme.HTTP01Solver) *Router {
+	r.acmeChallenges = challenges
+	return r
+}
+
+func (r *Router) WithCRLPublisher(crls *scheduler.CRLPublisher) *Router {
+	r.crls = crls
+	return r
+}
+
+func (r *Router) WithOCSPResponder(responder *ocsp.Responder) *Router {
+	r.ocsp = responder
+	return r
+}
+
+func (r *Router) WithTokens(tokens *auth.Tokens) *Router {
+	r.tokens = tokens
+	return r
+}
+
+// WithBaseURL sets the public URL of the service, which issued certificates
+// point to for their CRL and OCSP responder.
+func (r *Router) WithBaseURL(baseURL string) *Router {
+	r.baseURL = baseURL
+	return r
+}
+
+// httpStatus returns the HTTP status code to respond with when a request
+// failed with `err`.
+func httpStatus(err error) int {
+	var validationErr *db.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, db.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -44,9 +44,9 @@ func (r *Router) getUser(c echo.Context) error {
 	}
 
 	// query database for user
-	user, err := r.db.GetUser(user.UUID)
+	user, err := r.db.GetUser(user.UUID, principal(c))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError,
+		return echo.NewHTTPError(httpStatus(err),
 			fmt.Errorf("failed to get user: %w", err))
 	}
 
@@ -63,8 +63,8 @@ func (r *Router) deleteUser(c echo.Context) error {
 	}
 
 	// ask the database to delete user
-	if err := r.db.DeleteUser(user.UUID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError,
+	if err := r.db.DeleteUser(user.UUID, principal(c)); err != nil {
+		return echo.NewHTTPError(httpStatus(err),
 			fmt.Errorf("failed to delete user %s: %w", user.UUID, err))
 	}
 
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// crlValidity is how long a published CRL is valid for, it is republished
+// well before that by the scheduled job and on every revocation.
+const crlValidity = 24 * time.Hour
+
+// CRLPublisher signs and stores the CRLs of internal CAs.
+type CRLPublisher struct {
+	db db.Database
+}
+
+// NewCRLPublisher returns a CRLPublisher for the authorities in `db`.
+func NewCRLPublisher(db db.Database) *CRLPublisher {
+	return &CRLPublisher{db: db}
+}
+
+// PublishAll publishes a new CRL for every authority.
+func (p *CRLPublisher) PublishAll(ctx context.Context) error {
+	authorities, err := p.db.GetAuthorities()
+	if err != nil {
+		return err
+	}
+	for _, authority := range authorities {
+		if _, errPublish := p.Publish(authority.UUID); errPublish != nil {
+			err = errors.Join(err, fmt.Errorf("failed to publish CRL of authority %s: %w", authority.UUID, errPublish))
+		}
+	}
+	return err
+}
+
+// Publish signs a new CRL listing every certificate revoked by authority
+// `authorityUUID`, stores it and returns it.
+func (p *CRLPublisher) Publish(authorityUUID string) (*db.CRL, error) {
+	authority, err := p.db.GetAuthority(authorityUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authority: %w", err)
+	}
+	loaded, err := ca.Load(authority.Body, authority.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authority: %w", err)
+	}
+	revoked, err := p.db.GetRevokedCerts(authorityUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revoked certs: %w", err)
+	}
+	revocations := make([]ca.Revocation, 0, len(revoked))
+	for _, cert := range revoked {
+		serialNumber, ok := new(big.Int).SetString(cert.SerialNumber, 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial number %q of cert %s", cert.SerialNumber, cert.UUID)
+		}
+		revocations = append(revocations, ca.Revocation{
+			SerialNumber: serialNumber,
+			RevokedAt:    cert.RevokedAt,
+			Reason:       cert.RevocationReason,
+		})
+	}
+
+	number, err := p.db.NextCRLNumber(authorityUUID)
+	if err != nil {
+		return nil, err
+	}
+	crl := &db.CRL{
+		AuthorityUUID: authorityUUID,
+		Number:        number,
+		ThisUpdate:    time.Now().UTC().Truncate(time.Second),
+	}
+	crl.NextUpdate = crl.ThisUpdate.Add(crlValidity)
+	if crl.DER, err = loaded.CreateCRL(big.NewInt(number), revocations, crl.ThisUpdate, crl.NextUpdate); err != nil {
+		return nil, err
+	}
+	if err := p.db.SaveCRL(crl); err != nil {
+		return nil, err
+	}
+	return crl, nil
+}
+
+// Get returns the latest CRL of authority `authorityUUID`, publishing a new
+// one if it has none yet or it is past its next update.
+func (p *CRLPublisher) Get(authorityUUID string) (*db.CRL, error) {
+	crl, err := p.db.GetCRL(authorityUUID)
+	if err != nil {
+		return nil, err
+	}
+	if crl == nil || !time.Now().Before(crl.NextUpdate) {
+		return p.Publish(authorityUUID)
+	}
+	return crl, nil
+}
@@ -0,0 +1,103 @@
+package scheduler_test
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"certificate/pki"
+	"certificate/scheduler"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCRLPublisher(t *testing.T) {
+	root, err := ca.NewRoot(pkix.Name{CommonName: "Mock Root CA"}, 24*time.Hour)
+	assert.Nil(t, err)
+	rootKey, err := pki.EncodePrivateKey(root.Key)
+	assert.Nil(t, err)
+	authority := &db.Authority{UUID: "mock_authority_uuid", Body: pki.EncodeCertificate(root.Cert), PrivateKey: rootKey}
+	revoked := &db.Cert{
+		UUID:             "mock_cert_uuid",
+		AuthorityUUID:    authority.UUID,
+		Status:           db.CertStatusRevoked,
+		SerialNumber:     "2a",
+		RevokedAt:        time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		RevocationReason: pki.ReasonSuperseded,
+	}
+	newDatabase := func() *MockDatabase {
+		return &MockDatabase{
+			Authorities: map[string]*db.Authority{authority.UUID: authority},
+			Revoked:     map[string][]*db.Cert{authority.UUID: {revoked}},
+			CRLs:        map[string]*db.CRL{},
+		}
+	}
+
+	t.Run("happy_path_publish", func(t *testing.T) {
+		mdb := newDatabase()
+
+		crl, err := scheduler.NewCRLPublisher(mdb).Publish(authority.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, mdb.CRLs[authority.UUID], crl)
+		assert.Equal(t, int64(1), crl.Number)
+		assert.Equal(t, 24*time.Hour, crl.NextUpdate.Sub(crl.ThisUpdate))
+
+		parsed, err := x509.ParseRevocationList(crl.DER)
+		assert.Nil(t, err)
+		assert.Nil(t, parsed.CheckSignatureFrom(root.Cert))
+		assert.Equal(t, big.NewInt(1), parsed.Number)
+		assert.Len(t, parsed.RevokedCertificates, 1)
+		assert.Equal(t, big.NewInt(42), parsed.RevokedCertificates[0].SerialNumber)
+		assert.Equal(t, revoked.RevokedAt, parsed.RevokedCertificates[0].RevocationTime)
+	})
+	t.Run("happy_path_publish_all", func(t *testing.T) {
+		mdb := newDatabase()
+
+		assert.Nil(t, scheduler.NewCRLPublisher(mdb).PublishAll(context.Background()))
+		assert.Contains(t, mdb.CRLs, authority.UUID)
+	})
+	t.Run("happy_path_get_current", func(t *testing.T) {
+		mdb := newDatabase()
+		current := &db.CRL{AuthorityUUID: authority.UUID, Number: 5, NextUpdate: time.Now().Add(time.Hour)}
+		mdb.CRLs[authority.UUID] = current
+
+		crl, err := scheduler.NewCRLPublisher(mdb).Get(authority.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, current, crl)
+	})
+	t.Run("happy_path_get_stale", func(t *testing.T) {
+		mdb := newDatabase()
+		mdb.CRLs[authority.UUID] = &db.CRL{AuthorityUUID: authority.UUID, Number: 5, NextUpdate: time.Now().Add(-time.Hour)}
+
+		crl, err := scheduler.NewCRLPublisher(mdb).Get(authority.UUID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(6), crl.Number)
+		assert.True(t, crl.NextUpdate.After(time.Now()))
+	})
+	t.Run("error_unknown_authority", func(t *testing.T) {
+		crl, err := scheduler.NewCRLPublisher(newDatabase()).Get("unknown_authority_uuid")
+		var validationErr *db.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Nil(t, crl)
+	})
+	t.Run("error_invalid_serial_number", func(t *testing.T) {
+		mdb := newDatabase()
+		mdb.Revoked[authority.UUID] = []*db.Cert{{UUID: "mock_cert_uuid", SerialNumber: "serial"}}
+
+		crl, err := scheduler.NewCRLPublisher(mdb).Publish(authority.UUID)
+		assert.NotNil(t, err)
+		assert.Nil(t, crl)
+		assert.Empty(t, mdb.CRLs)
+	})
+	t.Run("error_database", func(t *testing.T) {
+		mdb := newDatabase()
+		mdb.Err = errors.New("mock_error")
+
+		assert.NotNil(t, scheduler.NewCRLPublisher(mdb).PublishAll(context.Background()))
+		assert.Empty(t, mdb.CRLs)
+	})
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"certificate/db"
+	"certificate/notifier"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultExpiryThresholds are the days before expiry at which certificates
+// are notified about when no thresholds are configured.
+var DefaultExpiryThresholds = []int{30, 14, 7, 1}
+
+// ExpiryScanner notifies about active certificates once they expire within
+// any of its thresholds, once per certificate and threshold.
+type ExpiryScanner struct {
+	db         db.CertDatabase
+	notifier   notifier.CertNotifier
+	thresholds []int
+}
+
+// NewExpiryScanner returns an ExpiryScanner for the certificates in `db` that
+// sends notifications through `notifier` at `thresholds` days before expiry.
+func NewExpiryScanner(db db.CertDatabase, notifier notifier.CertNotifier, thresholds []int) *ExpiryScanner {
+	// scan the lowest threshold first, so that a certificate that is already
+	// within several thresholds is only notified about the most urgent one
+	sorted := append([]int{}, thresholds...)
+	sort.Ints(sorted)
+	return &ExpiryScanner{db: db, notifier: notifier, thresholds: sorted}
+}
+
+// Scan sends a notification for every certificate that entered a threshold
+// since it was last notified about, and records it so that it is not sent
+// again. A notification that fails to send is retried by the next Scan.
+func (s *ExpiryScanner) Scan(ctx context.Context) error {
+	now := time.Now().UTC()
+	var err error
+	for _, thresholdDays := range s.thresholds {
+		certs, errGet := s.db.GetExpiringCerts(now, thresholdDays)
+		if errGet != nil {
+			err = errors.Join(err, errGet)
+			continue
+		}
+		for _, cert := range certs {
+			if errSend := s.notifier.SendCertExpiring(cert.UUID, cert.NotAfter, thresholdDays); errSend != nil {
+				err = errors.Join(err, fmt.Errorf("failed to send cert expiring message: %w", errSend))
+				continue
+			}
+			err = errors.Join(err, s.db.AddExpiryNotification(cert.UUID, thresholdDays))
+		}
+	}
+	return err
+}
+
+// ParseThresholds parses a comma separated list of positive days, like
+// "30,14,7,1".
+func ParseThresholds(s string) ([]int, error) {
+	var thresholds []int
+	for _, field := range strings.Split(s, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid threshold %q", field)
+		}
+		thresholds = append(thresholds, days)
+	}
+	return thresholds, nil
+}
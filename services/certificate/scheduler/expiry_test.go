@@ -0,0 +1,73 @@
+package scheduler_test
+
+import (
+	"certificate/db"
+	"certificate/scheduler"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestExpiryScanner_Scan(t *testing.T) {
+	soon := &db.Cert{UUID: "soon", NotAfter: time.Now().Add(12 * time.Hour)}
+	later := &db.Cert{UUID: "later", NotAfter: time.Now().Add(10 * 24 * time.Hour)}
+	expiring := map[int][]*db.Cert{
+		1:  {soon},
+		7:  {soon},
+		14: {soon, later},
+		30: {soon, later},
+	}
+
+	t.Run("happy_path_most_urgent_threshold_once", func(t *testing.T) {
+		mdb := &MockDatabase{Expiring: expiring, Notifications: map[string][]int{}}
+		mn := &MockCertNotifier{}
+		scanner := scheduler.NewExpiryScanner(mdb, mn, scheduler.DefaultExpiryThresholds)
+
+		assert.Nil(t, scanner.Scan(context.Background()))
+		assert.Equal(t, []string{"soon@1", "later@14"}, mn.Expiring)
+		assert.Equal(t, map[string][]int{"soon": {1}, "later": {14}}, mdb.Notifications)
+
+		// nothing new to notify about
+		assert.Nil(t, scanner.Scan(context.Background()))
+		assert.Len(t, mn.Expiring, 2)
+	})
+	t.Run("error_send_not_recorded", func(t *testing.T) {
+		mdb := &MockDatabase{Expiring: expiring, Notifications: map[string][]int{}}
+		mn := &MockCertNotifier{Err: errors.New("mock_error")}
+		scanner := scheduler.NewExpiryScanner(mdb, mn, []int{7})
+
+		assert.NotNil(t, scanner.Scan(context.Background()))
+		assert.Empty(t, mdb.Notifications)
+
+		// sent by the next scan once the notifier recovers
+		mn.Err = nil
+		assert.Nil(t, scanner.Scan(context.Background()))
+		assert.Equal(t, []string{"soon@7"}, mn.Expiring)
+	})
+	t.Run("error_get_expiring_certs", func(t *testing.T) {
+		mdb := &MockDatabase{Err: errors.New("mock_error")}
+		scanner := scheduler.NewExpiryScanner(mdb, &MockCertNotifier{}, []int{7})
+
+		assert.NotNil(t, scanner.Scan(context.Background()))
+	})
+}
+
+func TestParseThresholds(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		thresholds, err := scheduler.ParseThresholds("30, 14,7,1")
+		assert.Nil(t, err)
+		assert.Equal(t, []int{30, 14, 7, 1}, thresholds)
+	})
+	t.Run("error_not_a_number", func(t *testing.T) {
+		thresholds, err := scheduler.ParseThresholds("30,two")
+		assert.NotNil(t, err)
+		assert.Nil(t, thresholds)
+	})
+	t.Run("error_not_positive", func(t *testing.T) {
+		thresholds, err := scheduler.ParseThresholds("0")
+		assert.NotNil(t, err)
+		assert.Nil(t, thresholds)
+	})
+}
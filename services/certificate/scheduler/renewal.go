@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"certificate/notifier"
+	"certificate/pki"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RenewalPrincipal is the principal Renewer changes the certificates of all
+// users as, which is the actor of those changes in the certificate history.
+var RenewalPrincipal = db.SystemPrincipal("renewal")
+
+// ACMEClient obtains certificates from an ACME directory, see acme.Client.
+type ACMEClient interface {
+	Obtain(ctx context.Context, key crypto.Signer, sans []string) ([]*x509.Certificate, error)
+}
+
+// Renewer renews certificates according to their renewal policies.
+type Renewer struct {
+	db       db.Database
+	notifier notifier.CertNotifier
+	profiles ca.Profiles
+	acme     ACMEClient
+	baseURL  string
+}
+
+// NewRenewer returns a Renewer for the certificates in `db` that sends
+// notifications through `notifier`.
+func NewRenewer(db db.Database, notifier notifier.CertNotifier) *Renewer {
+	return &Renewer{db: db, notifier: notifier, profiles: ca.DefaultProfiles}
+}
+
+// WithProfiles sets the profiles certificates are renewed under by internal
+// CAs.
+func (r *Renewer) WithProfiles(profiles ca.Profiles) *Renewer {
+	r.profiles = profiles
+	return r
+}
+
+// WithBaseURL sets the public URL of the service, which certificates renewed
+// by internal CAs point to for their CRL and OCSP responder.
+func (r *Renewer) WithBaseURL(baseURL string) *Renewer {
+	r.baseURL = baseURL
+	return r
+}
+
+// WithACME sets the client certificates are renewed with through ACME.
+func (r *Renewer) WithACME(client ACMEClient) *Renewer {
+	r.acme = client
+	return r
+}
+
+// Renew renews every certificate that is due according to its renewal
+// policy. A failed renewal is retried by the next Renew.
+func (r *Renewer) Renew(ctx context.Context) error {
+	renewals, err := r.db.GetDueRenewals(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for _, renewal := range renewals {
+		if errRenew := r.renew(ctx, renewal); errRenew != nil {
+			err = errors.Join(err, fmt.Errorf("failed to renew cert %s: %w", renewal.Cert.UUID, errRenew))
+		}
+	}
+	return err
+}
+
+// renew issues a certificate with a new key and the same subject and SANs
+// as `renewal.Cert`, adds it as its replacement for the same user and team
+// and deactivates it.
+func (r *Renewer) renew(ctx context.Context, renewal *db.Renewal) error {
+	old := renewal.Cert
+	oldX509, err := pki.ParseCertificate(old.Body)
+	if err != nil {
+		return err
+	}
+	key, err := pki.GenerateKey(old.KeyAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	// issue the renewed certificate
+	cert := &db.Cert{
+		UserUUID:     old.UserUUID,
+		TeamUUID:     old.TeamUUID,
+		ReplacesUUID: old.UUID,
+		Labels:       old.Labels,
+	}
+	var issued *x509.Certificate
+	switch renewal.Policy.Method {
+	case db.RenewalMethodCA:
+		cert.AuthorityUUID = old.AuthorityUUID
+		issued, err = r.issue(old.AuthorityUUID, renewal.Policy.Profile, key, oldX509)
+	case db.RenewalMethodACME:
+		issued, cert.Chain, err = r.obtain(ctx, key, oldX509)
+	default:
+		err = fmt.Errorf("unknown renewal method %q", renewal.Policy.Method)
+	}
+	if err != nil {
+		return err
+	}
+	cert.Body = pki.EncodeCertificate(issued)
+	if cert.PrivateKey, err = pki.EncodePrivateKey(key); err != nil {
+		return err
+	}
+
+	// add the renewed certificate, which takes over the renewal policy, along
+	// with its chain, and deactivate the old certificate at once
+	if err := r.db.RenewCert(cert, RenewalPrincipal); err != nil {
+		return fmt.Errorf("failed to add renewed cert: %w", err)
+	}
+	if err := r.notifier.SendCertToggled(cert.UUID, cert.Active, cert.Labels); err != nil {
+		return fmt.Errorf("failed to send cert toggled message: %w", err)
+	}
+	if err := r.notifier.SendCertToggled(old.UUID, false, old.Labels); err != nil {
+		return fmt.Errorf("failed to send cert toggled message: %w", err)
+	}
+	if err := r.notifier.SendCertRenewed(cert.UUID, old.UUID); err != nil {
+		return fmt.Errorf("failed to send cert renewed message: %w", err)
+	}
+	return nil
+}
+
+// issue signs a certificate for `key` like `old` with internal CA
+// `authorityUUID` under profile `profileName`.
+func (r *Renewer) issue(authorityUUID, profileName string, key crypto.Signer, old *x509.Certificate) (*x509.Certificate, error) {
+	profile, ok := r.profiles[profileName]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", profileName)
+	}
+	authority, err := r.db.GetAuthority(authorityUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authority: %w", err)
+	}
+	loaded, err := ca.Load(authority.Body, authority.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authority: %w", err)
+	}
+	loaded.WithRevocationURLs(r.baseURL, authorityUUID)
+	encoded, err := pki.CreateCSR(key, old.Subject, pki.SANs(old))
+	if err != nil {
+		return nil, err
+	}
+	csr, err := ca.ParseCSR(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return loaded.Sign(csr, profile)
+}
+
+// obtain obtains a certificate for `key` with the SANs of `old` through
+// ACME, and returns it with the PEM encoded intermediates it comes with.
+func (r *Renewer) obtain(ctx context.Context, key crypto.Signer, old *x509.Certificate) (*x509.Certificate, []string, error) {
+	if r.acme == nil {
+		return nil, nil, errors.New("ACME is not configured")
+	}
+	chain, err := r.acme.Obtain(ctx, key, pki.SANs(old))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chain) == 0 {
+		return nil, nil, errors.New("ACME directory returned no certificate")
+	}
+	var intermediates []string
+	for _, issuer := range chain[1:] {
+		intermediates = append(intermediates, pki.EncodeCertificate(issuer))
+	}
+	return chain[0], intermediates, nil
+}
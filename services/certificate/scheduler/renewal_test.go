@@ -0,0 +1,158 @@
+package scheduler_test
+
+import (
+	"certificate/ca"
+	"certificate/db"
+	"certificate/pki"
+	"certificate/pki/pkitest"
+	"certificate/scheduler"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// MockACMEClient signs certificates with `CA` instead of an ACME directory,
+// or returns no certificates if `Empty` is set.
+type MockACMEClient struct {
+	CA    *ca.CA
+	Empty bool
+	Err   error
+}
+
+func (m *MockACMEClient) Obtain(ctx context.Context, key crypto.Signer, sans []string) ([]*x509.Certificate, error) {
+	if m.Err != nil || m.Empty {
+		return nil, m.Err
+	}
+	csr, err := pki.CreateCSR(key, pkix.Name{CommonName: sans[0]}, sans)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := ca.ParseCSR(csr)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := m.CA.Sign(parsed, ca.DefaultProfiles["server"])
+	return []*x509.Certificate{cert, m.CA.Cert}, err
+}
+
+func TestRenewer_Renew(t *testing.T) {
+	root, err := ca.NewRoot(pkix.Name{CommonName: "Mock Root CA"}, 24*time.Hour)
+	assert.Nil(t, err)
+	rootKey, err := pki.EncodePrivateKey(root.Key)
+	assert.Nil(t, err)
+	authority := &db.Authority{UUID: "mock_authority_uuid", Body: pki.EncodeCertificate(root.Cert), PrivateKey: rootKey}
+
+	// newRenewal returns a due renewal of a new certificate issued by `root`
+	// under `method`.
+	newRenewal := func(method string) *db.Renewal {
+		key := pkitest.NewKey()
+		csr, err := pki.CreateCSR(key, pkix.Name{CommonName: "dog.example.com"}, []string{"dog.example.com"})
+		assert.Nil(t, err)
+		parsed, err := ca.ParseCSR(csr)
+		assert.Nil(t, err)
+		issued, err := root.Sign(parsed, ca.DefaultProfiles["server"])
+		assert.Nil(t, err)
+		cert := &db.Cert{
+			UUID:          "mock_old_cert_uuid",
+			UserUUID:      "mock_user_uuid",
+			TeamUUID:      "mock_team_uuid",
+			AuthorityUUID: authority.UUID,
+			Body:          pki.EncodeCertificate(issued),
+			KeyAlgorithm:  pki.KeyAlgorithm(issued),
+			Active:        true,
+		}
+		return &db.Renewal{Cert: cert, Policy: &db.RenewalPolicy{
+			CertUUID:         cert.UUID,
+			UserUUID:         cert.UserUUID,
+			Method:           method,
+			DaysBeforeExpiry: 30,
+			Profile:          "server",
+		}}
+	}
+
+	t.Run("happy_path_ca", func(t *testing.T) {
+		renewal := newRenewal(db.RenewalMethodCA)
+		mdb := &MockDatabase{Due: []*db.Renewal{renewal}, Authorities: map[string]*db.Authority{authority.UUID: authority}}
+		mn := &MockCertNotifier{}
+
+		renewer := scheduler.NewRenewer(mdb, mn).WithBaseURL("https://certs.example.com")
+		assert.Nil(t, renewer.Renew(context.Background()))
+		assert.Len(t, mdb.Added, 1)
+		added := mdb.Added[0]
+		assert.Equal(t, renewal.Cert.UUID, added.ReplacesUUID)
+		assert.Equal(t, renewal.Cert.UserUUID, added.UserUUID)
+		assert.Equal(t, renewal.Cert.TeamUUID, added.TeamUUID)
+		assert.Equal(t, authority.UUID, added.AuthorityUUID)
+		assert.NotEmpty(t, added.PrivateKey)
+		issued, err := pki.ParseCertificate(added.Body)
+		assert.Nil(t, err)
+		assert.Nil(t, issued.CheckSignatureFrom(root.Cert))
+		assert.Equal(t, []string{"dog.example.com"}, issued.DNSNames)
+		assert.Equal(t, "CN=dog.example.com", issued.Subject.String())
+		assert.Equal(t, []string{"https://certs.example.com/ca/" + authority.UUID + "/crl"}, issued.CRLDistributionPoints)
+		assert.Equal(t, []string{"https://certs.example.com/ocsp"}, issued.OCSPServer)
+		key, err := pki.ParsePrivateKey(added.PrivateKey)
+		assert.Nil(t, err)
+		assert.Nil(t, pki.CheckKeyPair(issued, key))
+
+		assert.Equal(t, []string{renewal.Cert.UUID}, mdb.Deactivated)
+		assert.Equal(t, []string{added.UUID + "=true", renewal.Cert.UUID + "=false"}, mn.Toggled)
+		assert.Equal(t, []string{added.UUID + "<-" + renewal.Cert.UUID}, mn.Renewed)
+	})
+	t.Run("happy_path_acme", func(t *testing.T) {
+		renewal := newRenewal(db.RenewalMethodACME)
+		mdb := &MockDatabase{Due: []*db.Renewal{renewal}}
+		mn := &MockCertNotifier{}
+		renewer := scheduler.NewRenewer(mdb, mn).WithACME(&MockACMEClient{CA: root})
+
+		assert.Nil(t, renewer.Renew(context.Background()))
+		assert.Len(t, mdb.Added, 1)
+		assert.Equal(t, renewal.Cert.UUID, mdb.Added[0].ReplacesUUID)
+		assert.Empty(t, mdb.Added[0].AuthorityUUID)
+		assert.Equal(t, []string{pki.EncodeCertificate(root.Cert)}, mdb.Added[0].Chain)
+		assert.Equal(t, []string{renewal.Cert.UUID}, mdb.Deactivated)
+		assert.Len(t, mn.Renewed, 1)
+	})
+	t.Run("error_acme_not_configured", func(t *testing.T) {
+		mdb := &MockDatabase{Due: []*db.Renewal{newRenewal(db.RenewalMethodACME)}}
+
+		assert.NotNil(t, scheduler.NewRenewer(mdb, &MockCertNotifier{}).Renew(context.Background()))
+		assert.Empty(t, mdb.Added)
+		assert.Empty(t, mdb.Deactivated)
+	})
+	t.Run("error_acme_no_cert", func(t *testing.T) {
+		mdb := &MockDatabase{Due: []*db.Renewal{newRenewal(db.RenewalMethodACME)}}
+		renewer := scheduler.NewRenewer(mdb, &MockCertNotifier{}).
+			WithACME(&MockACMEClient{Empty: true})
+
+		assert.NotNil(t, renewer.Renew(context.Background()))
+		assert.Empty(t, mdb.Added)
+		assert.Empty(t, mdb.Deactivated)
+	})
+	t.Run("error_acme_failed", func(t *testing.T) {
+		mdb := &MockDatabase{Due: []*db.Renewal{newRenewal(db.RenewalMethodACME)}}
+		renewer := scheduler.NewRenewer(mdb, &MockCertNotifier{}).
+			WithACME(&MockACMEClient{Err: errors.New("mock_error")})
+
+		assert.NotNil(t, renewer.Renew(context.Background()))
+		assert.Empty(t, mdb.Added)
+		assert.Empty(t, mdb.Deactivated)
+	})
+	t.Run("error_unknown_profile", func(t *testing.T) {
+		mdb := &MockDatabase{Due: []*db.Renewal{newRenewal(db.RenewalMethodCA)}, Authorities: map[string]*db.Authority{authority.UUID: authority}}
+		renewer := scheduler.NewRenewer(mdb, &MockCertNotifier{}).WithProfiles(ca.Profiles{})
+
+		assert.NotNil(t, renewer.Renew(context.Background()))
+		assert.Empty(t, mdb.Added)
+	})
+	t.Run("error_get_due_renewals", func(t *testing.T) {
+		mdb := &MockDatabase{Err: errors.New("mock_error")}
+
+		assert.NotNil(t, scheduler.NewRenewer(mdb, &MockCertNotifier{}).Renew(context.Background()))
+	})
+}
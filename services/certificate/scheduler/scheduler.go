@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Job is a task run periodically by a Scheduler.
+type Job func(ctx context.Context) error
+
+// job is a Job along with its name and how often it runs.
+type job struct {
+	name     string
+	interval time.Duration
+	run      Job
+}
+
+// Scheduler runs jobs in the background at fixed intervals.
+type Scheduler struct {
+	jobs []job
+}
+
+// New returns a Scheduler without jobs.
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// WithJob adds Job `run` named `name` that runs every `interval`.
+func (s *Scheduler) WithJob(name string, interval time.Duration, run Job) *Scheduler {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+	return s
+}
+
+// Start runs every job right away and then every interval until `ctx` is
+// done, each in its own goroutine. Failed runs are logged and retried at the
+// next interval.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		go j.loop(ctx)
+	}
+}
+
+// loop runs `j` until `ctx` is done.
+func (j job) loop(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if err := j.run(ctx); err != nil {
+			log.Println(fmt.Errorf("job %q failed: %w", j.name, err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
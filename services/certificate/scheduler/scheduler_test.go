@@ -0,0 +1,185 @@
+package scheduler_test
+
+import (
+	"certificate/db"
+	"certificate/scheduler"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// MockDatabase returns `Expiring` for thresholds it has no notification for
+// yet, at that threshold or a lower one, `Due` renewals and `Revoked` certs
+// by authority. It records renewed and deactivated certs and saved CRLs.
+type MockDatabase struct {
+	db.Database
+	Expiring      map[int][]*db.Cert
+	Notifications map[string][]int
+	Due           []*db.Renewal
+	Authorities   map[string]*db.Authority
+	Revoked       map[string][]*db.Cert
+	CRLs          map[string]*db.CRL
+	Added         []*db.Cert
+	Deactivated   []string
+	Err           error
+}
+
+func (m *MockDatabase) GetExpiringCerts(now time.Time, thresholdDays int) ([]*db.Cert, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var certs []*db.Cert
+	for _, cert := range m.Expiring[thresholdDays] {
+		notified := false
+		for _, days := range m.Notifications[cert.UUID] {
+			notified = notified || days <= thresholdDays
+		}
+		if !notified {
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+func (m *MockDatabase) AddExpiryNotification(certUUID string, thresholdDays int) error {
+	m.Notifications[certUUID] = append(m.Notifications[certUUID], thresholdDays)
+	return nil
+}
+
+func (m *MockDatabase) GetDueRenewals(now time.Time) ([]*db.Renewal, error) {
+	return m.Due, m.Err
+}
+
+func (m *MockDatabase) GetAuthority(uuid string) (*db.Authority, error) {
+	authority, ok := m.Authorities[uuid]
+	if !ok {
+		return nil, &db.ValidationError{Field: "authority_uuid", Err: errors.New("authority does not exist")}
+	}
+	return authority, nil
+}
+
+func (m *MockDatabase) GetAuthorities() ([]*db.Authority, error) {
+	var authorities []*db.Authority
+	for _, authority := range m.Authorities {
+		authorities = append(authorities, authority)
+	}
+	return authorities, m.Err
+}
+
+func (m *MockDatabase) GetRevokedCerts(authorityUUID string) ([]*db.Cert, error) {
+	return m.Revoked[authorityUUID], m.Err
+}
+
+func (m *MockDatabase) NextCRLNumber(authorityUUID string) (int64, error) {
+	if crl, ok := m.CRLs[authorityUUID]; ok {
+		return crl.Number + 1, nil
+	}
+	return 1, nil
+}
+
+func (m *MockDatabase) SaveCRL(crl *db.CRL) error {
+	m.CRLs[crl.AuthorityUUID] = crl
+	return nil
+}
+
+func (m *MockDatabase) GetCRL(authorityUUID string) (*db.CRL, error) {
+	return m.CRLs[authorityUUID], m.Err
+}
+
+func (m *MockDatabase) RenewCert(cert *db.Cert, principal *db.Principal) error {
+	cert.UUID = fmt.Sprintf("mock_cert_uuid_%d", len(m.Added))
+	cert.Active = true
+	m.Added = append(m.Added, cert)
+	m.Deactivated = append(m.Deactivated, cert.ReplacesUUID)
+	return nil
+}
+
+// MockCertNotifier records messages as strings.
+type MockCertNotifier struct {
+	Toggled     []string
+	Expiring    []string
+	Renewed     []string
+	Transferred []string
+	Err         error
+}
+
+func (m *MockCertNotifier) SendCertToggled(uuid string, active bool, labels map[string]string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Toggled = append(m.Toggled, fmt.Sprintf("%s=%t", uuid, active))
+	return nil
+}
+
+func (m *MockCertNotifier) SendCertExpiring(uuid string, notAfter time.Time, thresholdDays int) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Expiring = append(m.Expiring, fmt.Sprintf("%s@%d", uuid, thresholdDays))
+	return nil
+}
+
+func (m *MockCertNotifier) SendCertRenewed(uuid, replacesUUID string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Renewed = append(m.Renewed, fmt.Sprintf("%s<-%s", uuid, replacesUUID))
+	return nil
+}
+
+func (m *MockCertNotifier) SendCertTransferred(uuid, fromUserUUID, userUUID, teamUUID string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Transferred = append(m.Transferred, fmt.Sprintf("%s:%s->%s/%s", uuid, fromUserUUID, userUUID, teamUUID))
+	return nil
+}
+
+func TestScheduler_Start(t *testing.T) {
+	t.Run("happy_path", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		runs := make(chan struct{}, 10)
+		scheduler.New().
+			WithJob("mock_job", time.Millisecond, func(ctx context.Context) error {
+				select {
+				case runs <- struct{}{}:
+				default:
+				}
+				return nil
+			}).
+			Start(ctx)
+
+		for i := 0; i < 3; i++ {
+			select {
+			case <-runs:
+			case <-time.After(time.Second):
+				t.Fatal("job did not run")
+			}
+		}
+	})
+	t.Run("happy_path_retry_after_error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		runs := make(chan struct{}, 10)
+		scheduler.New().
+			WithJob("mock_job", time.Millisecond, func(ctx context.Context) error {
+				select {
+				case runs <- struct{}{}:
+				default:
+				}
+				return errors.New("mock_error")
+			}).
+			Start(ctx)
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-runs:
+			case <-time.After(time.Second):
+				t.Fatal("job was not retried")
+			}
+		}
+	})
+}
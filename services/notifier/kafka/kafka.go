@@ -10,11 +10,11 @@ import (
 	"syscall"
 )
 
-// ReadCertActiveStatusToggled returns a channel for receiving messages
-func ReadCertActiveStatusToggled(addr string) (chan kafka.Message, error) {
+// Read returns a channel for receiving messages of `topic`
+func Read(addr, topic string) (chan kafka.Message, error) {
 	r := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:  []string{addr},
-		Topic:    "cert-active-status-toggled",
+		Topic:    topic,
 		MaxBytes: 10e6,
 	})
 	messageChan := make(chan kafka.Message)
@@ -7,17 +7,25 @@ import (
 	"net/http"
 	"notifier/kafka"
 	"os"
+	"sync"
 )
 
-// handleCertToggle takes in message content and sends it to the specified
-// HTTP endpoint.
-func handleCertToggle(msg []byte, endpoint string) {
-	log.Println("received message", string(msg))
-	resp, err := http.Post(endpoint+"/cert-active-status-toggled", "application/json", bytes.NewReader(msg))
+// topics are the kafka topics to forward, each one is posted to the path of
+// the same name under the configured endpoint.
+var topics = []string{"cert-active-status-toggled", "cert-expiring", "cert-renewed",
+	"cert-transferred"}
+
+// handleMessage takes in message content of `topic` and sends it to the
+// specified HTTP endpoint.
+func handleMessage(msg []byte, endpoint, topic string) {
+	log.Println("received message", topic, string(msg))
+	resp, err := http.Post(endpoint+"/"+topic, "application/json", bytes.NewReader(msg))
 	if err != nil {
 		// TODO: add retry logic by not acknowledging receipt of the kafka message
 		log.Println(fmt.Errorf("failed to send notify via HTTP POST: %w", err))
+		return
 	}
+	resp.Body.Close()
 	log.Println("got status code", resp.StatusCode)
 }
 
@@ -34,18 +42,26 @@ func main() {
 		log.Fatal("KAFKA_ADDR ENV not set")
 	}
 
-	// establish kafka reader
-	messageChan, err := kafka.ReadCertActiveStatusToggled(addr)
-	if err != nil {
-		log.Fatal(fmt.Errorf("failed to get kafka consumer: %w", err))
-	}
-	log.Println("listening for messages")
+	// establish a kafka reader for every topic
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		messageChan, err := kafka.Read(addr, topic)
+		if err != nil {
+			log.Fatal(fmt.Errorf("failed to get kafka consumer: %w", err))
+		}
+		log.Println("listening for messages of", topic)
 
-	// process messages
-	for m := range messageChan {
-		// spawn a go routine here for concurrent processing of messages
-		// I'd do some time based backoff in real code so that requests
-		//are not sent too close to each other
-		go handleCertToggle(m.Value, endpoint)
+		// process messages
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			for m := range messageChan {
+				// spawn a go routine here for concurrent processing of messages
+				// I'd do some time based backoff in real code so that requests
+				//are not sent too close to each other
+				go handleMessage(m.Value, endpoint, topic)
+			}
+		}(topic)
 	}
+	wg.Wait()
 }